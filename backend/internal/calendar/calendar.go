@@ -0,0 +1,285 @@
+package calendar
+
+import (
+	_ "embed"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Location 北京时间，所有 A 股日期计算均以此时区为准
+var Location = time.FixedZone("CST", 8*3600)
+
+// DateLayout 日期格式
+const DateLayout = "2006-01-02"
+
+// 市场代码
+const (
+	MarketCN = "cn"
+	MarketUS = "us"
+	MarketHK = "hk"
+)
+
+//go:embed holidays_cn.yaml
+var bundledHolidays []byte
+
+//go:embed holidays_us.yaml
+var bundledUSHolidays []byte
+
+//go:embed holidays_hk.yaml
+var bundledHKHolidays []byte
+
+// Session 交易时段，以当日零点起的分钟数表示，左闭右开
+type Session struct {
+	Open  int
+	Close int
+}
+
+// cnSessions A 股交易时段：9:30-11:30、13:00-15:00
+var cnSessions = []Session{
+	{Open: 9*60 + 30, Close: 11*60 + 30},
+	{Open: 13 * 60, Close: 15 * 60},
+}
+
+// holidayFile 休市日数据文件
+type holidayFile struct {
+	Holidays []string `yaml:"holidays"`
+}
+
+// Calendar 交易日历
+// 休市日只登记到 lastYear 年，之后的工作日一律视为交易日，首次遇到时按年记录警告
+type Calendar struct {
+	name     string
+	loc      *time.Location
+	sessions []Session
+
+	mu       sync.RWMutex
+	holidays map[string]bool
+	lastYear int
+	warned   map[int]bool
+}
+
+// New 创建交易日历，holidays 为工作日休市日期（YYYY-MM-DD）
+func New(loc *time.Location, sessions []Session, holidays []string) (*Calendar, error) {
+	c := &Calendar{
+		name:     "custom",
+		loc:      loc,
+		sessions: sessions,
+		holidays: make(map[string]bool),
+		warned:   make(map[int]bool),
+	}
+	if err := c.addHolidays(holidays); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+var (
+	defaultCalendar *Calendar
+	defaultOnce     sync.Once
+
+	overseasCalendars map[string]*Calendar
+	overseasOnce      sync.Once
+)
+
+// Default 返回 A 股交易日历，首次调用时加载内置休市日
+func Default() *Calendar {
+	defaultOnce.Do(func() {
+		defaultCalendar = mustBundled(MarketCN, bundledHolidays, cnSessions)
+	})
+	return defaultCalendar
+}
+
+// Market 返回指定市场的交易日历：cn 为 A 股日历，us、hk 为美股、港股休市日历，未知市场返回 nil
+// 境外日历只用于按日期判断是否休市，日期按当地日历日记录、以北京时间零点表示，不含交易时段
+func Market(name string) *Calendar {
+	if name == MarketCN {
+		return Default()
+	}
+	overseasOnce.Do(func() {
+		overseasCalendars = map[string]*Calendar{
+			MarketUS: mustBundled(MarketUS, bundledUSHolidays, nil),
+			MarketHK: mustBundled(MarketHK, bundledHKHolidays, nil),
+		}
+	})
+	return overseasCalendars[name]
+}
+
+// mustBundled 按内置休市日创建日历，数据有误时 panic
+func mustBundled(name string, raw []byte, sessions []Session) *Calendar {
+	var data holidayFile
+	if err := yaml.Unmarshal(raw, &data); err != nil {
+		panic(fmt.Sprintf("calendar: invalid bundled holidays: %v", err))
+	}
+	c, err := New(Location, sessions, data.Holidays)
+	if err != nil {
+		panic(fmt.Sprintf("calendar: invalid bundled holidays: %v", err))
+	}
+	c.name = name
+	return c
+}
+
+// LoadFile 从 YAML 文件追加休市日，用于在不重新编译的情况下更新日历
+func (c *Calendar) LoadFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var data holidayFile
+	if err := yaml.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("calendar: %s: %w", path, err)
+	}
+	return c.addHolidays(data.Holidays)
+}
+
+// addHolidays 校验并登记休市日，同时更新休市日覆盖到的最后一年
+func (c *Calendar) addHolidays(dates []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, date := range dates {
+		day, err := time.ParseInLocation(DateLayout, date, c.loc)
+		if err != nil {
+			return fmt.Errorf("calendar: invalid holiday %q", date)
+		}
+		c.holidays[date] = true
+		if day.Year() > c.lastYear {
+			c.lastYear = day.Year()
+		}
+	}
+	return nil
+}
+
+// LastYear 休市日数据覆盖到的最后一年，没有任何休市日时为 0
+func (c *Calendar) LastYear() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastYear
+}
+
+// warnUncovered 每个未覆盖的年份只记录一次警告
+func (c *Calendar) warnUncovered(year int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.warned[year] {
+		return
+	}
+	c.warned[year] = true
+	log.Printf("[calendar] %s holidays are only listed through %d, weekdays in %d are treated as trading days",
+		c.name, c.lastYear, year)
+}
+
+// Location 日历所在时区
+func (c *Calendar) Location() *time.Location {
+	return c.loc
+}
+
+// FormatDate 将时间格式化为日历时区下的日期
+func (c *Calendar) FormatDate(t time.Time) string {
+	return t.In(c.loc).Format(DateLayout)
+}
+
+// IsTradingDay 判断 t 所在日期是否为交易日，超出休市日覆盖年份的工作日视为交易日并记录警告
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	t = t.In(c.loc)
+	if weekday := t.Weekday(); weekday == time.Saturday || weekday == time.Sunday {
+		return false
+	}
+
+	c.mu.RLock()
+	holiday := c.holidays[t.Format(DateLayout)]
+	covered := t.Year() <= c.lastYear
+	c.mu.RUnlock()
+
+	if !covered {
+		c.warnUncovered(t.Year())
+	}
+	return !holiday
+}
+
+// IsTradingTime 判断 t 是否处于交易时段内
+func (c *Calendar) IsTradingTime(t time.Time) bool {
+	if !c.IsTradingDay(t) {
+		return false
+	}
+
+	minute := minuteOfDay(t.In(c.loc))
+	for _, session := range c.sessions {
+		if minute >= session.Open && minute < session.Close {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAfterClose 判断 t 是否已过当日最后一个交易时段的收盘时间
+func (c *Calendar) IsAfterClose(t time.Time) bool {
+	if len(c.sessions) == 0 {
+		return false
+	}
+	return minuteOfDay(t.In(c.loc)) >= c.sessions[len(c.sessions)-1].Close
+}
+
+// PrevTradingDay 返回 t 所在日期之前（不含当日）的最近交易日零点
+func (c *Calendar) PrevTradingDay(t time.Time) time.Time {
+	day := startOfDay(t.In(c.loc))
+	for {
+		day = day.AddDate(0, 0, -1)
+		if c.IsTradingDay(day) {
+			return day
+		}
+	}
+}
+
+// NextTradingDay 返回 t 所在日期之后（不含当日）的最近交易日零点
+func (c *Calendar) NextTradingDay(t time.Time) time.Time {
+	day := startOfDay(t.In(c.loc))
+	for {
+		day = day.AddDate(0, 0, 1)
+		if c.IsTradingDay(day) {
+			return day
+		}
+	}
+}
+
+// LatestTradingDay 返回 t 所在日期（若为交易日）或之前最近的交易日零点
+func (c *Calendar) LatestTradingDay(t time.Time) time.Time {
+	if c.IsTradingDay(t) {
+		return startOfDay(t.In(c.loc))
+	}
+	return c.PrevTradingDay(t)
+}
+
+// NavDate 返回在 t 时刻提交的申购/赎回所适用的净值日期：
+// 交易日收盘前提交按当日净值，否则顺延至下一个交易日
+func (c *Calendar) NavDate(t time.Time) time.Time {
+	if c.IsTradingDay(t) && !c.IsAfterClose(t) {
+		return startOfDay(t.In(c.loc))
+	}
+	return c.NextTradingDay(t)
+}
+
+// TradingDaysBetween 返回 [from, to] 区间内的交易日零点列表
+func (c *Calendar) TradingDaysBetween(from, to time.Time) []time.Time {
+	var days []time.Time
+	end := startOfDay(to.In(c.loc))
+	for day := startOfDay(from.In(c.loc)); !day.After(end); day = day.AddDate(0, 0, 1) {
+		if c.IsTradingDay(day) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func minuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
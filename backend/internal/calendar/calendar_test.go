@@ -0,0 +1,79 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func at(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, Location)
+}
+
+func TestNavDate(t *testing.T) {
+	cal := Default()
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{"trading day before close", at(2024, 3, 4, 14, 59), "2024-03-04"},
+		{"trading day before open", at(2024, 3, 4, 8, 0), "2024-03-04"},
+		{"trading day at close", at(2024, 3, 4, 15, 0), "2024-03-05"},
+		{"Friday after close", at(2024, 3, 8, 16, 0), "2024-03-11"},
+		{"Saturday", at(2024, 3, 9, 10, 0), "2024-03-11"},
+		{"eve of National Day after close", at(2024, 9, 30, 15, 30), "2024-10-08"},
+		{"during National Day", at(2024, 10, 3, 10, 0), "2024-10-08"},
+		{"other time zone is converted to Beijing time", time.Date(2024, 3, 4, 6, 30, 0, 0, time.UTC), "2024-03-04"},
+		{"other time zone after close", time.Date(2024, 3, 4, 7, 30, 0, 0, time.UTC), "2024-03-05"},
+	}
+	for _, tt := range tests {
+		if got := cal.FormatDate(cal.NavDate(tt.t)); got != tt.want {
+			t.Errorf("%s: NavDate(%v) = %s, want %s", tt.name, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestNextTradingDay(t *testing.T) {
+	cal := Default()
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{"weekday", at(2024, 3, 4, 10, 0), "2024-03-05"},
+		{"Friday", at(2024, 3, 8, 10, 0), "2024-03-11"},
+		{"Sunday", at(2024, 3, 10, 23, 59), "2024-03-11"},
+		{"before Dragon Boat Festival", at(2024, 6, 7, 10, 0), "2024-06-11"},
+		{"before Spring Festival", at(2024, 2, 8, 10, 0), "2024-02-19"},
+		{"year end", at(2023, 12, 29, 10, 0), "2024-01-02"},
+	}
+	for _, tt := range tests {
+		next := cal.NextTradingDay(tt.t)
+		if got := cal.FormatDate(next); got != tt.want {
+			t.Errorf("%s: NextTradingDay(%v) = %s, want %s", tt.name, tt.t, got, tt.want)
+		}
+		if next.Hour() != 0 || next.Minute() != 0 {
+			t.Errorf("%s: NextTradingDay(%v) = %v, want midnight", tt.name, tt.t, next)
+		}
+	}
+}
+
+func TestLastYear(t *testing.T) {
+	cal, err := New(Location, cnSessions, []string{"2030-01-01", "2031-02-03", "2029-12-31"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cal.LastYear(); got != 2031 {
+		t.Errorf("LastYear = %d, want 2031", got)
+	}
+	if err := cal.addHolidays([]string{"2032-01-01"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := cal.LastYear(); got != 2032 {
+		t.Errorf("LastYear after appending = %d, want 2032", got)
+	}
+	// 超出覆盖年份的工作日视为交易日
+	if !cal.IsTradingDay(at(2033, 1, 3, 10, 0)) {
+		t.Error("weekday beyond the covered years should be a trading day")
+	}
+}
@@ -29,14 +29,40 @@ type DatabaseConfig struct {
 
 // AppConfig 应用配置
 type AppConfig struct {
-	RefreshInterval int    `yaml:"refresh_interval"`
-	LogLevel        string `yaml:"log_level"`
+	RefreshInterval         int               `yaml:"refresh_interval"`
+	LogLevel                string            `yaml:"log_level"`
+	HoldingsRemainderFactor *float64          `yaml:"holdings_remainder_factor"`
+	CalibrationWindow       int               `yaml:"calibration_window"`
+	HolidayFile             string            `yaml:"holiday_file"`
+	OverseasHolidayFiles    map[string]string `yaml:"overseas_holiday_files"`
+	RiskFreeRate            float64           `yaml:"risk_free_rate"`
+	BenchmarkIndex          string            `yaml:"benchmark_index"`
+}
+
+// defaultRemainderFactor 未配置 holdings_remainder_factor 时，未披露部分按重仓股平均涨跌计
+const defaultRemainderFactor = 1.0
+
+// RemainderFactor 重仓股估值法中未披露部分相对重仓股平均涨跌的比例，未配置时为 1，显式配置的 0 表示不涨不跌
+func (c AppConfig) RemainderFactor() float64 {
+	if c.HoldingsRemainderFactor == nil {
+		return defaultRemainderFactor
+	}
+	return *c.HoldingsRemainderFactor
 }
 
 // ScraperConfig 爬虫配置
 type ScraperConfig struct {
-	Timeout    int `yaml:"timeout"`
-	RetryCount int `yaml:"retry_count"`
+	Timeout             int      `yaml:"timeout"`
+	RetryCount          int      `yaml:"retry_count"`
+	Sources             []string `yaml:"sources"`
+	StaleAfter          int      `yaml:"stale_after"`
+	FundgzBaseURL       string   `yaml:"fundgz_base_url"`
+	EastmoneyAPIBaseURL string   `yaml:"eastmoney_api_base_url"`
+	FundMobBaseURL      string   `yaml:"fundmob_base_url"`
+	FundF10BaseURL      string   `yaml:"fundf10_base_url"`
+	TencentBaseURL      string   `yaml:"tencent_base_url"`
+	TencentKlineBaseURL string   `yaml:"tencent_kline_base_url"`
+	ChinamoneyBaseURL   string   `yaml:"chinamoney_base_url"`
 }
 
 // CORSConfig CORS配置
@@ -73,6 +99,25 @@ func Load() (*Config, error) {
 	if cfg.App.RefreshInterval == 0 {
 		cfg.App.RefreshInterval = 60
 	}
+	if cfg.App.HoldingsRemainderFactor == nil {
+		factor := defaultRemainderFactor
+		cfg.App.HoldingsRemainderFactor = &factor
+	}
+	if cfg.App.CalibrationWindow == 0 {
+		cfg.App.CalibrationWindow = 60
+	}
+	if cfg.App.BenchmarkIndex == "" {
+		cfg.App.BenchmarkIndex = "sh000300"
+	}
+	if cfg.Scraper.Timeout == 0 {
+		cfg.Scraper.Timeout = 30
+	}
+	if len(cfg.Scraper.Sources) == 0 {
+		cfg.Scraper.Sources = []string{"eastmoney"}
+	}
+	if cfg.Scraper.StaleAfter == 0 {
+		cfg.Scraper.StaleAfter = 600
+	}
 
 	return cfg, nil
 }
@@ -1,9 +1,17 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+	"fundnet/backend/internal/scrapers"
 	"fundnet/backend/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -13,19 +21,33 @@ import (
 type FundHandler struct {
 	fundService     *services.FundService
 	estimateService *services.EstimateService
+	navService      *services.NavService
+	riskService     *services.RiskService
+	backtestService *services.BacktestService
+	fxService       *services.FxService
+	httpClient      *scrapers.Client
 }
 
 // NewFundHandler 创建基金处理器
-func NewFundHandler(fundService *services.FundService, estimateService *services.EstimateService) *FundHandler {
+func NewFundHandler(fundService *services.FundService, estimateService *services.EstimateService,
+	navService *services.NavService, riskService *services.RiskService, backtestService *services.BacktestService,
+	fxService *services.FxService, httpClient *scrapers.Client) *FundHandler {
 	return &FundHandler{
 		fundService:     fundService,
 		estimateService: estimateService,
+		navService:      navService,
+		riskService:     riskService,
+		backtestService: backtestService,
+		fxService:       fxService,
+		httpClient:      httpClient,
 	}
 }
 
 // RegisterRoutes 注册路由
-func RegisterRoutes(router *gin.Engine, fundService *services.FundService, estimateService *services.EstimateService) {
-	handler := NewFundHandler(fundService, estimateService)
+func RegisterRoutes(router *gin.Engine, fundService *services.FundService, estimateService *services.EstimateService,
+	navService *services.NavService, riskService *services.RiskService, backtestService *services.BacktestService,
+	fxService *services.FxService, httpClient *scrapers.Client) {
+	handler := NewFundHandler(fundService, estimateService, navService, riskService, backtestService, fxService, httpClient)
 
 	api := router.Group("/api")
 	{
@@ -33,8 +55,21 @@ func RegisterRoutes(router *gin.Engine, fundService *services.FundService, estim
 		funds := api.Group("/funds")
 		{
 			funds.GET("", handler.GetFunds)
+			funds.GET("/premiums", handler.GetFundPremiums)
 			funds.GET("/:code", handler.GetFund)
 			funds.GET("/:code/estimate", handler.GetFundEstimate)
+			funds.GET("/:code/navs", handler.GetFundNavs)
+			funds.GET("/:code/yields", handler.GetFundYields)
+			funds.GET("/:code/holdings", handler.GetFundHoldings)
+			funds.GET("/:code/accuracy", handler.GetFundAccuracy)
+			funds.GET("/:code/risk", handler.GetFundRisk)
+			funds.POST("/:code/backfill", handler.BackfillFundNavs)
+			funds.GET("/:code/fees", handler.GetFundFees)
+			funds.PUT("/:code/fees", handler.UpdateFundFees)
+			funds.POST("/:code/fees/refresh", handler.RefreshFundFees)
+			funds.GET("/:code/actions", handler.GetFundActions)
+			funds.POST("/:code/actions/refresh", handler.RefreshFundActions)
+			funds.POST("/:code/metadata/refresh", handler.RefreshFundMetadata)
 			funds.POST("", handler.AddFund)
 			funds.DELETE("/:code", handler.RemoveFund)
 			funds.PUT("/:code", handler.UpdateFund)
@@ -49,20 +84,72 @@ func RegisterRoutes(router *gin.Engine, fundService *services.FundService, estim
 			sectors.DELETE("/:id", handler.DeleteSector)
 		}
 
+		// 组合与账户接口
+		portfolios := api.Group("/portfolios")
+		{
+			portfolios.GET("", handler.GetPortfolios)
+			portfolios.POST("", handler.CreatePortfolio)
+			portfolios.PUT("/:id", handler.UpdatePortfolio)
+			portfolios.DELETE("/:id", handler.DeletePortfolio)
+		}
+		accounts := api.Group("/accounts")
+		{
+			accounts.GET("", handler.GetAccounts)
+			accounts.POST("", handler.CreateAccount)
+			accounts.PUT("/:id", handler.UpdateAccount)
+			accounts.DELETE("/:id", handler.DeleteAccount)
+		}
+
 		// 持仓相关接口
 		positions := api.Group("/positions")
 		{
 			positions.GET("", handler.GetPositions)
+			positions.GET("/:id/redeem-preview", handler.GetRedeemPreview)
+			positions.GET("/:id/lots", handler.GetPositionLots)
 			positions.POST("", handler.AddPosition)
 			positions.PUT("/:id", handler.UpdatePosition)
 			positions.DELETE("/:id", handler.DeletePosition)
 		}
 
+		// 交易流水接口
+		transactions := api.Group("/transactions")
+		{
+			transactions.GET("", handler.GetTransactions)
+			transactions.POST("", handler.AddTransaction)
+			transactions.POST("/orders", handler.PlaceBuyOrder)
+			transactions.DELETE("/:id", handler.DeleteTransaction)
+		}
+
+		// 回测接口
+		backtests := api.Group("/backtests")
+		{
+			backtests.GET("", handler.GetBacktests)
+			backtests.GET("/:id", handler.GetBacktest)
+			backtests.POST("", handler.RunBacktest)
+			backtests.DELETE("/:id", handler.DeleteBacktest)
+		}
+
+		// 定投计划接口
+		plans := api.Group("/plans")
+		{
+			plans.GET("", handler.GetPlans)
+			plans.GET("/upcoming", handler.GetUpcomingExecutions)
+			plans.POST("", handler.CreatePlan)
+			plans.PUT("/:id", handler.UpdatePlan)
+			plans.DELETE("/:id", handler.DeletePlan)
+		}
+
 		// 资产相关接口
 		assets := api.Group("/assets")
 		{
 			assets.GET("", handler.GetAssets)
 			assets.GET("/summary", handler.GetAssetSummary)
+			assets.GET("/history", handler.GetAssetHistory)
+			assets.GET("/performance", handler.GetAssetPerformance)
+			assets.GET("/risk", handler.GetAssetRisk)
+			assets.GET("/targets", handler.GetAllocationTargets)
+			assets.PUT("/targets", handler.SaveAllocationTargets)
+			assets.GET("/rebalance", handler.GetRebalancePlan)
 		}
 
 		// 估算历史接口
@@ -77,6 +164,22 @@ func RegisterRoutes(router *gin.Engine, fundService *services.FundService, estim
 			config.GET("", handler.GetConfig)
 			config.PUT("", handler.UpdateConfig)
 		}
+
+		// 交易日历接口
+		api.GET("/calendar", handler.GetCalendar)
+
+		// 汇率接口
+		fx := api.Group("/fx")
+		{
+			fx.GET("", handler.GetFxRates)
+			fx.POST("/refresh", handler.RefreshFxRates)
+		}
+
+		// 爬虫接口
+		scraperGroup := api.Group("/scrapers")
+		{
+			scraperGroup.GET("/stats", handler.GetScraperStats)
+		}
 	}
 }
 
@@ -105,15 +208,26 @@ func (h *FundHandler) GetFunds(c *gin.Context) {
 	})
 }
 
-// GetFund 获取单个基金
+// GetFundPremiums 获取场内上市基金的二级市场价格与溢价率，sort 与 order 指定排序
+func (h *FundHandler) GetFundPremiums(c *gin.Context) {
+	funds, err := h.fundService.GetPremiums(c.Query("sort"), strings.ToLower(c.Query("order")))
+	if respondEntityError(c, err, "") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    funds,
+	})
+}
+
+// GetFund 获取单个基金，附基础资料与现任基金经理
 func (h *FundHandler) GetFund(c *gin.Context) {
 	code := c.Param("code")
-	fund, err := h.fundService.GetFundByCode(code)
+	fund, err := h.fundService.GetFundDetail(code)
 	if err != nil {
-		c.JSON(http.StatusNotFound, Response{
-			Code:    404,
-			Message: err.Error(),
-		})
+		respondEntityError(c, err, "fund not found")
 		return
 	}
 
@@ -124,10 +238,24 @@ func (h *FundHandler) GetFund(c *gin.Context) {
 	})
 }
 
-// GetFundEstimate 获取基金估算
+// GetFundEstimate 获取基金估算，method=holdings 时使用重仓股加权估算
 func (h *FundHandler) GetFundEstimate(c *gin.Context) {
 	code := c.Param("code")
-	estimate, err := h.estimateService.GetEstimate(code)
+
+	var estimate interface{}
+	var err error
+	switch method := c.DefaultQuery("method", services.EstimateMethodUpstream); method {
+	case services.EstimateMethodUpstream:
+		estimate, err = h.estimateService.GetEstimate(code)
+	case services.EstimateMethodHoldings:
+		estimate, err = h.estimateService.EstimateByHoldings(code)
+	default:
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: fmt.Sprintf("unknown estimate method %q", method),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
@@ -143,17 +271,11 @@ func (h *FundHandler) GetFundEstimate(c *gin.Context) {
 	})
 }
 
-// AddFundRequest 添加基金请求
-type AddFundRequest struct {
-	Code   string `json:"code" binding:"required"`
-	Name   string `json:"name"`
-	Sector string `json:"sector"`
-}
-
-// AddFund 添加基金订阅
-func (h *FundHandler) AddFund(c *gin.Context) {
-	var req AddFundRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+// GetFundNavs 获取基金历史净值，附按分红与拆分计算的复权净值
+func (h *FundHandler) GetFundNavs(c *gin.Context) {
+	code := c.Param("code")
+	from, to, err := parseDateRange(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, Response{
 			Code:    400,
 			Message: err.Error(),
@@ -161,7 +283,7 @@ func (h *FundHandler) AddFund(c *gin.Context) {
 		return
 	}
 
-	fund, err := h.fundService.AddFund(req.Code, req.Name, req.Sector)
+	navs, err := h.navService.GetAdjustedNavHistory(code, from, to)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
@@ -173,14 +295,24 @@ func (h *FundHandler) AddFund(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
-		Data:    fund,
+		Data:    navs,
 	})
 }
 
-// RemoveFund 取消基金订阅
-func (h *FundHandler) RemoveFund(c *gin.Context) {
+// GetFundYields 获取货币基金的每万份收益与七日年化收益率
+func (h *FundHandler) GetFundYields(c *gin.Context) {
 	code := c.Param("code")
-	if err := h.fundService.RemoveFund(code); err != nil {
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	yields, err := h.navService.GetMoneyYields(code, from, to)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
 			Message: err.Error(),
@@ -191,28 +323,14 @@ func (h *FundHandler) RemoveFund(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
+		Data:    yields,
 	})
 }
 
-// UpdateFundRequest 更新基金请求
-type UpdateFundRequest struct {
-	Name   string `json:"name"`
-	Sector string `json:"sector"`
-}
-
-// UpdateFund 更新基金信息
-func (h *FundHandler) UpdateFund(c *gin.Context) {
+// GetFundHoldings 获取基金最新披露的重仓股
+func (h *FundHandler) GetFundHoldings(c *gin.Context) {
 	code := c.Param("code")
-	var req UpdateFundRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Code:    400,
-			Message: err.Error(),
-		})
-		return
-	}
-
-	fund, err := h.fundService.UpdateFund(code, req.Name, req.Sector)
+	holdings, err := h.estimateService.GetHoldings(code)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
@@ -224,13 +342,19 @@ func (h *FundHandler) UpdateFund(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
-		Data:    fund,
+		Data:    holdings,
 	})
 }
 
-// GetSectors 获取板块列表
-func (h *FundHandler) GetSectors(c *gin.Context) {
-	sectors, err := h.fundService.GetAllSectors()
+// GetFundAccuracy 获取基金估值准确度
+func (h *FundHandler) GetFundAccuracy(c *gin.Context) {
+	code := c.Param("code")
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+
+	report, err := h.estimateService.GetAccuracy(code, days)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
@@ -242,21 +366,15 @@ func (h *FundHandler) GetSectors(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
-		Data:    sectors,
+		Data:    report,
 	})
 }
 
-// CreateSectorRequest 创建板块请求
-type CreateSectorRequest struct {
-	Name      string `json:"name" binding:"required"`
-	Color     string `json:"color"`
-	SortOrder int    `json:"sort_order"`
-}
-
-// CreateSector 创建板块
-func (h *FundHandler) CreateSector(c *gin.Context) {
-	var req CreateSectorRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+// BackfillFundNavs 回填基金历史净值
+func (h *FundHandler) BackfillFundNavs(c *gin.Context) {
+	code := c.Param("code")
+	from, _, err := parseDateRange(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, Response{
 			Code:    400,
 			Message: err.Error(),
@@ -264,10 +382,10 @@ func (h *FundHandler) CreateSector(c *gin.Context) {
 		return
 	}
 
-	sector, err := h.fundService.CreateSector(req.Name, req.Color, req.SortOrder)
+	count, err := h.navService.Backfill(code, from)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Code:    500,
+		c.JSON(http.StatusBadGateway, Response{
+			Code:    502,
 			Message: err.Error(),
 		})
 		return
@@ -276,30 +394,65 @@ func (h *FundHandler) CreateSector(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
-		Data:    sector,
+		Data: gin.H{
+			"code":  code,
+			"saved": count,
+		},
 	})
 }
 
-// UpdateSectorRequest 更新板块请求
-type UpdateSectorRequest struct {
-	Name      string `json:"name"`
-	Color     string `json:"color"`
-	SortOrder int    `json:"sort_order"`
+// parseDateRange 解析 from/to 查询参数（YYYY-MM-DD），缺省为零值
+func parseDateRange(c *gin.Context) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse(calendar.DateLayout, value); err != nil {
+			return from, to, fmt.Errorf("invalid from date %q", value)
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse(calendar.DateLayout, value); err != nil {
+			return from, to, fmt.Errorf("invalid to date %q", value)
+		}
+	}
+	return from, to, nil
 }
 
-// UpdateSector 更新板块
-func (h *FundHandler) UpdateSector(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Code:    400,
-			Message: "invalid sector id",
-		})
-		return
+// parseAssetFilter 解析 account_id 与 portfolio_id 查询参数，缺省为不过滤
+func parseAssetFilter(c *gin.Context) (services.AssetFilter, error) {
+	var filter services.AssetFilter
+	if value := c.Query("account_id"); value != "" {
+		accountID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid account id %q", value)
+		}
+		filter.AccountID = &accountID
+	}
+	if value := c.Query("portfolio_id"); value != "" {
+		portfolioID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid portfolio id %q", value)
+		}
+		filter.PortfolioID = &portfolioID
 	}
+	return filter, nil
+}
 
-	var req UpdateSectorRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+// parseTime 解析 RFC3339 时间，或按北京时间解析 "2006-01-02 15:04:05"
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", value, calendar.Location); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// GetFundRisk 获取基金风险指标，查询参数 from、to（YYYY-MM-DD，默认最近一年）与 benchmark（指数代码）
+func (h *FundHandler) GetFundRisk(c *gin.Context) {
+	from, to, err := parseDateRange(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, Response{
 			Code:    400,
 			Message: err.Error(),
@@ -307,34 +460,42 @@ func (h *FundHandler) UpdateSector(c *gin.Context) {
 		return
 	}
 
-	sector, err := h.fundService.UpdateSector(id, req.Name, req.Color, req.SortOrder)
+	metrics, err := h.riskService.GetFundRisk(c.Param("code"), from, to, c.Query("benchmark"))
+	respondRisk(c, metrics, err)
+}
+
+// GetAssetRisk 获取组合风险指标，查询参数同 GetFundRisk
+func (h *FundHandler) GetAssetRisk(c *gin.Context) {
+	from, to, err := parseDateRange(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Code:    500,
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
 			Message: err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, Response{
-		Code:    0,
-		Message: "success",
-		Data:    sector,
-	})
+	metrics, err := h.riskService.GetPortfolioRisk(from, to, c.Query("benchmark"))
+	respondRisk(c, metrics, err)
 }
 
-// DeleteSector 删除板块
-func (h *FundHandler) DeleteSector(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Code:    400,
-			Message: "invalid sector id",
+// respondRisk 输出风险指标，基金不存在或数据不足时返回 404
+func respondRisk(c *gin.Context, metrics *services.RiskMetrics, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: "fund not found",
 		})
 		return
 	}
-
-	if err := h.fundService.DeleteSector(id); err != nil {
+	if errors.Is(err, services.ErrInsufficientData) {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
 			Message: err.Error(),
@@ -345,39 +506,36 @@ func (h *FundHandler) DeleteSector(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
+		Data:    metrics,
 	})
 }
 
-// GetPositions 获取持仓列表
-func (h *FundHandler) GetPositions(c *gin.Context) {
-	positions, err := h.fundService.GetAllPositions()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+// GetFundFees 获取基金费率表，尚未抓取或录入时返回 404
+func (h *FundHandler) GetFundFees(c *gin.Context) {
+	schedule, err := h.fundService.GetFeeSchedule(c.Param("code"))
+	if respondEntityError(c, err, "fee schedule not found") {
 		return
 	}
 
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
-		Data:    positions,
+		Data:    schedule,
 	})
 }
 
-// AddPositionRequest 添加持仓请求
-type AddPositionRequest struct {
-	FundCode string  `json:"fund_code" binding:"required"`
-	FundName string  `json:"fund_name"`
-	Shares   float64 `json:"shares" binding:"required"`
-	Cost     float64 `json:"cost" binding:"required"`
-	Sector   string  `json:"sector"`
+// UpdateFundFeesRequest 手工录入费率请求，费率均为小数（0.015 表示 1.5%）
+type UpdateFundFeesRequest struct {
+	PurchaseRate    float64                 `json:"purchase_rate"`
+	ManagementRate  float64                 `json:"management_rate"`
+	CustodyRate     float64                 `json:"custody_rate"`
+	ServiceRate     float64                 `json:"service_rate"`
+	RedemptionTiers []models.RedemptionTier `json:"redemption_tiers"`
 }
 
-// AddPosition 添加持仓
-func (h *FundHandler) AddPosition(c *gin.Context) {
-	var req AddPositionRequest
+// UpdateFundFees 手工录入基金费率表
+func (h *FundHandler) UpdateFundFees(c *gin.Context) {
+	var req UpdateFundFeesRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, Response{
 			Code:    400,
@@ -386,7 +544,39 @@ func (h *FundHandler) AddPosition(c *gin.Context) {
 		return
 	}
 
-	position, err := h.fundService.AddPosition(req.FundCode, req.FundName, req.Shares, req.Cost, req.Sector)
+	code := c.Param("code")
+	err := h.fundService.SaveFeeSchedule(&models.FeeSchedule{
+		FundCode:        code,
+		PurchaseRate:    req.PurchaseRate,
+		ManagementRate:  req.ManagementRate,
+		CustodyRate:     req.CustodyRate,
+		ServiceRate:     req.ServiceRate,
+		RedemptionTiers: req.RedemptionTiers,
+	})
+	if respondEntityError(c, err, "") {
+		return
+	}
+
+	h.respondFeeSchedule(c, code)
+}
+
+// RefreshFundFees 重新抓取基金费率表，覆盖手工录入的费率
+func (h *FundHandler) RefreshFundFees(c *gin.Context) {
+	code := c.Param("code")
+	if err := h.fundService.RefreshFeeSchedule(code); err != nil {
+		c.JSON(http.StatusBadGateway, Response{
+			Code:    502,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.respondFeeSchedule(c, code)
+}
+
+// respondFeeSchedule 返回已保存的费率表
+func (h *FundHandler) respondFeeSchedule(c *gin.Context, code string) {
+	schedule, err := h.fundService.GetFeeSchedule(code)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
@@ -398,38 +588,39 @@ func (h *FundHandler) AddPosition(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
-		Data:    position,
+		Data:    schedule,
 	})
 }
 
-// UpdatePositionRequest 更新持仓请求
-type UpdatePositionRequest struct {
-	Shares float64 `json:"shares"`
-	Cost   float64 `json:"cost"`
-	Sector string  `json:"sector"`
+// GetFundActions 获取基金的分红与拆分记录
+func (h *FundHandler) GetFundActions(c *gin.Context) {
+	h.respondFundActions(c, c.Param("code"))
 }
 
-// UpdatePosition 更新持仓
-func (h *FundHandler) UpdatePosition(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Code:    400,
-			Message: "invalid position id",
+// RefreshFundActions 重新抓取基金的分红与拆分记录，并记入持有账户的交易流水
+func (h *FundHandler) RefreshFundActions(c *gin.Context) {
+	code := c.Param("code")
+	if _, err := h.fundService.RefreshCorporateActions(code); err != nil {
+		c.JSON(http.StatusBadGateway, Response{
+			Code:    502,
+			Message: err.Error(),
 		})
 		return
 	}
-
-	var req UpdatePositionRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Code:    400,
+	if _, err := h.fundService.ApplyCorporateActions(time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
 			Message: err.Error(),
 		})
 		return
 	}
 
-	position, err := h.fundService.UpdatePosition(id, req.Shares, req.Cost, req.Sector)
+	h.respondFundActions(c, code)
+}
+
+// respondFundActions 返回已保存的分红与拆分记录
+func (h *FundHandler) respondFundActions(c *gin.Context, code string) {
+	actions, err := h.fundService.GetCorporateActions(code)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
@@ -441,22 +632,48 @@ func (h *FundHandler) UpdatePosition(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
-		Data:    position,
+		Data:    actions,
 	})
 }
 
-// DeletePosition 删除持仓
-func (h *FundHandler) DeletePosition(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
+// RefreshFundMetadata 重新抓取基金基础资料并返回基金详情
+func (h *FundHandler) RefreshFundMetadata(c *gin.Context) {
+	code := c.Param("code")
+	if _, err := h.fundService.GetFundByCode(code); err != nil {
+		respondEntityError(c, err, "fund not found")
+		return
+	}
+	if err := h.fundService.RefreshFundMetadata(code); err != nil {
+		c.JSON(http.StatusBadGateway, Response{
+			Code:    502,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.GetFund(c)
+}
+
+// AddFundRequest 添加基金请求
+type AddFundRequest struct {
+	Code   string `json:"code" binding:"required"`
+	Name   string `json:"name"`
+	Sector string `json:"sector"`
+}
+
+// AddFund 添加基金订阅
+func (h *FundHandler) AddFund(c *gin.Context) {
+	var req AddFundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, Response{
 			Code:    400,
-			Message: "invalid position id",
+			Message: err.Error(),
 		})
 		return
 	}
 
-	if err := h.fundService.DeletePosition(id); err != nil {
+	fund, err := h.fundService.AddFund(req.Code, req.Name, req.Sector)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
 			Message: err.Error(),
@@ -467,13 +684,14 @@ func (h *FundHandler) DeletePosition(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
+		Data:    fund,
 	})
 }
 
-// GetAssets 获取资产统计
-func (h *FundHandler) GetAssets(c *gin.Context) {
-	assets, err := h.fundService.GetAssetStats()
-	if err != nil {
+// RemoveFund 取消基金订阅
+func (h *FundHandler) RemoveFund(c *gin.Context) {
+	code := c.Param("code")
+	if err := h.fundService.RemoveFund(code); err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
 			Message: err.Error(),
@@ -484,13 +702,1204 @@ func (h *FundHandler) GetAssets(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
-		Data:    assets,
 	})
 }
 
-// GetAssetSummary 获取资产摘要
+// UpdateFundRequest 更新基金请求
+// Market（cn、us、hk）、Currency（CNY、USD、HKD）、NavLag 任一非空时更新基金的市场设置，未填写的项保持不变；
+// FundType 非空时更新基金类型，类型含“货币”的按货币基金的每日收益估值；
+// ExchangeSymbol 非空时更新场内交易代码（如 sh510300），空串表示不在场内交易
+type UpdateFundRequest struct {
+	Name           string  `json:"name"`
+	Sector         string  `json:"sector"`
+	Market         string  `json:"market"`
+	Currency       string  `json:"currency"`
+	NavLag         *int    `json:"nav_lag"`
+	FundType       *string `json:"fund_type"`
+	ExchangeSymbol *string `json:"exchange_symbol"`
+}
+
+// UpdateFund 更新基金信息
+func (h *FundHandler) UpdateFund(c *gin.Context) {
+	code := c.Param("code")
+	var req UpdateFundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	fund, err := h.fundService.UpdateFund(code, req.Name, req.Sector)
+	if err == nil && (req.Market != "" || req.Currency != "" || req.NavLag != nil) {
+		market, currency, navLag := fund.Market, fund.Currency, fund.NavLag
+		if req.Market != "" {
+			market = strings.ToLower(req.Market)
+		}
+		if req.Currency != "" {
+			currency = strings.ToUpper(req.Currency)
+		}
+		if req.NavLag != nil {
+			navLag = *req.NavLag
+		}
+		fund, err = h.fundService.UpdateFundMarket(code, market, currency, navLag)
+	}
+	if err == nil && req.FundType != nil {
+		fund, err = h.fundService.UpdateFundType(code, *req.FundType)
+	}
+	if err == nil && req.ExchangeSymbol != nil {
+		fund, err = h.fundService.UpdateExchangeSymbol(code, *req.ExchangeSymbol)
+	}
+	if err != nil {
+		respondEntityError(c, err, "fund not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    fund,
+	})
+}
+
+// GetSectors 获取板块列表
+func (h *FundHandler) GetSectors(c *gin.Context) {
+	sectors, err := h.fundService.GetAllSectors()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    sectors,
+	})
+}
+
+// CreateSectorRequest 创建板块请求
+type CreateSectorRequest struct {
+	Name      string `json:"name" binding:"required"`
+	Color     string `json:"color"`
+	SortOrder int    `json:"sort_order"`
+}
+
+// CreateSector 创建板块
+func (h *FundHandler) CreateSector(c *gin.Context) {
+	var req CreateSectorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	sector, err := h.fundService.CreateSector(req.Name, req.Color, req.SortOrder)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    sector,
+	})
+}
+
+// UpdateSectorRequest 更新板块请求
+type UpdateSectorRequest struct {
+	Name      string `json:"name"`
+	Color     string `json:"color"`
+	SortOrder int    `json:"sort_order"`
+}
+
+// UpdateSector 更新板块
+func (h *FundHandler) UpdateSector(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid sector id",
+		})
+		return
+	}
+
+	var req UpdateSectorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	sector, err := h.fundService.UpdateSector(id, req.Name, req.Color, req.SortOrder)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    sector,
+	})
+}
+
+// DeleteSector 删除板块
+func (h *FundHandler) DeleteSector(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid sector id",
+		})
+		return
+	}
+
+	if err := h.fundService.DeleteSector(id); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+// GetPortfolios 获取组合列表
+func (h *FundHandler) GetPortfolios(c *gin.Context) {
+	portfolios, err := h.fundService.GetPortfolios()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    portfolios,
+	})
+}
+
+// PortfolioRequest 创建或更新组合请求
+type PortfolioRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	SortOrder   int    `json:"sort_order"`
+}
+
+// CreatePortfolio 创建组合
+func (h *FundHandler) CreatePortfolio(c *gin.Context) {
+	var req PortfolioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	portfolio, err := h.fundService.CreatePortfolio(&models.Portfolio{
+		Name:        req.Name,
+		Description: req.Description,
+		SortOrder:   req.SortOrder,
+	})
+	if respondEntityError(c, err, "portfolio not found") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    portfolio,
+	})
+}
+
+// UpdatePortfolio 更新组合
+func (h *FundHandler) UpdatePortfolio(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid portfolio id",
+		})
+		return
+	}
+
+	var req PortfolioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	portfolio, err := h.fundService.UpdatePortfolio(&models.Portfolio{
+		ID:          id,
+		Name:        req.Name,
+		Description: req.Description,
+		SortOrder:   req.SortOrder,
+	})
+	if respondEntityError(c, err, "portfolio not found") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    portfolio,
+	})
+}
+
+// DeletePortfolio 删除组合，组合下仍有账户时拒绝
+func (h *FundHandler) DeletePortfolio(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid portfolio id",
+		})
+		return
+	}
+
+	if respondEntityError(c, h.fundService.DeletePortfolio(id), "portfolio not found") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+// GetAccounts 获取账户列表，可按 portfolio_id 过滤
+func (h *FundHandler) GetAccounts(c *gin.Context) {
+	filter, err := parseAssetFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	accounts, err := h.fundService.GetAccounts(filter.PortfolioID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    accounts,
+	})
+}
+
+// AccountRequest 创建或更新账户请求，portfolio_id 缺省为默认组合
+type AccountRequest struct {
+	PortfolioID int64  `json:"portfolio_id"`
+	Name        string `json:"name" binding:"required"`
+	Platform    string `json:"platform"`
+	Owner       string `json:"owner"`
+	Note        string `json:"note"`
+	SortOrder   int    `json:"sort_order"`
+}
+
+// toAccount 转换为账户模型
+func (req AccountRequest) toAccount(id int64) *models.Account {
+	return &models.Account{
+		ID:          id,
+		PortfolioID: req.PortfolioID,
+		Name:        req.Name,
+		Platform:    req.Platform,
+		Owner:       req.Owner,
+		Note:        req.Note,
+		SortOrder:   req.SortOrder,
+	}
+}
+
+// CreateAccount 创建账户
+func (h *FundHandler) CreateAccount(c *gin.Context) {
+	var req AccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	account, err := h.fundService.CreateAccount(req.toAccount(0))
+	if respondEntityError(c, err, "account not found") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    account,
+	})
+}
+
+// UpdateAccount 更新账户
+func (h *FundHandler) UpdateAccount(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid account id",
+		})
+		return
+	}
+
+	var req AccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	account, err := h.fundService.UpdateAccount(req.toAccount(id))
+	if respondEntityError(c, err, "account not found") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    account,
+	})
+}
+
+// DeleteAccount 删除账户，账户下仍有交易流水时拒绝
+func (h *FundHandler) DeleteAccount(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid account id",
+		})
+		return
+	}
+
+	if respondEntityError(c, h.fundService.DeleteAccount(id), "account not found") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+// respondEntityError 输出服务层错误，返回是否已响应：记录不存在为 404，参数不合法为 400，数据不足为 404，其余为 500
+func respondEntityError(c *gin.Context, err error, notFound string) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: notFound,
+		})
+	case errors.Is(err, services.ErrInvalidInput), errors.Is(err, services.ErrInvalidTransaction),
+		errors.Is(err, services.ErrInUse):
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrInsufficientData):
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+	}
+	return true
+}
+
+// GetPositions 获取持仓列表
+func (h *FundHandler) GetPositions(c *gin.Context) {
+	filter, err := parseAssetFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	positions, err := h.fundService.FilterPositions(filter, c.Query("closed") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    positions,
+	})
+}
+
+// AddPositionRequest 添加持仓请求
+type AddPositionRequest struct {
+	AccountID int64   `json:"account_id"`
+	FundCode  string  `json:"fund_code" binding:"required"`
+	FundName  string  `json:"fund_name"`
+	Shares    float64 `json:"shares" binding:"required"`
+	Cost      float64 `json:"cost" binding:"required"`
+	Sector    string  `json:"sector"`
+}
+
+// AddPosition 添加持仓
+func (h *FundHandler) AddPosition(c *gin.Context) {
+	var req AddPositionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	position, err := h.fundService.AddPosition(req.AccountID, req.FundCode, req.FundName, req.Shares, req.Cost,
+		req.Sector)
+	if respondEntityError(c, err, "") {
+		return
+	}
+	position = h.revaluePosition(position)
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    position,
+	})
+}
+
+// UpdatePositionRequest 更新持仓请求
+// DividendMode 为 cash（现金分红）或 reinvest（红利再投资），为空时不变
+type UpdatePositionRequest struct {
+	Shares       float64 `json:"shares"`
+	Cost         float64 `json:"cost"`
+	Sector       string  `json:"sector"`
+	DividendMode string  `json:"dividend_mode"`
+}
+
+// UpdatePosition 更新持仓
+func (h *FundHandler) UpdatePosition(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid position id",
+		})
+		return
+	}
+
+	var req UpdatePositionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	position, err := h.fundService.UpdatePosition(id, req.Shares, req.Cost, req.Sector, req.DividendMode)
+	if errors.Is(err, services.ErrPositionDerived) {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "持仓份额与成本由交易流水计算，请通过 /api/transactions 记录买入或卖出",
+		})
+		return
+	}
+	if respondEntityError(c, err, "position not found") {
+		return
+	}
+	position = h.revaluePosition(position)
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    position,
+	})
+}
+
+// GetPositionLots 获取持仓各批次的持有天数、赎回费率档与浮动盈亏
+func (h *FundHandler) GetPositionLots(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid position id",
+		})
+		return
+	}
+
+	lots, err := h.fundService.GetPositionLots(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: "position not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    lots,
+	})
+}
+
+// GetRedeemPreview 预览赎回费用，shares 缺省为全部持有份额（指定 lot_ids 时为所选批次的全部份额）
+// lot_ids 为逗号分隔的批次 ID，缺省按先进先出
+func (h *FundHandler) GetRedeemPreview(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid position id",
+		})
+		return
+	}
+
+	var shares float64
+	if value := c.Query("shares"); value != "" {
+		if shares, err = strconv.ParseFloat(value, 64); err != nil || shares < 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "invalid shares",
+			})
+			return
+		}
+	}
+
+	lotIDs, err := services.ParseLotIDs(c.Query("lot_ids"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	preview, err := h.fundService.PreviewRedemption(id, shares, lotIDs)
+	if respondEntityError(c, err, "position not found") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    preview,
+	})
+}
+
+// revaluePosition 立即重估持仓并返回最新数据，失败时返回原持仓
+func (h *FundHandler) revaluePosition(position *models.Position) *models.Position {
+	if err := h.estimateService.RevaluePosition(position.ID); err != nil {
+		return position
+	}
+	if updated, err := h.fundService.GetPositionByID(position.ID); err == nil {
+		return updated
+	}
+	return position
+}
+
+// DeletePosition 删除没有交易流水的持仓
+func (h *FundHandler) DeletePosition(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid position id",
+		})
+		return
+	}
+
+	if respondEntityError(c, h.fundService.DeletePosition(id), "") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+// GetTransactions 获取交易流水，支持 fund_code、account_id、portfolio_id、type、status、from、to 过滤
+func (h *FundHandler) GetTransactions(c *gin.Context) {
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	assetFilter, err := parseAssetFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	filter := services.TransactionFilter{
+		AccountID:   assetFilter.AccountID,
+		PortfolioID: assetFilter.PortfolioID,
+		FundCode:    c.Query("fund_code"),
+		Type:        c.Query("type"),
+		Status:      c.Query("status"),
+		From:        from,
+		To:          to,
+	}
+
+	transactions, err := h.fundService.GetTransactions(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    transactions,
+	})
+}
+
+// AddTransactionRequest 记录交易请求
+type AddTransactionRequest struct {
+	AccountID int64    `json:"account_id"`
+	FundCode  string   `json:"fund_code" binding:"required"`
+	FundName  string   `json:"fund_name"`
+	Type      string   `json:"type" binding:"required"`
+	TradeDate string   `json:"trade_date"`
+	Shares    float64  `json:"shares"`
+	Price     float64  `json:"price"`
+	Amount    float64  `json:"amount"`
+	Fee       *float64 `json:"fee"`
+	LotIDs    []int64  `json:"lot_ids"`
+	Note      string   `json:"note"`
+	Sector    string   `json:"sector"`
+}
+
+// AddTransaction 记录交易，返回重新推导后的持仓
+func (h *FundHandler) AddTransaction(c *gin.Context) {
+	var req AddTransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var tradeDate time.Time
+	if req.TradeDate != "" {
+		var err error
+		if tradeDate, err = time.ParseInLocation(calendar.DateLayout, req.TradeDate, calendar.Location); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: fmt.Sprintf("invalid trade date %q", req.TradeDate),
+			})
+			return
+		}
+	}
+
+	position, err := h.fundService.RecordTransaction(services.TransactionInput{
+		AccountID: req.AccountID,
+		FundCode:  req.FundCode,
+		FundName:  req.FundName,
+		Type:      req.Type,
+		TradeDate: tradeDate,
+		Shares:    req.Shares,
+		Price:     req.Price,
+		Amount:    req.Amount,
+		Fee:       req.Fee,
+		LotIDs:    req.LotIDs,
+		Note:      req.Note,
+		Sector:    req.Sector,
+	})
+	if respondEntityError(c, err, "") {
+		return
+	}
+	position = h.revaluePosition(position)
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    position,
+	})
+}
+
+// PlaceBuyOrderRequest 按金额申购请求，order_time 缺省为当前时间
+type PlaceBuyOrderRequest struct {
+	AccountID int64    `json:"account_id"`
+	FundCode  string   `json:"fund_code" binding:"required"`
+	FundName  string   `json:"fund_name"`
+	Amount    float64  `json:"amount" binding:"required"`
+	FeeRate   *float64 `json:"fee_rate"`
+	OrderTime string   `json:"order_time"`
+	Note      string   `json:"note"`
+	Sector    string   `json:"sector"`
+}
+
+// PlaceBuyOrder 按金额申购，份额待对应净值公布后自动确认
+func (h *FundHandler) PlaceBuyOrder(c *gin.Context) {
+	var req PlaceBuyOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var orderTime time.Time
+	if req.OrderTime != "" {
+		var err error
+		if orderTime, err = parseTime(req.OrderTime); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	position, err := h.fundService.PlaceBuyOrder(services.BuyOrderInput{
+		AccountID: req.AccountID,
+		FundCode:  req.FundCode,
+		FundName:  req.FundName,
+		Amount:    req.Amount,
+		FeeRate:   req.FeeRate,
+		OrderTime: orderTime,
+		Note:      req.Note,
+		Sector:    req.Sector,
+	})
+	if respondEntityError(c, err, "") {
+		return
+	}
+	position = h.revaluePosition(position)
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    position,
+	})
+}
+
+// DeleteTransaction 删除交易流水
+func (h *FundHandler) DeleteTransaction(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid transaction id",
+		})
+		return
+	}
+
+	if respondEntityError(c, h.fundService.DeleteTransaction(id), "transaction not found") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+// GetAssets 获取资产统计，支持 account_id、portfolio_id 过滤，缺省为全部账户的合并统计
+func (h *FundHandler) GetAssets(c *gin.Context) {
+	filter, err := parseAssetFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	assets, err := h.fundService.GetAssetStats(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    assets,
+	})
+}
+
+// GetAssetSummary 获取资产摘要，过滤参数同 GetAssets，并附各组合与账户的汇总
 func (h *FundHandler) GetAssetSummary(c *gin.Context) {
-	summary, err := h.fundService.GetAssetSummary()
+	filter, err := parseAssetFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	summary, err := h.fundService.GetAssetSummary(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    summary,
+	})
+}
+
+// GetAssetHistory 获取资产走势
+// 查询参数：from、to（YYYY-MM-DD），granularity（day/week/month，默认 day），
+// scope（portfolio/sector/position，默认 portfolio），key（板块名称或基金代码）
+func (h *FundHandler) GetAssetHistory(c *gin.Context) {
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	history, err := h.fundService.GetAssetHistory(from, to,
+		c.DefaultQuery("granularity", services.GranularityDay), c.Query("scope"), c.Query("key"))
+	if respondEntityError(c, err, "") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    history,
+	})
+}
+
+// GetAssetPerformance 获取组合、板块与基金在各标准区间的时间加权收益率与 XIRR
+func (h *FundHandler) GetAssetPerformance(c *gin.Context) {
+	performance, err := h.fundService.GetPerformance()
+	if respondEntityError(c, err, "") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    performance,
+	})
+}
+
+// PlanRequest 创建或更新定投计划请求，日期格式为 2006-01-02
+type PlanRequest struct {
+	AccountID int64   `json:"account_id"`
+	FundCode  string  `json:"fund_code" binding:"required"`
+	FundName  string  `json:"fund_name"`
+	Amount    float64 `json:"amount" binding:"required"`
+	Frequency string  `json:"frequency" binding:"required"`
+	Day       int     `json:"day" binding:"required"`
+	StartDate string  `json:"start_date"`
+	EndDate   string  `json:"end_date"`
+	Status    string  `json:"status"`
+	Note      string  `json:"note"`
+}
+
+// toPlan 转换为定投计划模型
+func (req PlanRequest) toPlan(id int64) (*models.InvestmentPlan, error) {
+	plan := &models.InvestmentPlan{
+		ID:        id,
+		AccountID: req.AccountID,
+		FundCode:  req.FundCode,
+		FundName:  req.FundName,
+		Amount:    req.Amount,
+		Frequency: req.Frequency,
+		Day:       req.Day,
+		Status:    req.Status,
+		Note:      req.Note,
+	}
+	if req.StartDate != "" {
+		start, err := time.ParseInLocation(calendar.DateLayout, req.StartDate, calendar.Location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start date %q", req.StartDate)
+		}
+		plan.StartDate = start
+	}
+	if req.EndDate != "" {
+		end, err := time.ParseInLocation(calendar.DateLayout, req.EndDate, calendar.Location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end date %q", req.EndDate)
+		}
+		plan.EndDate = &end
+	}
+	return plan, nil
+}
+
+// GetPlans 获取定投计划及其累计扣款与收益
+func (h *FundHandler) GetPlans(c *gin.Context) {
+	summaries, err := h.fundService.GetPlanSummaries(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    summaries,
+	})
+}
+
+// GetUpcomingExecutions 列出未来 days 天（默认 30 天）内的定投扣款
+func (h *FundHandler) GetUpcomingExecutions(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days <= 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid days",
+		})
+		return
+	}
+
+	upcoming, err := h.fundService.GetUpcomingExecutions(time.Now(), days)
+	if respondEntityError(c, err, "") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    upcoming,
+	})
+}
+
+// CreatePlan 创建定投计划
+func (h *FundHandler) CreatePlan(c *gin.Context) {
+	var req PlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+	plan, err := req.toPlan(0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	plan, err = h.fundService.CreatePlan(plan)
+	if respondEntityError(c, err, "plan not found") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    plan,
+	})
+}
+
+// UpdatePlan 更新定投计划，包括暂停与恢复
+func (h *FundHandler) UpdatePlan(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid plan id",
+		})
+		return
+	}
+
+	var req PlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+	plan, err := req.toPlan(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	plan, err = h.fundService.UpdatePlan(plan)
+	if respondEntityError(c, err, "plan not found") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    plan,
+	})
+}
+
+// DeletePlan 删除定投计划，已生成的申购流水保留
+func (h *FundHandler) DeletePlan(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid plan id",
+		})
+		return
+	}
+
+	if respondEntityError(c, h.fundService.DeletePlan(id), "plan not found") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+// BacktestRequest 回测请求，日期格式为 2006-01-02，to 为空时回测至今
+type BacktestRequest struct {
+	Name        string                  `json:"name"`
+	Strategy    string                  `json:"strategy" binding:"required"`
+	Funds       []services.BacktestFund `json:"funds" binding:"required"`
+	From        string                  `json:"from" binding:"required"`
+	To          string                  `json:"to"`
+	Amount      float64                 `json:"amount" binding:"required"`
+	Frequency   string                  `json:"frequency"`
+	Day         int                     `json:"day"`
+	FeeRate     *float64                `json:"fee_rate"`
+	MADays      int                     `json:"ma_days"`
+	MaxMultiple float64                 `json:"max_multiple"`
+}
+
+// RunBacktest 按历史净值回测定投策略并保存结果
+func (h *FundHandler) RunBacktest(c *gin.Context) {
+	var req BacktestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	params := services.BacktestParams{
+		Name:        req.Name,
+		Strategy:    req.Strategy,
+		Funds:       req.Funds,
+		Amount:      req.Amount,
+		Frequency:   req.Frequency,
+		Day:         req.Day,
+		FeeRate:     req.FeeRate,
+		MADays:      req.MADays,
+		MaxMultiple: req.MaxMultiple,
+	}
+	var err error
+	if params.From, err = time.ParseInLocation(calendar.DateLayout, req.From, calendar.Location); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: fmt.Sprintf("invalid from date %q", req.From),
+		})
+		return
+	}
+	if req.To != "" {
+		if params.To, err = time.ParseInLocation(calendar.DateLayout, req.To, calendar.Location); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: fmt.Sprintf("invalid to date %q", req.To),
+			})
+			return
+		}
+	}
+
+	result, err := h.backtestService.RunBacktest(params)
+	if respondEntityError(c, err, "") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    result,
+	})
+}
+
+// GetBacktests 列出已保存的回测结果摘要，用于比较不同策略
+func (h *FundHandler) GetBacktests(c *gin.Context) {
+	results, err := h.backtestService.GetBacktests()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
@@ -502,7 +1911,142 @@ func (h *FundHandler) GetAssetSummary(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code:    0,
 		Message: "success",
-		Data:    summary,
+		Data:    results,
+	})
+}
+
+// GetBacktest 获取一次回测的完整结果，包括净值曲线与交易明细
+func (h *FundHandler) GetBacktest(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid backtest id",
+		})
+		return
+	}
+
+	result, err := h.backtestService.GetBacktest(id)
+	if respondEntityError(c, err, "backtest not found") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    result,
+	})
+}
+
+// DeleteBacktest 删除已保存的回测结果
+func (h *FundHandler) DeleteBacktest(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid backtest id",
+		})
+		return
+	}
+
+	if respondEntityError(c, h.backtestService.DeleteBacktest(id), "backtest not found") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+// AllocationTargetRequest 单个目标配置，scope 为 sector 或 fund
+type AllocationTargetRequest struct {
+	Scope        string  `json:"scope" binding:"required"`
+	ScopeKey     string  `json:"scope_key"`
+	TargetWeight float64 `json:"target_weight"`
+	Tolerance    float64 `json:"tolerance"`
+}
+
+// SaveAllocationTargetsRequest 保存目标配置请求，整体替换已有配置
+type SaveAllocationTargetsRequest struct {
+	Targets []AllocationTargetRequest `json:"targets"`
+}
+
+// GetAllocationTargets 获取板块与基金的目标配置
+func (h *FundHandler) GetAllocationTargets(c *gin.Context) {
+	targets, err := h.fundService.GetAllocationTargets()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    targets,
+	})
+}
+
+// SaveAllocationTargets 保存板块与基金的目标配置
+func (h *FundHandler) SaveAllocationTargets(c *gin.Context) {
+	var req SaveAllocationTargetsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	targets := make([]models.AllocationTarget, 0, len(req.Targets))
+	for _, target := range req.Targets {
+		targets = append(targets, models.AllocationTarget{
+			Scope:        target.Scope,
+			ScopeKey:     target.ScopeKey,
+			TargetWeight: target.TargetWeight,
+			Tolerance:    target.Tolerance,
+		})
+	}
+	if respondEntityError(c, h.fundService.SaveAllocationTargets(targets), "") {
+		return
+	}
+
+	h.GetAllocationTargets(c)
+}
+
+// GetRebalancePlan 按目标配置计算偏离与各基金的买卖金额，cash 为新增投入资金
+func (h *FundHandler) GetRebalancePlan(c *gin.Context) {
+	filter, err := parseAssetFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+	var cash float64
+	if value := c.Query("cash"); value != "" {
+		if cash, err = strconv.ParseFloat(value, 64); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: fmt.Sprintf("invalid cash %q", value),
+			})
+			return
+		}
+	}
+
+	plan, err := h.fundService.GetRebalancePlan(filter, cash)
+	if respondEntityError(c, err, "") {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    plan,
 	})
 }
 
@@ -572,3 +2116,102 @@ func (h *FundHandler) UpdateConfig(c *gin.Context) {
 		Message: "success",
 	})
 }
+
+// GetScraperStats 获取各数据源请求统计
+func (h *FundHandler) GetScraperStats(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    h.httpClient.Stats(),
+	})
+}
+
+// GetCalendar 查询交易日历，time 参数为 RFC3339 时间，缺省为当前时间
+// market 为 us、hk 时查询境外市场的休市日历，只返回是否交易日与前后交易日
+func (h *FundHandler) GetCalendar(c *gin.Context) {
+	at := time.Now()
+	if value := c.Query("time"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: fmt.Sprintf("invalid time %q", value),
+			})
+			return
+		}
+		at = parsed
+	}
+
+	market := c.DefaultQuery("market", calendar.MarketCN)
+	cal := calendar.Market(market)
+	if cal == nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: fmt.Sprintf("unknown market %q", market),
+		})
+		return
+	}
+
+	data := gin.H{
+		"market":           market,
+		"time":             at.In(cal.Location()),
+		"is_trading_day":   cal.IsTradingDay(at),
+		"prev_trading_day": cal.FormatDate(cal.PrevTradingDay(at)),
+		"next_trading_day": cal.FormatDate(cal.NextTradingDay(at)),
+		"holidays_through": cal.LastYear(),
+	}
+	if market == calendar.MarketCN {
+		data["is_trading_time"] = cal.IsTradingTime(at)
+		data["nav_date"] = cal.FormatDate(cal.NavDate(at))
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    data,
+	})
+}
+
+// GetFxRates 查询人民币汇率中间价，支持 currency、from、to 过滤
+func (h *FundHandler) GetFxRates(c *gin.Context) {
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	rates, err := h.fxService.GetRates(strings.ToUpper(c.Query("currency")), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    rates,
+	})
+}
+
+// RefreshFxRates 抓取最新一期人民币汇率中间价
+func (h *FundHandler) RefreshFxRates(c *gin.Context) {
+	count, err := h.fxService.RefreshRates()
+	if err != nil {
+		c.JSON(http.StatusBadGateway, Response{
+			Code:    502,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: "success",
+		Data:    gin.H{"updated": count},
+	})
+}
@@ -10,38 +10,336 @@ import (
 
 var db *sql.DB
 
+// Fund 基金，Category 至 Managers 为订阅时抓取并每周刷新的基础资料：
+// FundSize 为最新规模（元），RiskLevel 为 1（低）至 5（高）的风险等级，0 表示未知；Managers 仅在基金详情中返回
 type Fund struct {
-	ID            int64     `json:"id"`
-	Code          string    `json:"code"`
-	Name          string    `json:"name"`
-	Sector        string    `json:"sector"`
-	Nav           float64   `json:"nav"`
-	NavDate       time.Time `json:"nav_date"`
-	EstimateNav   float64   `json:"estimate_nav"`
-	EstimateTime  time.Time `json:"estimate_time"`
-	DailyGrowth   float64   `json:"daily_growth"`
-	Subscribed    bool      `json:"subscribed"`
-	SubscribeTime time.Time `json:"subscribe_time"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID                int64         `json:"id"`
+	Code              string        `json:"code"`
+	Name              string        `json:"name"`
+	Sector            string        `json:"sector"`
+	Nav               float64       `json:"nav"`
+	NavDate           time.Time     `json:"nav_date"`
+	EstimateNav       float64       `json:"estimate_nav"`
+	EstimateTime      time.Time     `json:"estimate_time"`
+	DailyGrowth       float64       `json:"daily_growth"`
+	NavSource         string        `json:"nav_source"`
+	EstimateSource    string        `json:"estimate_source"`
+	Subscribed        bool          `json:"subscribed"`
+	SubscribeTime     time.Time     `json:"subscribe_time"`
+	Market            string        `json:"market"`
+	Currency          string        `json:"currency"`
+	NavLag            int           `json:"nav_lag"`
+	FundType          string        `json:"fund_type"`
+	ExchangeSymbol    string        `json:"exchange_symbol"`
+	MarketPrice       float64       `json:"market_price"`
+	MarketPriceTime   time.Time     `json:"market_price_time"`
+	PremiumRate       float64       `json:"premium_rate"`
+	Category          string        `json:"category"`
+	ShareClass        string        `json:"share_class"`
+	Company           string        `json:"company"`
+	FundSize          float64       `json:"fund_size"`
+	SizeDate          *time.Time    `json:"size_date"`
+	InceptionDate     *time.Time    `json:"inception_date"`
+	Benchmark         string        `json:"benchmark"`
+	RiskLevel         int           `json:"risk_level"`
+	MetadataUpdatedAt *time.Time    `json:"metadata_updated_at"`
+	Managers          []FundManager `json:"managers,omitempty"`
+	CreatedAt         time.Time     `json:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at"`
 }
 
-type Position struct {
+// 基金类别，由数据源的基金类型归类
+const (
+	FundCategoryEquity = "equity"
+	FundCategoryHybrid = "hybrid"
+	FundCategoryBond   = "bond"
+	FundCategoryIndex  = "index"
+	FundCategoryQDII   = "qdii"
+	FundCategoryMoney  = "money"
+	FundCategoryFOF    = "fof"
+	FundCategoryOther  = "other"
+)
+
+// FundManager 现任基金经理，StartDate 为其连续管理该基金的起始日期，TenureDays 为截至当日的任职天数
+type FundManager struct {
+	ID         int64     `json:"id"`
+	FundCode   string    `json:"fund_code"`
+	Name       string    `json:"name"`
+	StartDate  time.Time `json:"start_date"`
+	TenureDays int       `json:"tenure_days"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// MoneyYield 货币基金每日收益，IncomePer10K 为每万份收益（元），Yield7D 为七日年化收益率（%）
+type MoneyYield struct {
 	ID           int64     `json:"id"`
 	FundCode     string    `json:"fund_code"`
-	FundName     string    `json:"fund_name"`
-	Shares       float64   `json:"shares"`
-	Cost         float64   `json:"cost"`
-	CostBasis    float64   `json:"cost_basis"`
-	CurrentValue float64   `json:"current_value"`
-	ProfitLoss   float64   `json:"profit_loss"`
-	ProfitRate   float64   `json:"profit_rate"`
-	DailyGrowth  float64   `json:"daily_growth"`
-	Sector       string    `json:"sector"`
+	YieldDate    time.Time `json:"yield_date"`
+	IncomePer10K float64   `json:"income_per_10k"`
+	Yield7D      float64   `json:"yield_7d"`
+	Source       string    `json:"source"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// 份额计价币种
+const (
+	CurrencyCNY = "CNY"
+	CurrencyUSD = "USD"
+	CurrencyHKD = "HKD"
+)
+
+// FxRate 人民币汇率中间价，Rate 为 1 单位外币折合的人民币
+type FxRate struct {
+	ID        int64     `json:"id"`
+	Currency  string    `json:"currency"`
+	RateDate  time.Time `json:"rate_date"`
+	Rate      float64   `json:"rate"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 默认组合与默认账户，未指定账户的持仓与交易归入默认账户
+const (
+	DefaultPortfolioID int64 = 1
+	DefaultAccountID   int64 = 1
+)
+
+type Portfolio struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	SortOrder   int       `json:"sort_order"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type Account struct {
+	ID          int64     `json:"id"`
+	PortfolioID int64     `json:"portfolio_id"`
+	Name        string    `json:"name"`
+	Platform    string    `json:"platform"`
+	Owner       string    `json:"owner"`
+	Note        string    `json:"note"`
+	SortOrder   int       `json:"sort_order"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// 目标配置的范围
+const (
+	AllocationSector = "sector"
+	AllocationFund   = "fund"
+)
+
+// AllocationTarget 板块或基金的目标权重（%），实际权重偏离超过 Tolerance 个百分点时需要再平衡
+type AllocationTarget struct {
+	ID           int64     `json:"id"`
+	Scope        string    `json:"scope"`
+	ScopeKey     string    `json:"scope_key"`
+	TargetWeight float64   `json:"target_weight"`
+	Tolerance    float64   `json:"tolerance"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// 定投频率
+const (
+	PlanWeekly   = "weekly"
+	PlanBiweekly = "biweekly"
+	PlanMonthly  = "monthly"
+)
+
+// 定投计划状态
+const (
+	PlanActive = "active"
+	PlanPaused = "paused"
+)
+
+// InvestmentPlan 定投计划
+// 按周、双周定投时 Day 为星期几（1-7，周一为 1），按月定投时为每月几号（1-28）；
+// 扣款日不是交易日时顺延至下一个交易日，EndDate 为空表示长期定投
+type InvestmentPlan struct {
+	ID          int64      `json:"id"`
+	AccountID   int64      `json:"account_id"`
+	FundCode    string     `json:"fund_code"`
+	FundName    string     `json:"fund_name"`
+	Amount      float64    `json:"amount"`
+	Frequency   string     `json:"frequency"`
+	Day         int        `json:"day"`
+	StartDate   time.Time  `json:"start_date"`
+	EndDate     *time.Time `json:"end_date"`
+	Status      string     `json:"status"`
+	Note        string     `json:"note"`
+	LastRunDate *time.Time `json:"last_run_date"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// PlanExecution 定投计划某一扣款日生成的申购
+type PlanExecution struct {
+	ID            int64     `json:"id"`
+	PlanID        int64     `json:"plan_id"`
+	ExecuteDate   time.Time `json:"execute_date"`
+	TransactionID int64     `json:"transaction_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Backtest 已保存的回测结果，Params 与 Result 为 JSON 文本
+type Backtest struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	Strategy      string    `json:"strategy"`
+	Params        string    `json:"params"`
+	Result        string    `json:"result"`
+	TotalInvested float64   `json:"total_invested"`
+	FinalValue    float64   `json:"final_value"`
+	ReturnRate    float64   `json:"return_rate"`
+	XIRR          *float64  `json:"xirr"`
+	MaxDrawdown   float64   `json:"max_drawdown"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type Position struct {
+	ID             int64     `json:"id"`
+	AccountID      int64     `json:"account_id"`
+	FundCode       string    `json:"fund_code"`
+	FundName       string    `json:"fund_name"`
+	Shares         float64   `json:"shares"`
+	Cost           float64   `json:"cost"`
+	CostBasis      float64   `json:"cost_basis"`
+	CurrentValue   float64   `json:"current_value"`
+	ProfitLoss     float64   `json:"profit_loss"`
+	ProfitRate     float64   `json:"profit_rate"`
+	RealizedProfit float64   `json:"realized_profit"`
+	PendingAmount  float64   `json:"pending_amount"`
+	Status         string    `json:"status"`
+	DailyGrowth    float64   `json:"daily_growth"`
+	DailyProfit    float64   `json:"daily_profit"`
+	ValuationNav   float64   `json:"valuation_nav"`
+	ValuationBasis string    `json:"valuation_basis"`
+	ValuedAt       time.Time `json:"valued_at"`
+	Sector         string    `json:"sector"`
+	DividendMode   string    `json:"dividend_mode"`
+	Currency       string    `json:"currency"`
+	// 外币份额尚无人民币汇率中间价时，汇率与各人民币金额为空
+	FxRate         *float64  `json:"fx_rate"`
+	CostBasisCNY   *float64  `json:"cost_basis_cny"`
+	ValueCNY       *float64  `json:"current_value_cny"`
+	ProfitLossCNY  *float64  `json:"profit_loss_cny"`
+	DailyProfitCNY *float64  `json:"daily_profit_cny"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// 分红方式：现金分红或红利再投资
+const (
+	DividendModeCash     = "cash"
+	DividendModeReinvest = "reinvest"
+)
+
+// 交易类型
+const (
+	TransactionBuy              = "buy"
+	TransactionSell             = "sell"
+	TransactionDividendCash     = "dividend_cash"
+	TransactionDividendReinvest = "dividend_reinvest"
+	TransactionFee              = "fee"
+	TransactionTransferIn       = "transfer_in"
+	TransactionSplit            = "split"
+	TransactionIncome           = "income"
+)
+
+// 交易状态：按金额申购的订单在净值公布前为待确认
+const (
+	TransactionPending   = "pending"
+	TransactionConfirmed = "confirmed"
+)
+
+// 持仓状态
+const (
+	PositionHolding = "holding"
+	PositionPending = "pending"
+	PositionClosed  = "closed"
+)
+
+// 公司行为类型
+const (
+	ActionDividend = "dividend"
+	ActionSplit    = "split"
+)
+
+// CorporateAction 基金分红或份额拆分
+// 分红时 CashPerShare 为每份派现金额，拆分时 SplitRatio 为拆分后每份对应的份额
+type CorporateAction struct {
+	ID           int64      `json:"id"`
+	FundCode     string     `json:"fund_code"`
+	Type         string     `json:"type"`
+	RecordDate   time.Time  `json:"record_date"`
+	ExDate       time.Time  `json:"ex_date"`
+	PayDate      *time.Time `json:"pay_date"`
+	CashPerShare float64    `json:"cash_per_share"`
+	SplitRatio   float64    `json:"split_ratio"`
+	Source       string     `json:"source"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type Transaction struct {
+	ID             int64     `json:"id"`
+	AccountID      int64     `json:"account_id"`
+	FundCode       string    `json:"fund_code"`
+	Type           string    `json:"type"`
+	Status         string    `json:"status"`
+	OrderTime      time.Time `json:"order_time"`
+	TradeDate      time.Time `json:"trade_date"`
+	Shares         float64   `json:"shares"`
+	Price          float64   `json:"price"`
+	Amount         float64   `json:"amount"`
+	Fee            float64   `json:"fee"`
+	FeeRate        float64   `json:"fee_rate"`
+	LotIDs         []int64   `json:"lot_ids,omitempty"`
+	RealizedProfit float64   `json:"realized_profit"`
+	Note           string    `json:"note"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// 资产快照范围
+const (
+	SnapshotPortfolio = "portfolio"
+	SnapshotSector    = "sector"
+	SnapshotPosition  = "position"
+)
+
+type AssetSnapshot struct {
+	ID               int64     `json:"id"`
+	SnapshotDate     time.Time `json:"snapshot_date"`
+	Scope            string    `json:"scope"`
+	ScopeKey         string    `json:"scope_key"`
+	Value            float64   `json:"value"`
+	CostBasis        float64   `json:"cost_basis"`
+	DailyProfit      float64   `json:"daily_profit"`
+	RealizedProfit   float64   `json:"realized_profit"`
+	CumulativeProfit float64   `json:"cumulative_profit"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type FeeSchedule struct {
+	FundCode        string           `json:"fund_code"`
+	PurchaseRate    float64          `json:"purchase_rate"`
+	ManagementRate  float64          `json:"management_rate"`
+	CustodyRate     float64          `json:"custody_rate"`
+	ServiceRate     float64          `json:"service_rate"`
+	RedemptionTiers []RedemptionTier `json:"redemption_tiers"`
+	Source          string           `json:"source"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+}
+
+type RedemptionTier struct {
+	MinDays int     `json:"min_days"`
+	MaxDays int     `json:"max_days"`
+	Rate    float64 `json:"rate"`
+}
+
 type Sector struct {
 	ID        int64     `json:"id"`
 	Name      string    `json:"name"`
@@ -60,6 +358,56 @@ type EstimateHistory struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+type NavHistory struct {
+	ID          int64     `json:"id"`
+	FundCode    string    `json:"fund_code"`
+	NavDate     time.Time `json:"nav_date"`
+	Nav         float64   `json:"nav"`
+	AccNav      float64   `json:"acc_nav"`
+	DailyGrowth float64   `json:"daily_growth"`
+	Source      string    `json:"source"`
+	AdjNav      float64   `json:"adj_nav,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type IndexHistory struct {
+	ID        int64     `json:"id"`
+	Symbol    string    `json:"symbol"`
+	TradeDate time.Time `json:"trade_date"`
+	Close     float64   `json:"close"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type FundHolding struct {
+	ID         int64     `json:"id"`
+	FundCode   string    `json:"fund_code"`
+	ReportDate time.Time `json:"report_date"`
+	Symbol     string    `json:"symbol"`
+	StockCode  string    `json:"stock_code"`
+	StockName  string    `json:"stock_name"`
+	Exchange   string    `json:"exchange"`
+	Weight     float64   `json:"weight"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type EstimateSnapshot struct {
+	ID             int64     `json:"id"`
+	FundCode       string    `json:"fund_code"`
+	TradeDate      time.Time `json:"trade_date"`
+	Source         string    `json:"source"`
+	EstimateNav    float64   `json:"estimate_nav"`
+	EstimateGrowth float64   `json:"estimate_growth"`
+	EstimateTime   time.Time `json:"estimate_time"`
+	ActualNav      float64   `json:"actual_nav"`
+	ActualGrowth   float64   `json:"actual_growth"`
+	GrowthError    float64   `json:"growth_error"`
+	NavError       float64   `json:"nav_error"`
+	Settled        bool      `json:"settled"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
 func InitDB(path string) error {
 	var err error
 	db, err = sql.Open("sqlite3", path)
@@ -75,22 +423,9 @@ func InitDB(path string) error {
 }
 
 func createTables() error {
-	// 先删除旧表（如果存在），重新创建以应用新的 DATETIME 类型
-	dropTables := []string{
-		"DROP TABLE IF EXISTS funds",
-		"DROP TABLE IF EXISTS positions",
-		"DROP TABLE IF EXISTS sectors",
-		"DROP TABLE IF EXISTS estimate_history",
-		"DROP TABLE IF EXISTS config",
-	}
-	for _, stmt := range dropTables {
-		if _, err := db.Exec(stmt); err != nil {
-			return err
-		}
-	}
-
+	// 各表不存在时才创建，保留已有数据；建表之后新增的列由 migrateColumns 补齐
 	tables := []string{
-		`CREATE TABLE funds (
+		`CREATE TABLE IF NOT EXISTS funds (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			code TEXT UNIQUE NOT NULL,
 			name TEXT,
@@ -100,13 +435,53 @@ func createTables() error {
 			estimate_nav REAL DEFAULT 0,
 			estimate_time DATETIME,
 			daily_growth REAL DEFAULT 0,
+			nav_source TEXT DEFAULT '',
+			estimate_source TEXT DEFAULT '',
 			subscribed INTEGER DEFAULT 0,
 			subscribe_time DATETIME,
+			market TEXT DEFAULT 'cn',
+			currency TEXT DEFAULT 'CNY',
+			nav_lag INTEGER DEFAULT 0,
+			fund_type TEXT DEFAULT '',
+			exchange_symbol TEXT DEFAULT '',
+			market_price REAL DEFAULT 0,
+			market_price_time DATETIME,
+			premium_rate REAL DEFAULT 0,
+			category TEXT DEFAULT '',
+			share_class TEXT DEFAULT '',
+			company TEXT DEFAULT '',
+			fund_size REAL DEFAULT 0,
+			size_date DATETIME,
+			inception_date DATETIME,
+			benchmark TEXT DEFAULT '',
+			risk_level INTEGER DEFAULT 0,
+			metadata_updated_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS portfolios (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT UNIQUE NOT NULL,
+			description TEXT DEFAULT '',
+			sort_order INTEGER DEFAULT 0,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
-		`CREATE TABLE positions (
+		`CREATE TABLE IF NOT EXISTS accounts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			portfolio_id INTEGER NOT NULL DEFAULT 1,
+			name TEXT NOT NULL,
+			platform TEXT DEFAULT '',
+			owner TEXT DEFAULT '',
+			note TEXT DEFAULT '',
+			sort_order INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (portfolio_id, name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS positions (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL DEFAULT 1,
 			fund_code TEXT NOT NULL,
 			fund_name TEXT,
 			shares REAL DEFAULT 0,
@@ -115,12 +490,175 @@ func createTables() error {
 			current_value REAL DEFAULT 0,
 			profit_loss REAL DEFAULT 0,
 			profit_rate REAL DEFAULT 0,
+			realized_profit REAL DEFAULT 0,
+			pending_amount REAL DEFAULT 0,
+			status TEXT DEFAULT 'holding',
 			daily_growth REAL DEFAULT 0,
+			daily_profit REAL DEFAULT 0,
+			valuation_nav REAL DEFAULT 0,
+			valuation_basis TEXT DEFAULT '',
+			valued_at DATETIME,
 			sector TEXT,
+			dividend_mode TEXT DEFAULT 'cash',
+			currency TEXT DEFAULT 'CNY',
+			fx_rate REAL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (account_id, fund_code)
+		)`,
+		`CREATE TABLE IF NOT EXISTS position_settings (
+			account_id INTEGER NOT NULL,
+			fund_code TEXT NOT NULL,
+			dividend_mode TEXT DEFAULT 'cash',
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (account_id, fund_code)
+		)`,
+		`CREATE TABLE IF NOT EXISTS transactions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL DEFAULT 1,
+			fund_code TEXT NOT NULL,
+			type TEXT NOT NULL,
+			status TEXT DEFAULT 'confirmed',
+			order_time DATETIME,
+			trade_date DATETIME NOT NULL,
+			shares REAL DEFAULT 0,
+			price REAL DEFAULT 0,
+			amount REAL DEFAULT 0,
+			fee REAL DEFAULT 0,
+			fee_rate REAL DEFAULT 0,
+			lot_ids TEXT DEFAULT '',
+			realized_profit REAL DEFAULT 0,
+			note TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_position ON transactions (account_id, fund_code, trade_date)`,
+		`CREATE TABLE IF NOT EXISTS fund_fees (
+			fund_code TEXT PRIMARY KEY,
+			purchase_rate REAL DEFAULT 0,
+			management_rate REAL DEFAULT 0,
+			custody_rate REAL DEFAULT 0,
+			service_rate REAL DEFAULT 0,
+			source TEXT DEFAULT '',
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS fund_redemption_fees (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			fund_code TEXT NOT NULL,
+			min_days INTEGER DEFAULT 0,
+			max_days INTEGER DEFAULT 0,
+			rate REAL DEFAULT 0,
+			UNIQUE (fund_code, min_days)
+		)`,
+		`CREATE TABLE IF NOT EXISTS corporate_actions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			fund_code TEXT NOT NULL,
+			type TEXT NOT NULL,
+			record_date DATETIME NOT NULL,
+			ex_date DATETIME NOT NULL,
+			pay_date DATETIME,
+			cash_per_share REAL DEFAULT 0,
+			split_ratio REAL DEFAULT 0,
+			source TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (fund_code, type, ex_date)
+		)`,
+		`CREATE TABLE IF NOT EXISTS applied_actions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			action_id INTEGER NOT NULL,
+			account_id INTEGER NOT NULL,
+			transaction_id INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (action_id, account_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS fx_rates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			currency TEXT NOT NULL,
+			rate_date DATETIME NOT NULL,
+			rate REAL NOT NULL,
+			source TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (currency, rate_date)
+		)`,
+		`CREATE TABLE IF NOT EXISTS fund_managers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			fund_code TEXT NOT NULL,
+			name TEXT NOT NULL,
+			start_date DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (fund_code, name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS money_yields (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			fund_code TEXT NOT NULL,
+			yield_date DATETIME NOT NULL,
+			income_per_10k REAL DEFAULT 0,
+			yield_7d REAL DEFAULT 0,
+			source TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (fund_code, yield_date)
+		)`,
+		`CREATE TABLE IF NOT EXISTS asset_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			snapshot_date DATETIME NOT NULL,
+			scope TEXT NOT NULL,
+			scope_key TEXT NOT NULL DEFAULT '',
+			value REAL DEFAULT 0,
+			cost_basis REAL DEFAULT 0,
+			daily_profit REAL DEFAULT 0,
+			realized_profit REAL DEFAULT 0,
+			cumulative_profit REAL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (snapshot_date, scope, scope_key)
+		)`,
+		`CREATE TABLE IF NOT EXISTS allocation_targets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			scope TEXT NOT NULL,
+			scope_key TEXT NOT NULL,
+			target_weight REAL DEFAULT 0,
+			tolerance REAL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (scope, scope_key)
+		)`,
+		`CREATE TABLE IF NOT EXISTS investment_plans (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL DEFAULT 1,
+			fund_code TEXT NOT NULL,
+			fund_name TEXT DEFAULT '',
+			amount REAL DEFAULT 0,
+			frequency TEXT NOT NULL,
+			day INTEGER DEFAULT 1,
+			start_date DATETIME NOT NULL,
+			end_date DATETIME,
+			status TEXT DEFAULT 'active',
+			note TEXT DEFAULT '',
+			last_run_date DATETIME,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
-		`CREATE TABLE sectors (
+		`CREATE TABLE IF NOT EXISTS plan_executions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			plan_id INTEGER NOT NULL,
+			execute_date DATETIME NOT NULL,
+			transaction_id INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (plan_id, execute_date)
+		)`,
+		`CREATE TABLE IF NOT EXISTS backtests (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT DEFAULT '',
+			strategy TEXT NOT NULL,
+			params TEXT DEFAULT '',
+			result TEXT DEFAULT '',
+			total_invested REAL DEFAULT 0,
+			final_value REAL DEFAULT 0,
+			return_rate REAL DEFAULT 0,
+			xirr REAL,
+			max_drawdown REAL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS sectors (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			name TEXT UNIQUE NOT NULL,
 			color TEXT DEFAULT '#1890ff',
@@ -128,7 +666,7 @@ func createTables() error {
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
-		`CREATE TABLE estimate_history (
+		`CREATE TABLE IF NOT EXISTS estimate_history (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			fund_code TEXT NOT NULL,
 			estimate_nav REAL DEFAULT 0,
@@ -136,7 +674,56 @@ func createTables() error {
 			recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
-		`CREATE TABLE config (
+		`CREATE TABLE IF NOT EXISTS nav_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			fund_code TEXT NOT NULL,
+			nav_date DATETIME NOT NULL,
+			nav REAL DEFAULT 0,
+			acc_nav REAL DEFAULT 0,
+			daily_growth REAL DEFAULT 0,
+			source TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (fund_code, nav_date)
+		)`,
+		`CREATE TABLE IF NOT EXISTS index_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			symbol TEXT NOT NULL,
+			trade_date DATETIME NOT NULL,
+			close REAL DEFAULT 0,
+			source TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (symbol, trade_date)
+		)`,
+		`CREATE TABLE IF NOT EXISTS fund_holdings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			fund_code TEXT NOT NULL,
+			report_date DATETIME NOT NULL,
+			symbol TEXT NOT NULL,
+			stock_code TEXT,
+			stock_name TEXT,
+			exchange TEXT,
+			weight REAL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (fund_code, report_date, symbol)
+		)`,
+		`CREATE TABLE IF NOT EXISTS estimate_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			fund_code TEXT NOT NULL,
+			trade_date DATETIME NOT NULL,
+			source TEXT NOT NULL,
+			estimate_nav REAL DEFAULT 0,
+			estimate_growth REAL DEFAULT 0,
+			estimate_time DATETIME,
+			actual_nav REAL DEFAULT 0,
+			actual_growth REAL DEFAULT 0,
+			growth_error REAL DEFAULT 0,
+			nav_error REAL DEFAULT 0,
+			settled INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (fund_code, trade_date, source)
+		)`,
+		`CREATE TABLE IF NOT EXISTS config (
 			key TEXT PRIMARY KEY,
 			value TEXT,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
@@ -149,6 +736,23 @@ func createTables() error {
 		}
 	}
 
+	if err := migrateColumns(); err != nil {
+		return err
+	}
+	// 早期的持仓表没有账户维度，补齐 account_id 后再建唯一索引
+	if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_positions_account_fund ON positions (account_id, fund_code)`); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`INSERT OR IGNORE INTO portfolios (id, name) VALUES (?, '默认组合')`,
+		DefaultPortfolioID); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`INSERT OR IGNORE INTO accounts (id, portfolio_id, name) VALUES (?, ?, '默认账户')`,
+		DefaultAccountID, DefaultPortfolioID); err != nil {
+		return err
+	}
+
 	defaultSectors := []string{
 		"科技", "医疗", "新能源", "QDII", "消费", "金融", "军工", "半导体", "互联网", "房地产",
 	}
@@ -161,6 +765,98 @@ func createTables() error {
 	return nil
 }
 
+// columnMigrations 建表之后新增的列，已有数据库中缺少的列在启动时补齐
+var columnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"funds", "nav_source", "TEXT DEFAULT ''"},
+	{"funds", "estimate_source", "TEXT DEFAULT ''"},
+	{"funds", "market", "TEXT DEFAULT 'cn'"},
+	{"funds", "currency", "TEXT DEFAULT 'CNY'"},
+	{"funds", "nav_lag", "INTEGER DEFAULT 0"},
+	{"funds", "fund_type", "TEXT DEFAULT ''"},
+	{"funds", "exchange_symbol", "TEXT DEFAULT ''"},
+	{"funds", "market_price", "REAL DEFAULT 0"},
+	{"funds", "market_price_time", "DATETIME"},
+	{"funds", "premium_rate", "REAL DEFAULT 0"},
+	{"funds", "category", "TEXT DEFAULT ''"},
+	{"funds", "share_class", "TEXT DEFAULT ''"},
+	{"funds", "company", "TEXT DEFAULT ''"},
+	{"funds", "fund_size", "REAL DEFAULT 0"},
+	{"funds", "size_date", "DATETIME"},
+	{"funds", "inception_date", "DATETIME"},
+	{"funds", "benchmark", "TEXT DEFAULT ''"},
+	{"funds", "risk_level", "INTEGER DEFAULT 0"},
+	{"funds", "metadata_updated_at", "DATETIME"},
+	{"positions", "account_id", "INTEGER NOT NULL DEFAULT 1"},
+	{"positions", "realized_profit", "REAL DEFAULT 0"},
+	{"positions", "pending_amount", "REAL DEFAULT 0"},
+	{"positions", "status", "TEXT DEFAULT 'holding'"},
+	{"positions", "daily_profit", "REAL DEFAULT 0"},
+	{"positions", "valuation_nav", "REAL DEFAULT 0"},
+	{"positions", "valuation_basis", "TEXT DEFAULT ''"},
+	{"positions", "valued_at", "DATETIME"},
+	{"positions", "dividend_mode", "TEXT DEFAULT 'cash'"},
+	{"positions", "currency", "TEXT DEFAULT 'CNY'"},
+	{"positions", "fx_rate", "REAL"},
+	{"transactions", "status", "TEXT DEFAULT 'confirmed'"},
+	{"transactions", "order_time", "DATETIME"},
+	{"transactions", "fee_rate", "REAL DEFAULT 0"},
+	{"transactions", "lot_ids", "TEXT DEFAULT ''"},
+}
+
+// migrateColumns 按 PRAGMA table_info 检查已有表，为缺少的列执行 ALTER TABLE ADD COLUMN
+func migrateColumns() error {
+	existing := make(map[string]map[string]bool)
+	for _, m := range columnMigrations {
+		columns, ok := existing[m.table]
+		if !ok {
+			var err error
+			if columns, err = tableColumns(m.table); err != nil {
+				return err
+			}
+			existing[m.table] = columns
+		}
+		if columns[m.column] {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", m.table, m.column, err)
+		}
+		columns[m.column] = true
+	}
+	return nil
+}
+
+// tableColumns 返回表中已有的列名
+func tableColumns(table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
 func CloseDB() {
 	if db != nil {
 		db.Close()
@@ -0,0 +1,89 @@
+package scrapers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"fundnet/backend/internal/config"
+)
+
+// DefaultChinamoneyBaseURL 中国货币网默认地址
+const DefaultChinamoneyBaseURL = "https://www.chinamoney.com.cn"
+
+// ccprPayload 人民币汇率中间价接口原始数据，价格为字符串
+type ccprPayload struct {
+	Data struct {
+		LastDate string `json:"lastDate"`
+	} `json:"data"`
+	Records []struct {
+		Pair  string `json:"vrtEName"`
+		Price string `json:"price"`
+	} `json:"records"`
+}
+
+// ChinamoneyScraper 中国货币网爬虫，提供人民币汇率中间价
+type ChinamoneyScraper struct {
+	baseURL string
+	client  *Client
+}
+
+// NewChinamoneyScraper 创建中国货币网爬虫
+func NewChinamoneyScraper(cfg config.ScraperConfig, client *Client) *ChinamoneyScraper {
+	return &ChinamoneyScraper{
+		baseURL: baseURL(cfg.ChinamoneyBaseURL, DefaultChinamoneyBaseURL),
+		client:  client,
+	}
+}
+
+// Name 数据源名称
+func (s *ChinamoneyScraper) Name() string {
+	return "chinamoney"
+}
+
+// FetchFxRates 获取最新一期人民币汇率中间价（每个交易日 9:15 公布），只返回“外币/CNY”报价
+func (s *ChinamoneyScraper) FetchFxRates() ([]FxRate, error) {
+	url := s.baseURL + "/r/cms/www/chinamoney/data/fx/ccpr.json"
+	body, err := s.client.Get(s.Name(), url, s.baseURL+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	rates, err := parseCCPR(body)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rates {
+		rates[i].Source = s.Name()
+	}
+	return rates, nil
+}
+
+// parseCCPR 解析中间价接口，lastDate 形如“2024-05-10 9:15”，报价形如 {"vrtEName": "USD/CNY", "price": "7.1028"}
+func parseCCPR(body []byte) ([]FxRate, error) {
+	var payload ccprPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("ccpr: %w", err)
+	}
+	date := datePattern.FindString(payload.Data.LastDate)
+	if date == "" {
+		return nil, fmt.Errorf("ccpr: invalid date %q", payload.Data.LastDate)
+	}
+
+	rates := make([]FxRate, 0, len(payload.Records))
+	for _, record := range payload.Records {
+		currency, quote, ok := strings.Cut(record.Pair, "/")
+		if !ok || quote != "CNY" || len(currency) != 3 {
+			continue
+		}
+		price, err := parseFloat(record.Price)
+		if err != nil || price <= 0 {
+			continue
+		}
+		rates = append(rates, FxRate{Currency: currency, RateDate: date, Rate: price})
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("ccpr: no rates found")
+	}
+	return rates, nil
+}
@@ -0,0 +1,223 @@
+package scrapers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"fundnet/backend/internal/config"
+)
+
+// 重试退避参数
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
+
+// StatusError 上游返回非 200 状态码
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("GET %s: unexpected status %d", e.URL, e.StatusCode)
+}
+
+// Attempt 单次请求尝试的结果
+type Attempt struct {
+	Source     string
+	URL        string
+	Attempt    int
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+	Retryable  bool
+}
+
+// SourceStats 数据源请求统计
+type SourceStats struct {
+	Attempts    int64     `json:"attempts"`
+	Successes   int64     `json:"successes"`
+	Failures    int64     `json:"failures"`
+	Retries     int64     `json:"retries"`
+	LastError   string    `json:"last_error"`
+	LastErrorAt time.Time `json:"last_error_at"`
+}
+
+// Client 爬虫共用的 HTTP 客户端，负责超时与重试
+type Client struct {
+	http       *http.Client
+	retryCount int
+
+	mu    sync.Mutex
+	stats map[string]*SourceStats
+	rand  *rand.Rand
+}
+
+// NewClient 根据爬虫配置创建 HTTP 客户端
+func NewClient(cfg config.ScraperConfig) *Client {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	retryCount := cfg.RetryCount
+	if retryCount < 0 {
+		retryCount = 0
+	}
+
+	return &Client{
+		http:       &http.Client{Timeout: timeout},
+		retryCount: retryCount,
+		stats:      make(map[string]*SourceStats),
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Get 发起 GET 请求并读取响应体，暂时性错误按指数退避加抖动重试
+func (c *Client) Get(source, url, referer string) ([]byte, error) {
+	var lastErr error
+	for attempt := 1; attempt <= c.retryCount+1; attempt++ {
+		if attempt > 1 {
+			time.Sleep(c.backoff(attempt - 1))
+		}
+
+		start := time.Now()
+		body, statusCode, err := c.do(url, referer)
+		result := Attempt{
+			Source:     source,
+			URL:        url,
+			Attempt:    attempt,
+			StatusCode: statusCode,
+			Duration:   time.Since(start),
+			Err:        err,
+			Retryable:  isRetryable(err),
+		}
+		c.record(result)
+
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+		if !result.Retryable {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// Stats 返回各数据源的请求统计快照
+func (c *Client) Stats() map[string]SourceStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := make(map[string]SourceStats, len(c.stats))
+	for source, stats := range c.stats {
+		snapshot[source] = *stats
+	}
+	return snapshot
+}
+
+// do 执行单次请求
+func (c *Client) do(url, referer string) ([]byte, int, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if referer != "" {
+		req.Header.Set("Referer", referer)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	return body, resp.StatusCode, err
+}
+
+// backoff 第 n 次重试前的等待时长：指数增长，在 [d/2, d) 区间内随机抖动
+func (c *Client) backoff(retry int) time.Duration {
+	delay := retryBaseDelay << (retry - 1)
+	if delay <= 0 || delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+
+	c.mu.Lock()
+	jitter := time.Duration(c.rand.Int63n(int64(delay / 2)))
+	c.mu.Unlock()
+
+	return delay/2 + jitter
+}
+
+// record 记录单次尝试结果
+func (c *Client) record(attempt Attempt) {
+	c.mu.Lock()
+	stats, ok := c.stats[attempt.Source]
+	if !ok {
+		stats = &SourceStats{}
+		c.stats[attempt.Source] = stats
+	}
+	stats.Attempts++
+	if attempt.Attempt > 1 {
+		stats.Retries++
+	}
+	if attempt.Err == nil {
+		stats.Successes++
+	} else {
+		stats.Failures++
+		stats.LastError = attempt.Err.Error()
+		stats.LastErrorAt = time.Now()
+	}
+	c.mu.Unlock()
+
+	if attempt.Err != nil {
+		log.Printf("[scraper] %s attempt %d/%d failed after %s (retryable=%t): %v",
+			attempt.Source, attempt.Attempt, c.retryCount+1, attempt.Duration.Round(time.Millisecond),
+			attempt.Retryable, attempt.Err)
+	}
+}
+
+// isRetryable 判断错误是否为暂时性错误：5xx、429、超时、连接重置
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// baseURL 返回去掉末尾斜杠的地址，未配置时使用默认值
+func baseURL(configured, fallback string) string {
+	if configured == "" {
+		configured = fallback
+	}
+	return strings.TrimRight(configured, "/")
+}
@@ -0,0 +1,108 @@
+package scrapers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+
+	"fundnet/backend/internal/config"
+)
+
+func TestClientGetRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryCount int
+		statuses   []int
+		wantErr    bool
+		wantCalls  int
+	}{
+		{name: "success", retryCount: 1, statuses: []int{200}, wantCalls: 1},
+		{name: "5xx then success", retryCount: 1, statuses: []int{503, 200}, wantCalls: 2},
+		{name: "429 then success", retryCount: 1, statuses: []int{429, 200}, wantCalls: 2},
+		{name: "retries exhausted", retryCount: 1, statuses: []int{500, 502}, wantErr: true, wantCalls: 2},
+		{name: "404 is not retried", retryCount: 1, statuses: []int{404, 200}, wantErr: true, wantCalls: 1},
+		{name: "no retries configured", retryCount: 0, statuses: []int{503, 200}, wantErr: true, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		calls := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			status := tt.statuses[calls]
+			calls++
+			w.WriteHeader(status)
+			fmt.Fprintf(w, "attempt %d", calls)
+		}))
+
+		client := NewClient(config.ScraperConfig{RetryCount: tt.retryCount, Timeout: 2})
+		body, err := client.Get("test", srv.URL, "")
+		srv.Close()
+
+		if calls != tt.wantCalls {
+			t.Errorf("%s: %d requests, want %d", tt.name, calls, tt.wantCalls)
+		}
+		if tt.wantErr {
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.statuses[calls-1] {
+				t.Errorf("%s: err = %v, want status %d", tt.name, err, tt.statuses[calls-1])
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if want := fmt.Sprintf("attempt %d", calls); string(body) != want {
+			t.Errorf("%s: body = %q, want %q", tt.name, body, want)
+		}
+
+		stats := client.Stats()["test"]
+		if stats.Attempts != int64(calls) || stats.Retries != int64(calls-1) || stats.Successes != 1 {
+			t.Errorf("%s: stats = %+v", tt.name, stats)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"500", &StatusError{StatusCode: 500}, true},
+		{"503", &StatusError{StatusCode: 503}, true},
+		{"429", &StatusError{StatusCode: 429}, true},
+		{"404", &StatusError{StatusCode: 404}, false},
+		{"403", &StatusError{StatusCode: 403}, false},
+		{"timeout", context.DeadlineExceeded, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"connection refused", fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"parse error", errors.New("invalid character '<'"), false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: isRetryable(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	client := NewClient(config.ScraperConfig{})
+	for retry := 1; retry <= 8; retry++ {
+		delay := retryBaseDelay << (retry - 1)
+		if delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+		for i := 0; i < 50; i++ {
+			got := client.backoff(retry)
+			if got < delay/2 || got >= delay {
+				t.Fatalf("backoff(%d) = %v, want in [%v, %v)", retry, got, delay/2, delay)
+			}
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package scrapers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/config"
+)
+
+// 天天基金各接口默认地址
+const (
+	DefaultFundgzBaseURL       = "http://fundgz.1234567.com.cn"
+	DefaultEastmoneyAPIBaseURL = "https://api.fund.eastmoney.com"
+	DefaultFundMobBaseURL      = "https://fundmobapi.eastmoney.com"
+	DefaultFundF10BaseURL      = "http://fundf10.eastmoney.com"
+)
+
+// eastmoneyReferer 净值接口要求的来源页
+const eastmoneyReferer = "http://fundf10.eastmoney.com/"
+
+// fundgzPayload fundgz 接口原始数据，数值均为字符串
+type fundgzPayload struct {
+	FundCode string `json:"fundcode"`
+	Name     string `json:"name"`
+	Jzrq     string `json:"jzrq"`
+	Dwjz     string `json:"dwjz"`
+	Gsz      string `json:"gsz"`
+	Gszzl    string `json:"gszzl"`
+	Gztime   string `json:"gztime"`
+}
+
+// lsjzPayload 历史净值接口原始数据
+type lsjzPayload struct {
+	Data struct {
+		LSJZList []struct {
+			FSRQ  string `json:"FSRQ"`
+			DWJZ  string `json:"DWJZ"`
+			LJJZ  string `json:"LJJZ"`
+			JZZZL string `json:"JZZZL"`
+		} `json:"LSJZList"`
+	} `json:"Data"`
+	ErrCode    int    `json:"ErrCode"`
+	ErrMsg     string `json:"ErrMsg"`
+	TotalCount int    `json:"TotalCount"`
+}
+
+// baseInfoPayload 基金基本信息接口原始数据
+type baseInfoPayload struct {
+	Datas struct {
+		FCode     string `json:"FCODE"`
+		ShortName string `json:"SHORTNAME"`
+		FType     string `json:"FTYPE"`
+		JJGS      string `json:"JJGS"`
+		EstabDate string `json:"ESTABDATE"`
+		EndNav    string `json:"ENDNAV"`
+		FEGMRQ    string `json:"FEGMRQ"`
+		Bench     string `json:"BENCH"`
+		RiskLevel string `json:"RISKLEVEL"`
+	} `json:"Datas"`
+	ErrCode int    `json:"ErrCode"`
+	ErrMsg  string `json:"ErrMsg"`
+}
+
+// positionPayload 基金持仓接口原始数据，Expansion 为报告期
+type positionPayload struct {
+	Datas struct {
+		FundStocks []struct {
+			GPDM     string `json:"GPDM"`
+			GPJC     string `json:"GPJC"`
+			JZBL     string `json:"JZBL"`
+			NEWTEXCH string `json:"NEWTEXCH"`
+		} `json:"fundStocks"`
+	} `json:"Datas"`
+	ErrCode   int    `json:"ErrCode"`
+	ErrMsg    string `json:"ErrMsg"`
+	Expansion string `json:"Expansion"`
+}
+
+// EastmoneyScraper 天天基金爬虫
+type EastmoneyScraper struct {
+	fundgzURL string
+	apiURL    string
+	mobileURL string
+	f10URL    string
+	client    *Client
+}
+
+// NewEastmoneyScraper 创建天天基金爬虫，未配置的地址使用默认值
+func NewEastmoneyScraper(cfg config.ScraperConfig, client *Client) *EastmoneyScraper {
+	return &EastmoneyScraper{
+		fundgzURL: baseURL(cfg.FundgzBaseURL, DefaultFundgzBaseURL),
+		apiURL:    baseURL(cfg.EastmoneyAPIBaseURL, DefaultEastmoneyAPIBaseURL),
+		mobileURL: baseURL(cfg.FundMobBaseURL, DefaultFundMobBaseURL),
+		f10URL:    baseURL(cfg.FundF10BaseURL, DefaultFundF10BaseURL),
+		client:    client,
+	}
+}
+
+// Name 数据源名称
+func (s *EastmoneyScraper) Name() string {
+	return "eastmoney"
+}
+
+// FetchEstimate 获取基金实时估值
+func (s *EastmoneyScraper) FetchEstimate(code string) (*FundEstimate, error) {
+	url := fmt.Sprintf("%s/js/%s.js?rt=%d", s.fundgzURL, code, time.Now().UnixMilli())
+	body, err := s.client.Get(s.Name(), url, "")
+	if err != nil {
+		return nil, err
+	}
+
+	estimate, err := parseFundgz(body)
+	if err != nil {
+		return nil, err
+	}
+	estimate.Source = s.Name()
+	return estimate, nil
+}
+
+// FetchNav 获取基金最新官方净值
+func (s *EastmoneyScraper) FetchNav(code string) (*FundNav, error) {
+	navs, _, err := s.FetchNavPage(code, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(navs) == 0 {
+		return nil, fmt.Errorf("lsjz %s: no nav published", code)
+	}
+	return &navs[0], nil
+}
+
+// FetchNavPage 分页获取基金历史净值，按日期倒序，返回当页数据与总条数
+func (s *EastmoneyScraper) FetchNavPage(code string, pageIndex, pageSize int) ([]FundNav, int, error) {
+	url := fmt.Sprintf("%s/f10/lsjz?fundCode=%s&pageIndex=%d&pageSize=%d",
+		s.apiURL, code, pageIndex, pageSize)
+	body, err := s.client.Get(s.Name(), url, eastmoneyReferer)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var payload lsjzPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, 0, fmt.Errorf("lsjz: %w", err)
+	}
+	if payload.ErrCode != 0 {
+		return nil, 0, fmt.Errorf("lsjz %s: %s", code, payload.ErrMsg)
+	}
+
+	navs := make([]FundNav, 0, len(payload.Data.LSJZList))
+	for _, item := range payload.Data.LSJZList {
+		nav := FundNav{Code: code, NavDate: item.FSRQ, Source: s.Name()}
+		if nav.Nav, err = parseFloat(item.DWJZ); err != nil {
+			return nil, 0, fmt.Errorf("lsjz: invalid DWJZ %q", item.DWJZ)
+		}
+		if nav.AccNav, err = parseFloat(item.LJJZ); err != nil {
+			return nil, 0, fmt.Errorf("lsjz: invalid LJJZ %q", item.LJJZ)
+		}
+		if nav.DailyGrowth, err = parseFloat(item.JZZZL); err != nil {
+			return nil, 0, fmt.Errorf("lsjz: invalid JZZZL %q", item.JZZZL)
+		}
+		navs = append(navs, nav)
+	}
+
+	return navs, payload.TotalCount, nil
+}
+
+// FetchMoneyYieldPage 分页获取货币基金的每万份收益与七日年化收益率，按日期倒序，返回当页数据与总条数
+// 货币基金的历史净值接口中 DWJZ 为每万份收益，LJJZ 为七日年化收益率（%）
+func (s *EastmoneyScraper) FetchMoneyYieldPage(code string, pageIndex, pageSize int) ([]MoneyYield, int, error) {
+	navs, total, err := s.FetchNavPage(code, pageIndex, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	yields := make([]MoneyYield, 0, len(navs))
+	for _, nav := range navs {
+		yields = append(yields, MoneyYield{
+			Code:         code,
+			YieldDate:    nav.NavDate,
+			IncomePer10K: nav.Nav,
+			Yield7D:      nav.AccNav,
+			Source:       nav.Source,
+		})
+	}
+	return yields, total, nil
+}
+
+// FetchMetadata 获取基金基础信息，现任基金经理取自 F10 基金经理页
+// 经理页抓取或解析失败时只记录日志，仍返回基础信息，Managers 为 nil（与暂无现任经理的空列表区分）
+func (s *EastmoneyScraper) FetchMetadata(code string) (*FundMetadata, error) {
+	url := fmt.Sprintf("%s/FundMApi/FundBaseTypeInformation.ashx?FCODE=%s&deviceid=Wap&plat=Wap&product=EFund&version=2.0.0",
+		s.mobileURL, code)
+	body, err := s.client.Get(s.Name(), url, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var payload baseInfoPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("base info: %w", err)
+	}
+	if payload.ErrCode != 0 {
+		return nil, fmt.Errorf("base info %s: %s", code, payload.ErrMsg)
+	}
+
+	size, err := parseFloat(payload.Datas.EndNav)
+	if err != nil {
+		return nil, fmt.Errorf("base info %s: invalid size %q", code, payload.Datas.EndNav)
+	}
+	riskLevel, _ := strconv.Atoi(strings.TrimSpace(payload.Datas.RiskLevel))
+
+	managers, err := s.fetchManagers(code)
+	if err != nil {
+		log.Printf("[scraper] %s managers of %s unavailable: %v", s.Name(), code, err)
+		managers = nil
+	}
+
+	return &FundMetadata{
+		Code:          code,
+		Name:          payload.Datas.ShortName,
+		Type:          payload.Datas.FType,
+		Company:       payload.Datas.JJGS,
+		InceptionDate: datePattern.FindString(payload.Datas.EstabDate),
+		Size:          size,
+		SizeDate:      datePattern.FindString(payload.Datas.FEGMRQ),
+		Benchmark:     strings.TrimSpace(payload.Datas.Bench),
+		RiskLevel:     riskLevel,
+		Managers:      managers,
+		Source:        s.Name(),
+	}, nil
+}
+
+// FetchHoldings 获取基金最新披露的前十大重仓股
+func (s *EastmoneyScraper) FetchHoldings(code string) (*FundHoldings, error) {
+	url := fmt.Sprintf("%s/FundMNewApi/FundMNInverstPosition?FCODE=%s&deviceid=Wap&plat=Wap&product=EFund&version=2.0.0",
+		s.mobileURL, code)
+	body, err := s.client.Get(s.Name(), url, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var payload positionPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("position: %w", err)
+	}
+	if payload.ErrCode != 0 {
+		return nil, fmt.Errorf("position %s: %s", code, payload.ErrMsg)
+	}
+
+	holdings := &FundHoldings{Code: code, ReportDate: payload.Expansion, Source: s.Name()}
+	for _, item := range payload.Datas.FundStocks {
+		weight, err := parseFloat(item.JZBL)
+		if err != nil {
+			return nil, fmt.Errorf("position: invalid JZBL %q", item.JZBL)
+		}
+		exchange := eastmoneyExchange(item.NEWTEXCH, item.GPDM)
+		holdings.Stocks = append(holdings.Stocks, StockHolding{
+			Symbol:   exchange + item.GPDM,
+			Code:     item.GPDM,
+			Name:     item.GPJC,
+			Exchange: exchange,
+			Weight:   weight,
+		})
+	}
+
+	return holdings, nil
+}
+
+// eastmoneyExchange 将天天基金的市场编号转换为行情前缀
+func eastmoneyExchange(market, code string) string {
+	switch market {
+	case "1":
+		return "sh"
+	case "0":
+		if strings.HasPrefix(code, "8") || strings.HasPrefix(code, "4") {
+			return "bj"
+		}
+		return "sz"
+	case "116":
+		return "hk"
+	case "105", "106", "107":
+		return "us"
+	default:
+		return ""
+	}
+}
+
+// parseFundgz 解析 jsonpgz({...}); 格式的返回数据
+func parseFundgz(body []byte) (*FundEstimate, error) {
+	text := strings.TrimSpace(string(body))
+	start := strings.Index(text, "(")
+	end := strings.LastIndex(text, ")")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("fundgz: malformed jsonp payload")
+	}
+
+	inner := strings.TrimSpace(text[start+1 : end])
+	if inner == "" {
+		return nil, ErrNoEstimate
+	}
+
+	var payload fundgzPayload
+	if err := json.Unmarshal([]byte(inner), &payload); err != nil {
+		return nil, fmt.Errorf("fundgz: %w", err)
+	}
+
+	estimate := &FundEstimate{
+		Code:    payload.FundCode,
+		Name:    payload.Name,
+		NavDate: payload.Jzrq,
+	}
+
+	var err error
+	if estimate.Nav, err = parseFloat(payload.Dwjz); err != nil {
+		return nil, fmt.Errorf("fundgz: invalid dwjz %q", payload.Dwjz)
+	}
+	if estimate.EstimateNav, err = parseFloat(payload.Gsz); err != nil {
+		return nil, fmt.Errorf("fundgz: invalid gsz %q", payload.Gsz)
+	}
+	if estimate.DailyGrowth, err = parseFloat(payload.Gszzl); err != nil {
+		return nil, fmt.Errorf("fundgz: invalid gszzl %q", payload.Gszzl)
+	}
+	if payload.Gztime != "" {
+		estimate.EstimateTime, err = time.ParseInLocation("2006-01-02 15:04", payload.Gztime, calendar.Location)
+		if err != nil {
+			return nil, fmt.Errorf("fundgz: invalid gztime %q", payload.Gztime)
+		}
+	}
+
+	return estimate, nil
+}
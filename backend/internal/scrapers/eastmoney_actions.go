@@ -0,0 +1,93 @@
+package scrapers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var (
+	datePattern       = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+	cashPattern       = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*元`)
+	splitRatioPattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*:\s*(\d+(?:\.\d+)?)`)
+)
+
+// FetchCorporateActions 从天天基金 F10 分红送配页获取基金的历次分红与份额拆分
+func (s *EastmoneyScraper) FetchCorporateActions(code string) ([]CorporateAction, error) {
+	url := fmt.Sprintf("%s/fhsp_%s.html", s.f10URL, code)
+	body, err := s.client.Get(s.Name(), url, eastmoneyReferer)
+	if err != nil {
+		return nil, err
+	}
+
+	actions, err := parseActionPage(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("fhsp %s: %w", code, err)
+	}
+	for i := range actions {
+		actions[i].Code = code
+		actions[i].Source = s.Name()
+	}
+	return actions, nil
+}
+
+// parseActionPage 解析分红送配页 HTML
+// 分红表为“年份 / 权益登记日 / 除息日 / 每份分红 / 分红发放日”，每份分红形如“每份派现金0.0500元”；
+// 拆分表为“年份 / 拆分折算日 / 拆分类型 / 拆分折算比例”，比例形如“1:1.0250”；
+// 没有记录时表格只有一行“暂无……”提示
+func parseActionPage(page string) ([]CorporateAction, error) {
+	dividends := sectionRows(page, "分红送配详情")
+	splits := sectionRows(page, "拆分详情")
+	if dividends == nil && splits == nil {
+		return nil, fmt.Errorf("no dividend or split table found")
+	}
+
+	actions := make([]CorporateAction, 0)
+	for _, cells := range dividends {
+		if len(cells) < 5 {
+			continue
+		}
+		match := cashPattern.FindStringSubmatch(cells[3])
+		exDate := datePattern.FindString(cells[2])
+		if match == nil || exDate == "" {
+			continue
+		}
+		cash, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dividend %q", cells[3])
+		}
+		actions = append(actions, CorporateAction{
+			Type:         ActionDividend,
+			RecordDate:   datePattern.FindString(cells[1]),
+			ExDate:       exDate,
+			PayDate:      datePattern.FindString(cells[4]),
+			CashPerShare: cash,
+		})
+	}
+
+	for _, cells := range splits {
+		if len(cells) < 4 {
+			continue
+		}
+		match := splitRatioPattern.FindStringSubmatch(cells[3])
+		exDate := datePattern.FindString(cells[1])
+		if match == nil || exDate == "" {
+			continue
+		}
+		before, err := strconv.ParseFloat(match[1], 64)
+		if err != nil || before == 0 {
+			return nil, fmt.Errorf("invalid split ratio %q", cells[3])
+		}
+		after, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid split ratio %q", cells[3])
+		}
+		actions = append(actions, CorporateAction{
+			Type:       ActionSplit,
+			RecordDate: exDate,
+			ExDate:     exDate,
+			SplitRatio: after / before,
+		})
+	}
+	return actions, nil
+}
@@ -0,0 +1,164 @@
+package scrapers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	htmlRowPattern     = regexp.MustCompile(`(?is)<tr[^>]*>(.*?)</tr>`)
+	htmlCellPattern    = regexp.MustCompile(`(?is)<td[^>]*>(.*?)</td>`)
+	htmlTagPattern     = regexp.MustCompile(`(?s)<[^>]+>`)
+	percentPattern     = regexp.MustCompile(`(\d+(?:\.\d+)?)%`)
+	holdingTermPattern = regexp.MustCompile(`(大于等于|大于|小于等于|小于)\s*(\d+(?:\.\d+)?)\s*(个月|月|天|日|年)`)
+)
+
+// FetchFees 从天天基金 F10 费率页获取基金的申购、赎回与运作费率
+func (s *EastmoneyScraper) FetchFees(code string) (*FundFees, error) {
+	url := fmt.Sprintf("%s/jjfl_%s.html", s.f10URL, code)
+	body, err := s.client.Get(s.Name(), url, eastmoneyReferer)
+	if err != nil {
+		return nil, err
+	}
+
+	fees, err := parseFeePage(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("jjfl %s: %w", code, err)
+	}
+	fees.Code = code
+	fees.Source = s.Name()
+	return fees, nil
+}
+
+// parseFeePage 解析费率页 HTML
+// 运作费用为“管理费率 / 托管费率 / 销售服务费率”表格；申购费率取最低金额档的优惠费率；
+// 赎回费率按“小于7天”“大于等于7天，小于1年”等期限描述换算为持有天数区间
+func parseFeePage(page string) (*FundFees, error) {
+	fees := &FundFees{}
+
+	var err error
+	if fees.ManagementRate, err = labeledRate(page, "管理费率"); err != nil {
+		return nil, err
+	}
+	if fees.CustodyRate, err = labeledRate(page, "托管费率"); err != nil {
+		return nil, err
+	}
+	if fees.ServiceRate, err = labeledRate(page, "销售服务费率"); err != nil {
+		return nil, err
+	}
+
+	if rows := sectionRows(page, "申购费率"); len(rows) > 0 {
+		cells := rows[0]
+		if fees.PurchaseRate, err = lastPercent(cells[len(cells)-1]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, cells := range sectionRows(page, "赎回费率") {
+		rate, err := lastPercent(cells[len(cells)-1])
+		if err != nil {
+			return nil, err
+		}
+		tier := RedemptionTier{Rate: rate}
+		for _, cell := range cells[:len(cells)-1] {
+			if holdingTermPattern.MatchString(cell) {
+				tier.MinDays, tier.MaxDays = parseHoldingTerm(cell)
+				break
+			}
+		}
+		fees.RedemptionTiers = append(fees.RedemptionTiers, tier)
+	}
+
+	if len(fees.RedemptionTiers) == 0 && fees.ManagementRate == 0 {
+		return nil, fmt.Errorf("no fee table found")
+	}
+	return fees, nil
+}
+
+// labeledRate 读取“标签单元格 + 费率单元格”形式的年化费率，"---" 视为 0
+func labeledRate(page, label string) (float64, error) {
+	pattern := regexp.MustCompile(`(?s)` + regexp.QuoteMeta(label) + `\s*</td>\s*<td[^>]*>(.*?)</td>`)
+	match := pattern.FindStringSubmatch(page)
+	if match == nil {
+		return 0, nil
+	}
+	return lastPercent(stripTags(match[1]))
+}
+
+// sectionRows 返回标题之后第一个表格中的数据行，每行为去除标签后的单元格文本
+func sectionRows(page, title string) [][]string {
+	start := strings.Index(page, ">"+title)
+	if start < 0 {
+		start = strings.Index(page, title)
+	}
+	if start < 0 {
+		return nil
+	}
+	page = page[start:]
+
+	tableStart := strings.Index(page, "<table")
+	tableEnd := strings.Index(page, "</table>")
+	if tableStart < 0 || tableEnd < tableStart {
+		return nil
+	}
+
+	var rows [][]string
+	for _, row := range htmlRowPattern.FindAllStringSubmatch(page[tableStart:tableEnd], -1) {
+		var cells []string
+		for _, cell := range htmlCellPattern.FindAllStringSubmatch(row[1], -1) {
+			cells = append(cells, stripTags(cell[1]))
+		}
+		if len(cells) > 0 {
+			rows = append(rows, cells)
+		}
+	}
+	return rows
+}
+
+// stripTags 去除 HTML 标签与首尾空白
+func stripTags(text string) string {
+	return strings.TrimSpace(htmlTagPattern.ReplaceAllString(text, ""))
+}
+
+// lastPercent 取文本中最后一个百分数并换算为小数；有原费率与优惠费率时后者在后
+func lastPercent(text string) (float64, error) {
+	matches := percentPattern.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(matches[len(matches)-1][1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q", text)
+	}
+	return value / 100, nil
+}
+
+// parseHoldingTerm 将期限描述换算为持有天数区间 [min, max)，max 为 0 表示无上限
+// 一年按 365 天、一个月按 30 天计
+func parseHoldingTerm(text string) (int, int) {
+	var minDays, maxDays int
+	for _, match := range holdingTermPattern.FindAllStringSubmatch(text, -1) {
+		value, _ := strconv.ParseFloat(match[2], 64)
+		days := int(value)
+		switch match[3] {
+		case "年":
+			days = int(value * 365)
+		case "个月", "月":
+			days = int(value * 30)
+		}
+
+		switch match[1] {
+		case "大于等于":
+			minDays = days
+		case "大于":
+			minDays = days + 1
+		case "小于":
+			maxDays = days
+		case "小于等于":
+			maxDays = days + 1
+		}
+	}
+	return minDays, maxDays
+}
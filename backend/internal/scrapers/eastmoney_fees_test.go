@@ -0,0 +1,27 @@
+package scrapers
+
+import "testing"
+
+func TestParseHoldingTerm(t *testing.T) {
+	tests := []struct {
+		text    string
+		minDays int
+		maxDays int
+	}{
+		{"小于7天", 0, 7},
+		{"大于等于7天，小于1年", 7, 365},
+		{"大于等于1年，小于2年", 365, 730},
+		{"大于等于2年", 730, 0},
+		{"大于等于30日，小于6个月", 30, 180},
+		{"小于等于1月", 0, 31},
+		{"大于365天", 366, 0},
+		{"大于等于1.5年", 547, 0},
+		{"--", 0, 0},
+	}
+	for _, tt := range tests {
+		minDays, maxDays := parseHoldingTerm(tt.text)
+		if minDays != tt.minDays || maxDays != tt.maxDays {
+			t.Errorf("parseHoldingTerm(%q) = [%d, %d), want [%d, %d)", tt.text, minDays, maxDays, tt.minDays, tt.maxDays)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package scrapers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// fetchManagers 从天天基金 F10 基金经理页获取现任基金经理及其任职起始日期
+func (s *EastmoneyScraper) fetchManagers(code string) ([]FundManager, error) {
+	url := fmt.Sprintf("%s/jjjl_%s.html", s.f10URL, code)
+	body, err := s.client.Get(s.Name(), url, eastmoneyReferer)
+	if err != nil {
+		return nil, err
+	}
+
+	managers, err := parseManagerPage(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("jjjl %s: %w", code, err)
+	}
+	return managers, nil
+}
+
+// parseManagerPage 解析基金经理页 HTML
+// 变动表为“起始期 / 截止期 / 基金经理 / 任职期间 / 任职回报”，按起始期倒序，截止期为“至今”的一行即现任经理，
+// 多名经理以空格分隔；经理的任职起始日期为其连续出现在各期经理名单中的最早起始期
+func parseManagerPage(page string) ([]FundManager, error) {
+	rows := sectionRows(page, "基金经理变动一览")
+	if rows == nil {
+		return nil, fmt.Errorf("no manager table found")
+	}
+
+	type term struct {
+		start string
+		names []string
+	}
+	var terms []term
+	for _, cells := range rows {
+		if len(cells) < 3 {
+			continue
+		}
+		start := datePattern.FindString(cells[0])
+		if start == "" {
+			continue
+		}
+		if len(terms) == 0 && !strings.Contains(cells[1], "至今") {
+			// 最新一期已离任，基金暂无现任经理
+			return []FundManager{}, nil
+		}
+		terms = append(terms, term{start: start, names: strings.Fields(strings.ReplaceAll(cells[2], "&nbsp;", " "))})
+	}
+
+	managers := make([]FundManager, 0)
+	if len(terms) == 0 {
+		return managers, nil
+	}
+	for _, name := range terms[0].names {
+		start := terms[0].start
+		for _, t := range terms[1:] {
+			if !slices.Contains(t.names, name) {
+				break
+			}
+			start = t.start
+		}
+		managers = append(managers, FundManager{Name: name, StartDate: start})
+	}
+	return managers, nil
+}
@@ -0,0 +1,65 @@
+package scrapers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/config"
+)
+
+func TestFetchEstimateParsesFundgzJSONP(t *testing.T) {
+	responses := map[string]string{
+		"/js/161725.js": `jsonpgz({"fundcode":"161725","name":"招商中证白酒指数(LOF)A","jzrq":"2024-03-01",` +
+			`"dwjz":"1.0530","gsz":"1.0612","gszzl":"0.78","gztime":"2024-03-04 14:30"});`,
+		"/js/000198.js": `jsonpgz();`,
+		"/js/000001.js": `<html>not found</html>`,
+		"/js/000002.js": `jsonpgz({"fundcode":"000002","dwjz":"1.0","gsz":"N/A","gszzl":"0.1"});`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	cfg := config.ScraperConfig{FundgzBaseURL: srv.URL, RetryCount: 0, Timeout: 2}
+	scraper := NewEastmoneyScraper(cfg, NewClient(cfg))
+
+	estimate, err := scraper.FetchEstimate("161725")
+	if err != nil {
+		t.Fatalf("FetchEstimate: %v", err)
+	}
+	want := FundEstimate{
+		Code:         "161725",
+		Name:         "招商中证白酒指数(LOF)A",
+		Nav:          1.053,
+		NavDate:      "2024-03-01",
+		EstimateNav:  1.0612,
+		DailyGrowth:  0.78,
+		EstimateTime: time.Date(2024, 3, 4, 14, 30, 0, 0, calendar.Location),
+		Source:       "eastmoney",
+	}
+	if !estimate.EstimateTime.Equal(want.EstimateTime) {
+		t.Errorf("EstimateTime = %v, want %v", estimate.EstimateTime, want.EstimateTime)
+	}
+	estimate.EstimateTime = want.EstimateTime
+	if *estimate != want {
+		t.Errorf("FetchEstimate = %+v, want %+v", *estimate, want)
+	}
+
+	if _, err := scraper.FetchEstimate("000198"); !errors.Is(err, ErrNoEstimate) {
+		t.Errorf("empty payload: err = %v, want ErrNoEstimate", err)
+	}
+	for _, code := range []string{"000001", "000002"} {
+		if _, err := scraper.FetchEstimate(code); err == nil || errors.Is(err, ErrNoEstimate) {
+			t.Errorf("%s: err = %v, want parse error", code, err)
+		}
+	}
+}
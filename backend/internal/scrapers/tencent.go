@@ -0,0 +1,95 @@
+package scrapers
+
+import (
+	"fmt"
+	"strings"
+
+	"fundnet/backend/internal/config"
+)
+
+// 腾讯行情各接口默认地址
+const (
+	DefaultTencentBaseURL      = "http://qt.gtimg.cn"
+	DefaultTencentKlineBaseURL = "https://web.ifzq.gtimg.cn"
+)
+
+// tencentBatchSize 单次请求的最大股票数
+const tencentBatchSize = 50
+
+// TencentQuoteScraper 腾讯股票行情爬虫
+type TencentQuoteScraper struct {
+	baseURL  string
+	klineURL string
+	client   *Client
+}
+
+// NewTencentQuoteScraper 创建腾讯股票行情爬虫
+func NewTencentQuoteScraper(cfg config.ScraperConfig, client *Client) *TencentQuoteScraper {
+	return &TencentQuoteScraper{
+		baseURL:  baseURL(cfg.TencentBaseURL, DefaultTencentBaseURL),
+		klineURL: baseURL(cfg.TencentKlineBaseURL, DefaultTencentKlineBaseURL),
+		client:   client,
+	}
+}
+
+// Name 数据源名称
+func (s *TencentQuoteScraper) Name() string {
+	return "tencent"
+}
+
+// FetchQuotes 批量获取股票实时行情，symbols 形如 sh600519、hk00700
+func (s *TencentQuoteScraper) FetchQuotes(symbols []string) (map[string]StockQuote, error) {
+	quotes := make(map[string]StockQuote, len(symbols))
+	for start := 0; start < len(symbols); start += tencentBatchSize {
+		end := start + tencentBatchSize
+		if end > len(symbols) {
+			end = len(symbols)
+		}
+
+		url := fmt.Sprintf("%s/q=%s", s.baseURL, strings.Join(symbols[start:end], ","))
+		body, err := s.client.Get(s.Name(), url, "")
+		if err != nil {
+			return nil, err
+		}
+
+		for symbol, quote := range parseTencentQuotes(string(body)) {
+			quotes[symbol] = quote
+		}
+	}
+	return quotes, nil
+}
+
+// parseTencentQuotes 解析 v_sh600519="1~名称~代码~现价~昨收~...";
+// 名称为 GBK 编码，此处只取数值字段
+func parseTencentQuotes(body string) map[string]StockQuote {
+	quotes := make(map[string]StockQuote)
+	for _, line := range strings.Split(body, ";") {
+		line = strings.TrimSpace(line)
+		eq := strings.Index(line, "=")
+		if !strings.HasPrefix(line, "v_") || eq < 0 {
+			continue
+		}
+
+		symbol := line[2:eq]
+		fields := strings.Split(strings.Trim(line[eq+1:], `"`), "~")
+		if len(fields) < 5 {
+			continue
+		}
+
+		price, err := parseFloat(fields[3])
+		if err != nil {
+			continue
+		}
+		prevClose, err := parseFloat(fields[4])
+		if err != nil {
+			continue
+		}
+
+		quote := StockQuote{Symbol: symbol, Price: price, PrevClose: prevClose}
+		if price > 0 && prevClose > 0 {
+			quote.ChangeRate = (price - prevClose) / prevClose * 100
+		}
+		quotes[symbol] = quote
+	}
+	return quotes
+}
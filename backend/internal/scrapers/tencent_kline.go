@@ -0,0 +1,98 @@
+package scrapers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 日K线接口单次最多返回 640 条，按约 400 个交易日的自然日跨度分段请求
+const (
+	klineLimit     = 640
+	klineSpanDays  = 600
+	klineDayLayout = "2006-01-02"
+)
+
+// klinePayload 日K线接口原始数据，每行为 [日期, 开盘, 收盘, 最高, 最低, 成交量, ...]
+type klinePayload struct {
+	Code int                                   `json:"code"`
+	Msg  string                                `json:"msg"`
+	Data map[string]map[string]json.RawMessage `json:"data"`
+}
+
+// FetchIndexHistory 获取指数 [from, to] 区间的日收盘点位，按日期升序；symbol 形如 sh000300
+func (s *TencentQuoteScraper) FetchIndexHistory(symbol string, from, to time.Time) ([]IndexClose, error) {
+	byDate := make(map[string]IndexClose)
+	for start := from; !start.After(to); start = start.AddDate(0, 0, klineSpanDays+1) {
+		end := start.AddDate(0, 0, klineSpanDays)
+		if end.After(to) {
+			end = to
+		}
+
+		url := fmt.Sprintf("%s/appstock/app/fqkline/get?param=%s,day,%s,%s,%d,",
+			s.klineURL, symbol, start.Format(klineDayLayout), end.Format(klineDayLayout), klineLimit)
+		body, err := s.client.Get(s.Name(), url, "")
+		if err != nil {
+			return nil, err
+		}
+
+		closes, err := parseKline(symbol, body)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range closes {
+			item.Source = s.Name()
+			byDate[item.Date] = item
+		}
+	}
+
+	closes := make([]IndexClose, 0, len(byDate))
+	for _, item := range byDate {
+		closes = append(closes, item)
+	}
+	sort.Slice(closes, func(i, j int) bool { return closes[i].Date < closes[j].Date })
+	return closes, nil
+}
+
+// parseKline 解析日K线接口返回，指数的K线位于 day 字段，股票可能位于 qfqday 字段
+func parseKline(symbol string, body []byte) ([]IndexClose, error) {
+	var payload klinePayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("kline: %w", err)
+	}
+	if payload.Code != 0 {
+		return nil, fmt.Errorf("kline %s: %s", symbol, payload.Msg)
+	}
+
+	fields, ok := payload.Data[symbol]
+	if !ok {
+		return nil, fmt.Errorf("kline %s: no data", symbol)
+	}
+	raw, ok := fields["day"]
+	if !ok {
+		if raw, ok = fields["qfqday"]; !ok {
+			return nil, nil
+		}
+	}
+
+	var rows [][]interface{}
+	if err := json.Unmarshal(raw, &rows); err != nil {
+		return nil, fmt.Errorf("kline %s: %w", symbol, err)
+	}
+
+	closes := make([]IndexClose, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 3 {
+			continue
+		}
+		date, _ := row[0].(string)
+		value, _ := row[2].(string)
+		closeValue, err := parseFloat(value)
+		if err != nil || date == "" || closeValue <= 0 {
+			continue
+		}
+		closes = append(closes, IndexClose{Symbol: symbol, Date: date, Close: closeValue})
+	}
+	return closes, nil
+}
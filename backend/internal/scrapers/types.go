@@ -0,0 +1,156 @@
+package scrapers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoEstimate 上游未提供估值（如货币基金、新发基金）
+var ErrNoEstimate = errors.New("fund has no estimate")
+
+// ErrUnsupported 数据源不支持该类数据
+var ErrUnsupported = errors.New("operation not supported by data source")
+
+// FundEstimate 基金实时估值
+type FundEstimate struct {
+	Code         string    `json:"code"`
+	Name         string    `json:"name"`
+	Nav          float64   `json:"nav"`
+	NavDate      string    `json:"nav_date"`
+	EstimateNav  float64   `json:"estimate_nav"`
+	DailyGrowth  float64   `json:"daily_growth"`
+	EstimateTime time.Time `json:"estimate_time"`
+	Source       string    `json:"source"`
+}
+
+// FundNav 基金官方净值
+type FundNav struct {
+	Code        string  `json:"code"`
+	NavDate     string  `json:"nav_date"`
+	Nav         float64 `json:"nav"`
+	AccNav      float64 `json:"acc_nav"`
+	DailyGrowth float64 `json:"daily_growth"`
+	Source      string  `json:"source"`
+}
+
+// MoneyYield 货币基金每日收益，IncomePer10K 为每万份收益（元），Yield7D 为七日年化收益率（%）
+type MoneyYield struct {
+	Code         string  `json:"code"`
+	YieldDate    string  `json:"yield_date"`
+	IncomePer10K float64 `json:"income_per_10k"`
+	Yield7D      float64 `json:"yield_7d"`
+	Source       string  `json:"source"`
+}
+
+// FundMetadata 基金基础信息
+// Type 为数据源的基金类型描述（如“混合型-偏股”），Size 为最新规模（元），SizeDate 为规模截止日期，
+// RiskLevel 为 1（低）至 5（高）的风险等级，0 表示未知；Managers 为 nil 表示经理信息未能获取
+type FundMetadata struct {
+	Code          string        `json:"code"`
+	Name          string        `json:"name"`
+	Type          string        `json:"type"`
+	Company       string        `json:"company"`
+	InceptionDate string        `json:"inception_date"`
+	Size          float64       `json:"size"`
+	SizeDate      string        `json:"size_date"`
+	Benchmark     string        `json:"benchmark"`
+	RiskLevel     int           `json:"risk_level"`
+	Managers      []FundManager `json:"managers"`
+	Source        string        `json:"source"`
+}
+
+// FundManager 现任基金经理，StartDate 为其连续管理该基金的起始日期
+type FundManager struct {
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+}
+
+// parseFloat 解析数值字符串，空字符串视为 0
+func parseFloat(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(s, "%")
+	if s == "" || s == "--" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// StockHolding 基金持仓股票
+type StockHolding struct {
+	Symbol   string  `json:"symbol"`
+	Code     string  `json:"code"`
+	Name     string  `json:"name"`
+	Exchange string  `json:"exchange"`
+	Weight   float64 `json:"weight"`
+}
+
+// FundHoldings 基金某一报告期的前十大重仓股
+type FundHoldings struct {
+	Code       string         `json:"code"`
+	ReportDate string         `json:"report_date"`
+	Stocks     []StockHolding `json:"stocks"`
+	Source     string         `json:"source"`
+}
+
+// StockQuote 股票实时行情
+type StockQuote struct {
+	Symbol     string  `json:"symbol"`
+	Price      float64 `json:"price"`
+	PrevClose  float64 `json:"prev_close"`
+	ChangeRate float64 `json:"change_rate"`
+}
+
+// IndexClose 指数某一交易日的收盘点位
+type IndexClose struct {
+	Symbol string  `json:"symbol"`
+	Date   string  `json:"date"`
+	Close  float64 `json:"close"`
+	Source string  `json:"source"`
+}
+
+// RedemptionTier 赎回费率档位，持有天数落在 [MinDays, MaxDays) 内适用 Rate；MaxDays 为 0 表示无上限
+type RedemptionTier struct {
+	MinDays int     `json:"min_days"`
+	MaxDays int     `json:"max_days"`
+	Rate    float64 `json:"rate"`
+}
+
+// FundFees 基金费率，费率均为小数（0.015 表示 1.5%），运作费率为年化
+type FundFees struct {
+	Code            string           `json:"code"`
+	PurchaseRate    float64          `json:"purchase_rate"`
+	ManagementRate  float64          `json:"management_rate"`
+	CustodyRate     float64          `json:"custody_rate"`
+	ServiceRate     float64          `json:"service_rate"`
+	RedemptionTiers []RedemptionTier `json:"redemption_tiers"`
+	Source          string           `json:"source"`
+}
+
+// 公司行为类型
+const (
+	ActionDividend = "dividend"
+	ActionSplit    = "split"
+)
+
+// CorporateAction 基金分红或份额拆分
+// 分红时 CashPerShare 为每份派现金额；拆分时 SplitRatio 为拆分后每份对应的份额（1:1.0250 记为 1.025）
+type CorporateAction struct {
+	Code         string  `json:"code"`
+	Type         string  `json:"type"`
+	RecordDate   string  `json:"record_date"`
+	ExDate       string  `json:"ex_date"`
+	PayDate      string  `json:"pay_date"`
+	CashPerShare float64 `json:"cash_per_share"`
+	SplitRatio   float64 `json:"split_ratio"`
+	Source       string  `json:"source"`
+}
+
+// FxRate 人民币汇率中间价，Rate 为 1 单位外币折合的人民币
+type FxRate struct {
+	Currency string  `json:"currency"`
+	RateDate string  `json:"rate_date"`
+	Rate     float64 `json:"rate"`
+	Source   string  `json:"source"`
+}
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"fundnet/backend/internal/models"
+)
+
+// 收益统计区间
+const (
+	Window1M        = "1M"
+	Window3M        = "3M"
+	WindowYTD       = "YTD"
+	Window1Y        = "1Y"
+	WindowInception = "inception"
+)
+
+// performanceWindows 收益统计的标准区间
+var performanceWindows = []string{Window1M, Window3M, WindowYTD, Window1Y, WindowInception}
+
+// CashFlow 投资者视角的外部现金流：申购、转入与费用为投入（负数），赎回净额与现金分红为取回（正数）
+type CashFlow struct {
+	Date   time.Time `json:"date"`
+	Amount float64   `json:"amount"`
+}
+
+// valuationPoint 某日收盘后的市值
+type valuationPoint struct {
+	Date  time.Time
+	Value float64
+}
+
+// WindowReturn 单个区间的收益
+// TWR 为时间加权收益率（%），剔除资金进出的影响；XIRR 为资金加权年化收益率（%），无法求解时为空
+type WindowReturn struct {
+	Window     string    `json:"window"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	StartValue float64   `json:"start_value"`
+	EndValue   float64   `json:"end_value"`
+	NetInflow  float64   `json:"net_inflow"`
+	Profit     float64   `json:"profit"`
+	TWR        float64   `json:"twr"`
+	XIRR       *float64  `json:"xirr"`
+}
+
+// Performance 组合、板块或单只基金在各标准区间的收益
+type Performance struct {
+	Scope   string         `json:"scope"`
+	Key     string         `json:"key"`
+	Returns []WindowReturn `json:"returns"`
+}
+
+// PortfolioPerformance 组合整体、各板块与各基金的收益
+type PortfolioPerformance struct {
+	AsOf      *time.Time    `json:"as_of"`
+	Portfolio Performance   `json:"portfolio"`
+	Sectors   []Performance `json:"sectors"`
+	Funds     []Performance `json:"funds"`
+}
+
+// GetPerformance 计算组合、各板块与各基金在 1M、3M、YTD、1Y 与成立以来的 TWR 与 XIRR
+// 每日市值取自资产快照，现金流取自已确认的交易流水；各区间截止于最近一次快照
+func (s *FundService) GetPerformance() (*PortfolioPerformance, error) {
+	valuations, err := s.loadValuations()
+	if err != nil {
+		return nil, err
+	}
+	flows, err := s.loadCashFlows()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PortfolioPerformance{
+		Sectors: make([]Performance, 0),
+		Funds:   make([]Performance, 0),
+	}
+	portfolio := valuations[scopeKey{models.SnapshotPortfolio, ""}]
+	if len(portfolio) == 0 {
+		result.Portfolio = Performance{Scope: models.SnapshotPortfolio, Returns: make([]WindowReturn, 0)}
+		return result, nil
+	}
+	asOf := portfolio[len(portfolio)-1].Date
+	result.AsOf = &asOf
+
+	keys := make([]scopeKey, 0, len(valuations))
+	for key := range valuations {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].key < keys[j].key })
+
+	for _, key := range keys {
+		perf := Performance{
+			Scope:   key.scope,
+			Key:     key.key,
+			Returns: windowReturns(valuations[key], flows[key], asOf),
+		}
+		switch key.scope {
+		case models.SnapshotPortfolio:
+			result.Portfolio = perf
+		case models.SnapshotSector:
+			result.Sectors = append(result.Sectors, perf)
+		case models.SnapshotPosition:
+			result.Funds = append(result.Funds, perf)
+		}
+	}
+	return result, nil
+}
+
+// scopeKey 快照范围与键
+type scopeKey struct {
+	scope string
+	key   string
+}
+
+// loadValuations 读取全部资产快照，按范围分组并按日期排序
+func (s *FundService) loadValuations() (map[scopeKey][]valuationPoint, error) {
+	rows, err := s.db.Query(`
+		SELECT snapshot_date, scope, scope_key, value
+		FROM asset_snapshots
+		ORDER BY snapshot_date
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	valuations := make(map[scopeKey][]valuationPoint)
+	for rows.Next() {
+		var point valuationPoint
+		var key scopeKey
+		if err := rows.Scan(&point.Date, &key.scope, &key.key, &point.Value); err != nil {
+			return nil, err
+		}
+		point.Date = dateOnly(point.Date)
+		valuations[key] = append(valuations[key], point)
+	}
+	return valuations, rows.Err()
+}
+
+// loadCashFlows 将已确认的交易流水转换为现金流，同时计入所属基金、板块与组合
+// 红利再投资不产生外部现金流；外币份额的现金流按交易日的汇率中间价折算为人民币
+func (s *FundService) loadCashFlows() (map[scopeKey][]CashFlow, error) {
+	rows, err := s.db.Query(`
+		SELECT t.trade_date, t.fund_code, t.type, t.amount, t.fee, COALESCE(p.sector, ''), COALESCE(p.currency, '')
+		FROM transactions t
+		LEFT JOIN positions p ON p.account_id = t.account_id AND p.fund_code = t.fund_code
+		WHERE t.status = ?
+		ORDER BY t.trade_date, t.id
+	`, models.TransactionConfirmed)
+	if err != nil {
+		return nil, err
+	}
+	type flowRow struct {
+		t        models.Transaction
+		sector   string
+		currency string
+	}
+	var records []flowRow
+	for rows.Next() {
+		var r flowRow
+		if err := rows.Scan(&r.t.TradeDate, &r.t.FundCode, &r.t.Type, &r.t.Amount, &r.t.Fee, &r.sector, &r.currency); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	flows := make(map[scopeKey][]CashFlow)
+	for _, r := range records {
+		t, sector := r.t, r.sector
+		rate, err := fxRateNear(s.db, r.currency, t.TradeDate)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fxPendingError([]string{t.FundCode})
+		}
+		if err != nil {
+			return nil, err
+		}
+		t.Amount, t.Fee = t.Amount*rate, t.Fee*rate
+
+		flow := CashFlow{Date: dateOnly(t.TradeDate)}
+		switch t.Type {
+		case models.TransactionBuy, models.TransactionTransferIn, models.TransactionFee:
+			flow.Amount = -t.Amount
+		case models.TransactionSell:
+			flow.Amount = t.Amount - t.Fee
+		case models.TransactionDividendCash:
+			flow.Amount = t.Amount
+		default:
+			continue
+		}
+
+		for _, key := range []scopeKey{
+			{models.SnapshotPosition, t.FundCode},
+			{models.SnapshotSector, sector},
+			{models.SnapshotPortfolio, ""},
+		} {
+			flows[key] = append(flows[key], flow)
+		}
+	}
+	return flows, nil
+}
+
+// windowReturns 计算截止于 asOf 的各标准区间收益
+func windowReturns(valuations []valuationPoint, flows []CashFlow, asOf time.Time) []WindowReturn {
+	returns := make([]WindowReturn, 0, len(performanceWindows))
+	if len(valuations) == 0 {
+		return returns
+	}
+
+	inception := valuations[0].Date
+	if len(flows) > 0 && flows[0].Date.Before(inception) {
+		inception = flows[0].Date
+	}
+
+	for _, window := range performanceWindows {
+		var from time.Time
+		switch window {
+		case Window1M:
+			from = asOf.AddDate(0, -1, 0)
+		case Window3M:
+			from = asOf.AddDate(0, -3, 0)
+		case WindowYTD:
+			from = time.Date(asOf.Year(), 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+		case Window1Y:
+			from = asOf.AddDate(-1, 0, 0)
+		case WindowInception:
+			from = inception.AddDate(0, 0, -1)
+		}
+		if from.Before(inception) {
+			from = inception.AddDate(0, 0, -1)
+		}
+		returns = append(returns, windowReturn(window, valuations, flows, from, asOf))
+	}
+	return returns
+}
+
+// windowReturn 计算 (from, to] 区间的收益：from 为区间开始前一日，其市值作为期初投入
+func windowReturn(window string, valuations []valuationPoint, flows []CashFlow, from, to time.Time) WindowReturn {
+	result := WindowReturn{Window: window, From: from.AddDate(0, 0, 1), To: to}
+	result.StartValue = valueOn(valuations, from)
+	result.EndValue = valueOn(valuations, to)
+
+	// 时间加权：各子区间收益率连乘
+	growth := 1.0
+	for _, point := range flowAdjustedReturns(valuations, flows, from, to) {
+		growth *= 1 + point.Value
+	}
+	result.TWR = round((growth-1)*100, 2)
+
+	// 资金加权：期初市值视为投入，期末市值视为取回
+	cashFlows := make([]CashFlow, 0, len(flows)+2)
+	if result.StartValue > 0 {
+		cashFlows = append(cashFlows, CashFlow{Date: from, Amount: -result.StartValue})
+	}
+	for _, flow := range flows {
+		if flow.Date.After(from) && !flow.Date.After(to) {
+			cashFlows = append(cashFlows, flow)
+			result.NetInflow -= flow.Amount
+		}
+	}
+	if result.EndValue > 0 {
+		cashFlows = append(cashFlows, CashFlow{Date: to, Amount: result.EndValue})
+	}
+	if rate, ok := xirr(cashFlows); ok {
+		rate = round(rate*100, 2)
+		result.XIRR = &rate
+	}
+
+	result.NetInflow = round(result.NetInflow, 2)
+	result.Profit = round(result.EndValue-result.StartValue-result.NetInflow, 2)
+	result.StartValue = round(result.StartValue, 2)
+	result.EndValue = round(result.EndValue, 2)
+	return result
+}
+
+// flowAdjustedReturns 以相邻快照划分 (from, to] 区间，计算剔除现金流后的各子区间收益率（小数形式）
+// 子区间内的现金流视为在期末按当日净值发生；期初市值为 0 时以当期投入为基数，无投入的空仓日跳过
+func flowAdjustedReturns(valuations []valuationPoint, flows []CashFlow, from, to time.Time) []valuationPoint {
+	var returns []valuationPoint
+	prevValue := valueOn(valuations, from)
+	prevDate := from
+	next := 0
+	for _, point := range valuations {
+		if !point.Date.After(from) {
+			continue
+		}
+		if point.Date.After(to) {
+			break
+		}
+		var flow float64
+		for ; next < len(flows) && !flows[next].Date.After(point.Date); next++ {
+			if flows[next].Date.After(prevDate) {
+				flow -= flows[next].Amount
+			}
+		}
+		switch {
+		case prevValue > 0:
+			returns = append(returns, valuationPoint{Date: point.Date, Value: (point.Value-flow)/prevValue - 1})
+		case flow > 0:
+			returns = append(returns, valuationPoint{Date: point.Date, Value: point.Value/flow - 1})
+		}
+		prevValue, prevDate = point.Value, point.Date
+	}
+	return returns
+}
+
+// valueOn 取 date 当日（含）之前最近一次快照的市值，没有快照时为 0
+func valueOn(valuations []valuationPoint, date time.Time) float64 {
+	var value float64
+	for _, point := range valuations {
+		if point.Date.After(date) {
+			break
+		}
+		value = point.Value
+	}
+	return value
+}
+
+// xirr 求解使现金流按年折现的净现值为零的年化收益率（小数形式）
+// 现金流须同时包含投入与取回，否则无解
+func xirr(flows []CashFlow) (float64, bool) {
+	if len(flows) < 2 {
+		return 0, false
+	}
+	var hasIn, hasOut bool
+	for _, flow := range flows {
+		hasIn = hasIn || flow.Amount < 0
+		hasOut = hasOut || flow.Amount > 0
+	}
+	if !hasIn || !hasOut {
+		return 0, false
+	}
+
+	start := flows[0].Date
+	npv := func(rate float64) float64 {
+		var total float64
+		for _, flow := range flows {
+			years := flow.Date.Sub(start).Hours() / 24 / 365
+			total += flow.Amount / math.Pow(1+rate, years)
+		}
+		return total
+	}
+
+	// 净现值随利率单调递减（先投入后取回），用二分法求根
+	low, high := -0.9999, 1.0
+	for npv(high) > 0 {
+		high *= 2
+		if high > 1e6 {
+			return 0, false
+		}
+	}
+	if npv(low) < 0 {
+		return 0, false
+	}
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		if npv(mid) > 0 {
+			low = mid
+		} else {
+			high = mid
+		}
+		if high-low < 1e-10 {
+			break
+		}
+	}
+	return (low + high) / 2, true
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+)
+
+func TestXIRR(t *testing.T) {
+	tests := []struct {
+		name  string
+		flows []CashFlow
+		want  float64
+		ok    bool
+	}{
+		{
+			name:  "one year",
+			flows: []CashFlow{{day(2023, 1, 1), -1000}, {day(2024, 1, 1), 1100}},
+			want:  0.1,
+			ok:    true,
+		},
+		{
+			name:  "loss",
+			flows: []CashFlow{{day(2023, 1, 1), -1000}, {day(2024, 1, 1), 800}},
+			want:  -0.2,
+			ok:    true,
+		},
+		{
+			name:  "two years",
+			flows: []CashFlow{{day(2021, 1, 1), -1000}, {day(2022, 12, 31), 1210}},
+			want:  0.1,
+			ok:    true,
+		},
+		{name: "single flow", flows: []CashFlow{{day(2023, 1, 1), -1000}}},
+		{name: "no redemption", flows: []CashFlow{{day(2023, 1, 1), -1000}, {day(2023, 6, 1), -500}}},
+		{name: "no investment", flows: []CashFlow{{day(2023, 1, 1), 1000}, {day(2023, 6, 1), 500}}},
+	}
+	for _, tt := range tests {
+		got, ok := xirr(tt.flows)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if ok && math.Abs(got-tt.want) > 1e-3 {
+			t.Errorf("%s: xirr = %.6f, want %.6f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestXIRRZeroesNPV(t *testing.T) {
+	flows := []CashFlow{
+		{day(2023, 1, 3), -1000},
+		{day(2023, 4, 3), -1000},
+		{day(2023, 7, 3), 300},
+		{day(2023, 10, 9), -1000},
+		{day(2024, 2, 1), 3300},
+	}
+	rate, ok := xirr(flows)
+	if !ok {
+		t.Fatal("xirr: no solution")
+	}
+	var npv float64
+	for _, flow := range flows {
+		years := flow.Date.Sub(flows[0].Date).Hours() / 24 / 365
+		npv += flow.Amount / math.Pow(1+rate, years)
+	}
+	if math.Abs(npv) > 1e-4 {
+		t.Errorf("npv at %.6f = %v, want 0", rate, npv)
+	}
+}
+
+func TestFlowAdjustedReturns(t *testing.T) {
+	tests := []struct {
+		name       string
+		valuations []valuationPoint
+		flows      []CashFlow
+		from, to   time.Time
+		want       []valuationPoint
+	}{
+		{
+			name: "no flows",
+			valuations: []valuationPoint{
+				{day(2024, 3, 1), 1000}, {day(2024, 3, 4), 1100}, {day(2024, 3, 5), 990},
+			},
+			from: day(2024, 3, 1),
+			to:   day(2024, 3, 5),
+			want: []valuationPoint{{day(2024, 3, 4), 0.1}, {day(2024, 3, 5), -0.1}},
+		},
+		{
+			name: "purchase and redemption are excluded from returns",
+			valuations: []valuationPoint{
+				{day(2024, 3, 1), 1000}, {day(2024, 3, 4), 2100}, {day(2024, 3, 5), 1575},
+			},
+			flows: []CashFlow{{day(2024, 3, 4), -1000}, {day(2024, 3, 5), 500}},
+			from:  day(2024, 3, 1),
+			to:    day(2024, 3, 5),
+			want:  []valuationPoint{{day(2024, 3, 4), 0.1}, {day(2024, 3, 5), -0.011905}},
+		},
+		{
+			name: "first purchase from an empty portfolio",
+			valuations: []valuationPoint{
+				{day(2024, 3, 1), 0}, {day(2024, 3, 4), 0}, {day(2024, 3, 5), 1050},
+			},
+			flows: []CashFlow{{day(2024, 3, 5), -1000}},
+			from:  day(2024, 3, 1),
+			to:    day(2024, 3, 5),
+			want:  []valuationPoint{{day(2024, 3, 5), 0.05}},
+		},
+		{
+			name: "flows on or before from are ignored",
+			valuations: []valuationPoint{
+				{day(2024, 3, 1), 2000}, {day(2024, 3, 4), 2200},
+			},
+			flows: []CashFlow{{day(2024, 3, 1), -1000}},
+			from:  day(2024, 3, 1),
+			to:    day(2024, 3, 4),
+			want:  []valuationPoint{{day(2024, 3, 4), 0.1}},
+		},
+		{
+			name: "points outside the window are skipped",
+			valuations: []valuationPoint{
+				{day(2024, 2, 29), 500}, {day(2024, 3, 1), 1000}, {day(2024, 3, 4), 1200}, {day(2024, 3, 5), 1300},
+			},
+			from: day(2024, 3, 1),
+			to:   day(2024, 3, 4),
+			want: []valuationPoint{{day(2024, 3, 4), 0.2}},
+		},
+	}
+	for _, tt := range tests {
+		got := flowAdjustedReturns(tt.valuations, tt.flows, tt.from, tt.to)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d returns %v, want %d", tt.name, len(got), got, len(tt.want))
+			continue
+		}
+		for i := range got {
+			if !got[i].Date.Equal(tt.want[i].Date) || math.Abs(got[i].Value-tt.want[i].Value) > 1e-6 {
+				t.Errorf("%s: return %d = %v %.6f, want %v %.6f", tt.name, i, got[i].Date.Format(calendar.DateLayout),
+					got[i].Value, tt.want[i].Date.Format(calendar.DateLayout), tt.want[i].Value)
+			}
+		}
+	}
+}
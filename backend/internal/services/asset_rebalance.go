@@ -0,0 +1,426 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+)
+
+// rebalanceMinAmount 低于该金额（元）的调仓忽略
+const rebalanceMinAmount = 1.0
+
+// 调仓方向
+const (
+	RebalanceBuy  = "buy"
+	RebalanceSell = "sell"
+)
+
+// AllocationDrift 目标配置的偏离：权重均为百分比，Drift 为实际权重减目标权重
+type AllocationDrift struct {
+	Scope         string  `json:"scope"`
+	ScopeKey      string  `json:"scope_key"`
+	CurrentValue  float64 `json:"current_value"`
+	CurrentWeight float64 `json:"current_weight"`
+	TargetWeight  float64 `json:"target_weight"`
+	Drift         float64 `json:"drift"`
+	Tolerance     float64 `json:"tolerance"`
+	OutOfBand     bool    `json:"out_of_band"`
+}
+
+// RebalanceTrade 单个持仓的调仓建议
+// 卖出时 Amount 为赎回金额，Fee 为按持有期估算的赎回费；买入时 Fee 为申购费
+// 费用无法估算时 FeeKnown 为 false、FeeError 为原因，Fee 按 0 计入
+type RebalanceTrade struct {
+	AccountID    int64   `json:"account_id"`
+	PositionID   int64   `json:"position_id"`
+	FundCode     string  `json:"fund_code"`
+	FundName     string  `json:"fund_name"`
+	Sector       string  `json:"sector"`
+	Action       string  `json:"action"`
+	CurrentValue float64 `json:"current_value"`
+	TargetValue  float64 `json:"target_value"`
+	Amount       float64 `json:"amount"`
+	Shares       float64 `json:"shares"`
+	Nav          float64 `json:"nav"`
+	FeeRate      float64 `json:"fee_rate"`
+	Fee          float64 `json:"fee"`
+	NetAmount    float64 `json:"net_amount"`
+	FeeKnown     bool    `json:"fee_known"`
+	FeeError     string  `json:"fee_error,omitempty"`
+}
+
+// RebalancePlan 再平衡方案
+// CashRemaining 为新增资金加赎回到账减买入后的余额，为负表示需要额外投入
+type RebalancePlan struct {
+	TotalValue     float64           `json:"total_value"`
+	Cash           float64           `json:"cash"`
+	Targets        []AllocationDrift `json:"targets"`
+	Trades         []RebalanceTrade  `json:"trades"`
+	TotalBuy       float64           `json:"total_buy"`
+	TotalSell      float64           `json:"total_sell"`
+	RedemptionFees float64           `json:"redemption_fees"`
+	PurchaseFees   float64           `json:"purchase_fees"`
+	CashRemaining  float64           `json:"cash_remaining"`
+}
+
+// GetAllocationTargets 获取全部目标配置
+func (s *FundService) GetAllocationTargets() ([]models.AllocationTarget, error) {
+	rows, err := s.db.Query(`
+		SELECT id, scope, scope_key, target_weight, tolerance, created_at, updated_at
+		FROM allocation_targets
+		ORDER BY scope, scope_key
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := make([]models.AllocationTarget, 0)
+	for rows.Next() {
+		var target models.AllocationTarget
+		err := rows.Scan(&target.ID, &target.Scope, &target.ScopeKey, &target.TargetWeight, &target.Tolerance,
+			&target.CreatedAt, &target.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
+
+// SaveAllocationTargets 以 targets 替换全部目标配置
+// 同一范围内的目标权重合计不能超过 100%
+func (s *FundService) SaveAllocationTargets(targets []models.AllocationTarget) error {
+	totals := make(map[string]float64)
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		if target.Scope != models.AllocationSector && target.Scope != models.AllocationFund {
+			return fmt.Errorf("%w: unknown scope %q", ErrInvalidInput, target.Scope)
+		}
+		if target.Scope == models.AllocationFund && target.ScopeKey == "" {
+			return fmt.Errorf("%w: fund code is required", ErrInvalidInput)
+		}
+		if target.TargetWeight < 0 || target.TargetWeight > 100 || target.Tolerance < 0 {
+			return fmt.Errorf("%w: target_weight must be in [0, 100] and tolerance must not be negative",
+				ErrInvalidInput)
+		}
+		key := target.Scope + ":" + target.ScopeKey
+		if seen[key] {
+			return fmt.Errorf("%w: duplicate target %s", ErrInvalidInput, key)
+		}
+		seen[key] = true
+		totals[target.Scope] += target.TargetWeight
+	}
+	for scope, total := range totals {
+		if total > 100+1e-9 {
+			return fmt.Errorf("%w: %s target weights sum to %.2f%%", ErrInvalidInput, scope, total)
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM allocation_targets`); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, target := range targets {
+		_, err := tx.Exec(`
+			INSERT INTO allocation_targets (scope, scope_key, target_weight, tolerance, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, target.Scope, target.ScopeKey, target.TargetWeight, target.Tolerance, now, now)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// rebalanceUnit 参与再平衡的持仓；未持有但设有目标的基金 position 为空
+type rebalanceUnit struct {
+	position  *models.Position
+	accountID int64
+	fundCode  string
+	fundName  string
+	sector    string
+	value     float64
+	nav       float64
+	target    float64
+	targeted  bool
+	inBand    bool
+}
+
+// GetRebalancePlan 按目标配置计算 filter 范围内持仓的偏离与调仓建议，cash 为可新增投入的资金
+// 基金目标优先于板块目标；设有板块目标的板块中，未单独设目标的基金按市值比例分摊剩余的板块目标；
+// 偏离超出容忍度的目标调整回目标权重，其余目标只用剩余资金补足低配部分；没有目标的持仓保持不动
+func (s *FundService) GetRebalancePlan(filter AssetFilter, cash float64) (*RebalancePlan, error) {
+	if cash < 0 {
+		return nil, fmt.Errorf("%w: cash must not be negative", ErrInvalidInput)
+	}
+	targets, err := s.GetAllocationTargets()
+	if err != nil {
+		return nil, err
+	}
+	positions, err := s.FilterPositions(filter, false)
+	if err != nil {
+		return nil, err
+	}
+
+	fundTargets := make(map[string]models.AllocationTarget)
+	sectorTargets := make(map[string]models.AllocationTarget)
+	for _, target := range targets {
+		if target.Scope == models.AllocationFund {
+			fundTargets[target.ScopeKey] = target
+		} else {
+			sectorTargets[target.ScopeKey] = target
+		}
+	}
+
+	var units []*rebalanceUnit
+	var current float64
+	fundValues := make(map[string]float64)
+	sectorValues := make(map[string]float64)
+	for i := range positions {
+		pos := &positions[i]
+		if pos.Shares < shareEpsilon {
+			continue
+		}
+		// 外币份额的市值与净值折算为人民币，交易金额均以人民币计；尚无汇率时无法计算配置权重
+		if pos.FxRate == nil {
+			return nil, fxPendingError([]string{pos.FundCode})
+		}
+		nav := s.positionNav(pos) * *pos.FxRate
+		value := *pos.ValueCNY
+		if value == 0 {
+			value = pos.Shares * nav
+		}
+		units = append(units, &rebalanceUnit{
+			position:  pos,
+			accountID: pos.AccountID,
+			fundCode:  pos.FundCode,
+			fundName:  pos.FundName,
+			sector:    pos.Sector,
+			value:     value,
+			nav:       nav,
+		})
+		current += value
+		fundValues[pos.FundCode] += value
+		sectorValues[pos.Sector] += value
+	}
+
+	// 设有目标但尚未持有的基金在指定账户（未指定时为默认账户）中新建持仓买入
+	newAccount := models.DefaultAccountID
+	if filter.AccountID != nil {
+		newAccount = *filter.AccountID
+	}
+	for _, target := range targets {
+		code := target.ScopeKey
+		if _, ok := fundValues[code]; ok || target.Scope != models.AllocationFund {
+			continue
+		}
+		unit := &rebalanceUnit{accountID: newAccount, fundCode: code}
+		if fund, err := s.GetFundByCode(code); err == nil {
+			rate, err := fxRateOn(s.db, fund.Currency, time.Now())
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fxPendingError([]string{code})
+			}
+			if err != nil {
+				return nil, err
+			}
+			unit.fundName, unit.sector, unit.nav = fund.Name, fund.Sector, fund.Nav*rate
+		}
+		units = append(units, unit)
+	}
+
+	plan := &RebalancePlan{
+		TotalValue: round(current, 2),
+		Cash:       round(cash, 2),
+		Targets:    make([]AllocationDrift, 0, len(targets)),
+		Trades:     make([]RebalanceTrade, 0),
+	}
+	drifts := make(map[string]AllocationDrift, len(targets))
+	for _, target := range targets {
+		value := fundValues[target.ScopeKey]
+		if target.Scope == models.AllocationSector {
+			value = sectorValues[target.ScopeKey]
+		}
+		drift := AllocationDrift{
+			Scope:        target.Scope,
+			ScopeKey:     target.ScopeKey,
+			CurrentValue: round(value, 2),
+			TargetWeight: target.TargetWeight,
+			Tolerance:    target.Tolerance,
+		}
+		if current > 0 {
+			drift.CurrentWeight = round(value/current*100, 2)
+		}
+		drift.Drift = round(drift.CurrentWeight-target.TargetWeight, 2)
+		drift.OutOfBand = math.Abs(drift.CurrentWeight-target.TargetWeight) > target.Tolerance
+		drifts[target.Scope+":"+target.ScopeKey] = drift
+		plan.Targets = append(plan.Targets, drift)
+	}
+
+	assignTargets(units, fundTargets, sectorTargets, drifts, current+cash)
+	plan.Trades = s.rebalanceTrades(units, cash, plan)
+	return plan, nil
+}
+
+// assignTargets 计算各持仓的目标市值，total 为含新增资金的总资产
+func assignTargets(units []*rebalanceUnit, fundTargets, sectorTargets map[string]models.AllocationTarget,
+	drifts map[string]AllocationDrift, total float64) {
+	// 同一基金（或板块内未单独设目标的基金）的目标按当前市值比例分摊，均为 0 时平均分摊
+	share := func(members []*rebalanceUnit, targetValue float64) {
+		var sum float64
+		for _, unit := range members {
+			sum += unit.value
+		}
+		for _, unit := range members {
+			if sum > 0 {
+				unit.target = targetValue * unit.value / sum
+			} else {
+				unit.target = targetValue / float64(len(members))
+			}
+		}
+	}
+
+	byFund := make(map[string][]*rebalanceUnit)
+	bySector := make(map[string][]*rebalanceUnit)
+	fundTargetInSector := make(map[string]float64)
+	for _, unit := range units {
+		unit.target = unit.value
+		if target, ok := fundTargets[unit.fundCode]; ok {
+			unit.targeted = true
+			unit.inBand = !drifts[models.AllocationFund+":"+unit.fundCode].OutOfBand
+			if len(byFund[unit.fundCode]) == 0 {
+				fundTargetInSector[unit.sector] += target.TargetWeight / 100 * total
+			}
+			byFund[unit.fundCode] = append(byFund[unit.fundCode], unit)
+			continue
+		}
+		if _, ok := sectorTargets[unit.sector]; ok {
+			unit.targeted = true
+			unit.inBand = !drifts[models.AllocationSector+":"+unit.sector].OutOfBand
+			bySector[unit.sector] = append(bySector[unit.sector], unit)
+		}
+	}
+
+	for code, members := range byFund {
+		share(members, fundTargets[code].TargetWeight/100*total)
+	}
+	for sector, members := range bySector {
+		residual := sectorTargets[sector].TargetWeight/100*total - fundTargetInSector[sector]
+		share(members, math.Max(residual, 0))
+	}
+}
+
+// rebalanceTrades 生成调仓建议并汇总金额与费用
+func (s *FundService) rebalanceTrades(units []*rebalanceUnit, cash float64, plan *RebalancePlan) []RebalanceTrade {
+	amounts := make([]float64, len(units))
+	available := cash
+	for i, unit := range units {
+		if !unit.targeted || unit.inBand {
+			continue
+		}
+		amounts[i] = unit.target - unit.value
+		available -= amounts[i]
+	}
+
+	// 剩余资金按缺口比例补足容忍度内的低配持仓
+	if available > 0 {
+		var shortfall float64
+		for _, unit := range units {
+			if unit.targeted && unit.inBand && unit.target > unit.value {
+				shortfall += unit.target - unit.value
+			}
+		}
+		if shortfall > 0 {
+			ratio := math.Min(available/shortfall, 1)
+			for i, unit := range units {
+				if unit.targeted && unit.inBand && unit.target > unit.value {
+					amounts[i] = (unit.target - unit.value) * ratio
+				}
+			}
+		}
+	}
+
+	redeemDate := calendar.Default().NavDate(time.Now())
+	trades := make([]RebalanceTrade, 0)
+	remaining := cash
+	for i, unit := range units {
+		amount := round(amounts[i], 2)
+		if math.Abs(amount) < rebalanceMinAmount {
+			continue
+		}
+		trade := RebalanceTrade{
+			AccountID:    unit.accountID,
+			FundCode:     unit.fundCode,
+			FundName:     unit.fundName,
+			Sector:       unit.sector,
+			CurrentValue: round(unit.value, 2),
+			TargetValue:  round(unit.target, 2),
+			Nav:          unit.nav,
+		}
+		if unit.position != nil {
+			trade.PositionID = unit.position.ID
+		}
+
+		if amount > 0 {
+			trade.Action = RebalanceBuy
+			trade.Amount = amount
+			if schedule, err := s.feeSchedule(unit.fundCode); err != nil {
+				trade.FeeError = err.Error()
+			} else {
+				trade.FeeRate = schedule.PurchaseRate
+				trade.Fee = purchaseFee(trade.FeeRate, amount, 0, 0)
+				trade.FeeKnown = true
+			}
+			trade.NetAmount = round(amount-trade.Fee, 2)
+			plan.TotalBuy += amount
+			plan.PurchaseFees += trade.Fee
+			remaining -= amount
+		} else {
+			trade.Action = RebalanceSell
+			trade.Amount = -amount
+			if unit.nav > 0 {
+				trade.Shares = math.Min(round(trade.Amount/unit.nav, 2), unit.position.Shares)
+				fee, err := s.sellFee(unit.position.AccountID, unit.fundCode, trade.Shares, trade.Amount, redeemDate, nil)
+				if err != nil {
+					trade.FeeError = err.Error()
+				} else {
+					trade.Fee, trade.FeeKnown = fee, true
+				}
+			} else {
+				trade.FeeError = "fund has no nav"
+			}
+			if trade.Amount > 0 {
+				trade.FeeRate = round(trade.Fee/trade.Amount, 4)
+			}
+			trade.NetAmount = round(trade.Amount-trade.Fee, 2)
+			plan.TotalSell += trade.Amount
+			plan.RedemptionFees += trade.Fee
+			remaining += trade.NetAmount
+		}
+		trades = append(trades, trade)
+	}
+
+	// 先卖后买
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Action == RebalanceSell && trades[j].Action == RebalanceBuy
+	})
+
+	plan.TotalBuy = round(plan.TotalBuy, 2)
+	plan.TotalSell = round(plan.TotalSell, 2)
+	plan.PurchaseFees = round(plan.PurchaseFees, 2)
+	plan.RedemptionFees = round(plan.RedemptionFees, 2)
+	plan.CashRemaining = round(remaining, 2)
+	return trades
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+)
+
+// snapshotDeadlineHour 过了该时刻（北京时间）仍有基金未公布净值时，按已有数据写入当日快照
+const snapshotDeadlineHour = 22
+
+// 资产走势的时间粒度
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// AssetHistoryPoint 资产走势中的一个点
+// 按周、按月汇总时取区间内最后一个快照的市值与累计盈亏，DailyProfit 为区间内当日盈亏之和
+type AssetHistoryPoint struct {
+	Date             time.Time `json:"date"`
+	Value            float64   `json:"value"`
+	CostBasis        float64   `json:"cost_basis"`
+	DailyProfit      float64   `json:"daily_profit"`
+	RealizedProfit   float64   `json:"realized_profit"`
+	CumulativeProfit float64   `json:"cumulative_profit"`
+}
+
+// SnapshotIfReady 交易日收盘后，所有持仓基金应已公布的官方净值（A 股基金为当日，QDII 基金按净值滞后）
+// 都已入库（或已过截止时间）时写入当日快照，返回是否写入
+func (s *FundService) SnapshotIfReady(now time.Time) (bool, error) {
+	cal := calendar.Default()
+	if !cal.IsTradingDay(now) || !cal.IsAfterClose(now) {
+		return false, nil
+	}
+
+	if now.In(cal.Location()).Hour() < snapshotDeadlineHour {
+		waiting, err := s.awaitingNav(now)
+		if err != nil {
+			return false, err
+		}
+		if waiting {
+			return false, nil
+		}
+	}
+
+	return true, s.TakeSnapshot(now)
+}
+
+// awaitingNav 是否有未清仓持仓的基金尚未入库 now 时刻应已公布的最新净值
+func (s *FundService) awaitingNav(now time.Time) (bool, error) {
+	rows, err := s.db.Query(`
+		SELECT `+fundColumns+`
+		FROM funds
+		WHERE code IN (SELECT fund_code FROM positions WHERE status != ?)
+	`, models.PositionClosed)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		fund, err := scanFund(rows)
+		if err != nil {
+			return false, err
+		}
+		if fund.NavDate.IsZero() || fund.NavDate.Format(calendar.DateLayout) < publishedNavDate(fund, now).Format(calendar.DateLayout) {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// TakeSnapshot 按持仓当前估值写入 date 当日的持仓、板块与组合快照，已有快照时覆盖
+// 累计盈亏 = 市值 - 持仓成本 + 已实现盈亏；有外币份额持仓尚无汇率时不写入并返回 ErrInsufficientData
+func (s *FundService) TakeSnapshot(date time.Time) error {
+	positions, err := s.GetPositions(true)
+	if err != nil {
+		return err
+	}
+
+	// 同一基金在多个账户中的持仓合并为一条持仓快照
+	portfolio := &models.AssetSnapshot{Scope: models.SnapshotPortfolio}
+	funds := make(map[string]*models.AssetSnapshot)
+	sectors := make(map[string]*models.AssetSnapshot)
+	var fundOrder, sectorOrder []string
+	held := make(map[string]bool)
+	converted, pending := positionsInCNY(positions)
+	if len(pending) > 0 {
+		// 缺少汇率时合计市值不完整，不写入快照，待取得汇率后再写
+		return fxPendingError(pending)
+	}
+	for _, pos := range converted {
+		value, costBasis, dailyProfit := pos.CurrentValue, pos.CostBasis, pos.DailyProfit
+		// 尚未估值的持仓按最新官方净值计算市值；已清仓的持仓只计入已实现盈亏
+		if value == 0 && pos.Shares > 0 {
+			value = pos.Shares * s.positionNav(&pos) * *pos.FxRate
+		}
+		if pos.Status == models.PositionClosed {
+			value, costBasis, dailyProfit = 0, 0, 0
+		} else {
+			held[pos.FundCode] = true
+		}
+
+		fund, ok := funds[pos.FundCode]
+		if !ok {
+			fund = &models.AssetSnapshot{Scope: models.SnapshotPosition, ScopeKey: pos.FundCode}
+			funds[pos.FundCode] = fund
+			fundOrder = append(fundOrder, pos.FundCode)
+		}
+		sector, ok := sectors[pos.Sector]
+		if !ok {
+			sector = &models.AssetSnapshot{Scope: models.SnapshotSector, ScopeKey: pos.Sector}
+			sectors[pos.Sector] = sector
+			sectorOrder = append(sectorOrder, pos.Sector)
+		}
+		for _, total := range []*models.AssetSnapshot{fund, sector, portfolio} {
+			total.Value += value
+			total.CostBasis += costBasis
+			total.DailyProfit += dailyProfit
+			total.RealizedProfit += pos.RealizedProfit
+		}
+	}
+
+	var rows []*models.AssetSnapshot
+	for _, code := range fundOrder {
+		if held[code] {
+			rows = append(rows, funds[code])
+		}
+	}
+	for _, name := range sectorOrder {
+		rows = append(rows, sectors[name])
+	}
+	rows = append(rows, portfolio)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	snapshotDate := calendar.Default().FormatDate(date)
+	if _, err := tx.Exec(`DELETE FROM asset_snapshots WHERE snapshot_date = ?`, snapshotDate); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, row := range rows {
+		cumulative := row.Value - row.CostBasis + row.RealizedProfit
+		_, err := tx.Exec(`
+			INSERT INTO asset_snapshots
+				(snapshot_date, scope, scope_key, value, cost_basis, daily_profit, realized_profit,
+				 cumulative_profit, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, snapshotDate, row.Scope, row.ScopeKey, round(row.Value, 2), round(row.CostBasis, 2),
+			round(row.DailyProfit, 2), round(row.RealizedProfit, 2), round(cumulative, 2), now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetAssetHistory 查询 [from, to] 区间的资产走势，零值日期表示不限
+// scope 为 portfolio、sector 或 position，key 为板块名称或基金代码
+func (s *FundService) GetAssetHistory(from, to time.Time, granularity, scope, key string) ([]AssetHistoryPoint, error) {
+	switch granularity {
+	case "", GranularityDay, GranularityWeek, GranularityMonth:
+	default:
+		return nil, fmt.Errorf("%w: unknown granularity %q", ErrInvalidInput, granularity)
+	}
+	switch scope {
+	case "":
+		scope = models.SnapshotPortfolio
+	case models.SnapshotPortfolio, models.SnapshotSector, models.SnapshotPosition:
+	default:
+		return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidInput, scope)
+	}
+
+	query := `
+		SELECT snapshot_date, value, cost_basis, daily_profit, realized_profit, cumulative_profit
+		FROM asset_snapshots
+		WHERE scope = ? AND scope_key = ?
+	`
+	args := []interface{}{scope, key}
+	if !from.IsZero() {
+		query += " AND snapshot_date >= ?"
+		args = append(args, from.Format(calendar.DateLayout))
+	}
+	if !to.IsZero() {
+		query += " AND snapshot_date <= ?"
+		args = append(args, to.Format(calendar.DateLayout))
+	}
+	query += " ORDER BY snapshot_date"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := make([]AssetHistoryPoint, 0)
+	var lastPeriod string
+	for rows.Next() {
+		var point AssetHistoryPoint
+		err := rows.Scan(&point.Date, &point.Value, &point.CostBasis, &point.DailyProfit,
+			&point.RealizedProfit, &point.CumulativeProfit)
+		if err != nil {
+			return nil, err
+		}
+
+		period := historyPeriod(point.Date, granularity)
+		if n := len(points); n > 0 && period == lastPeriod {
+			point.DailyProfit = round(points[n-1].DailyProfit+point.DailyProfit, 2)
+			points[n-1] = point
+			continue
+		}
+		lastPeriod = period
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
+
+// historyPeriod 返回日期所属的汇总区间标识
+func historyPeriod(date time.Time, granularity string) string {
+	switch granularity {
+	case GranularityWeek:
+		year, week := date.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case GranularityMonth:
+		return date.Format("2006-01")
+	default:
+		return date.Format(calendar.DateLayout)
+	}
+}
@@ -0,0 +1,503 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+)
+
+// 回测策略
+const (
+	StrategyLumpSum        = "lump_sum"
+	StrategyFixed          = "fixed"
+	StrategyValueAveraging = "value_averaging"
+	StrategySmart          = "smart"
+)
+
+// 回测参数默认值
+const (
+	defaultBacktestMADays      = 250
+	defaultBacktestMaxMultiple = 3.0
+)
+
+// smartTier 均线偏离智能定投的扣款倍数：偏离度不低于 Above 时按 Multiple 扣款
+type smartTier struct {
+	Above    float64
+	Multiple float64
+}
+
+// smartTiers 按偏离度从高到低排列：高于均线少投，低于均线多投
+var smartTiers = []smartTier{
+	{1.00, 0.6},
+	{0.50, 0.7},
+	{0.15, 0.8},
+	{0, 0.9},
+	{-0.05, 1.6},
+	{-0.10, 1.7},
+	{-0.20, 1.8},
+	{-0.30, 1.9},
+	{-0.40, 2.0},
+}
+
+// smartMultipleBelow 偏离度低于所有档位时的扣款倍数
+const smartMultipleBelow = 2.1
+
+// BacktestFund 回测标的，Weight 为每期金额分配的百分比，均为 0 时等权分配
+type BacktestFund struct {
+	Code   string  `json:"code"`
+	Weight float64 `json:"weight"`
+}
+
+// BacktestParams 回测参数
+// Amount 为一次性投入的总额或每期定投金额；按周、双周定投时 Day 为星期几，按月定投时为每月几号；
+// FeeRate 为空时使用各基金费率表的申购费率；价值平均每期投入不超过 MaxMultiple 倍的 Amount，不做卖出；
+// 智能定投按 MADays 日均线的偏离度调整每期扣款倍数
+type BacktestParams struct {
+	Name        string         `json:"name"`
+	Strategy    string         `json:"strategy"`
+	Funds       []BacktestFund `json:"funds"`
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Amount      float64        `json:"amount"`
+	Frequency   string         `json:"frequency"`
+	Day         int            `json:"day"`
+	FeeRate     *float64       `json:"fee_rate"`
+	MADays      int            `json:"ma_days"`
+	MaxMultiple float64        `json:"max_multiple"`
+}
+
+// BacktestPoint 回测净值曲线上的一天
+type BacktestPoint struct {
+	Date     time.Time `json:"date"`
+	Invested float64   `json:"invested"`
+	Value    float64   `json:"value"`
+	Profit   float64   `json:"profit"`
+}
+
+// BacktestTrade 回测中的一笔申购，Multiple 为智能定投的扣款倍数
+type BacktestTrade struct {
+	Date     time.Time `json:"date"`
+	FundCode string    `json:"fund_code"`
+	Amount   float64   `json:"amount"`
+	Fee      float64   `json:"fee"`
+	Nav      float64   `json:"nav"`
+	Shares   float64   `json:"shares"`
+	Multiple float64   `json:"multiple,omitempty"`
+}
+
+// BacktestResult 回测结果
+// ReturnRate 与 MaxDrawdown 为百分比，最大回撤按剔除投入后的净值序列计算；XIRR 为年化资金加权收益率（%）
+type BacktestResult struct {
+	ID             int64           `json:"id"`
+	Name           string          `json:"name"`
+	Strategy       string          `json:"strategy"`
+	Params         BacktestParams  `json:"params"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	TotalInvested  float64         `json:"total_invested"`
+	TotalFees      float64         `json:"total_fees"`
+	FinalValue     float64         `json:"final_value"`
+	Profit         float64         `json:"profit"`
+	ReturnRate     float64         `json:"return_rate"`
+	XIRR           *float64        `json:"xirr"`
+	MaxDrawdown    float64         `json:"max_drawdown"`
+	DrawdownStart  *time.Time      `json:"drawdown_start"`
+	DrawdownTrough *time.Time      `json:"drawdown_trough"`
+	TradeCount     int             `json:"trade_count"`
+	Equity         []BacktestPoint `json:"equity,omitempty"`
+	Trades         []BacktestTrade `json:"trades,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// BacktestService 定投策略回测服务
+type BacktestService struct {
+	db          *sql.DB
+	fundService *FundService
+	riskService *RiskService
+}
+
+// NewBacktestService 创建回测服务，历史净值的回填与缓存复用风险指标服务
+func NewBacktestService(fundService *FundService, riskService *RiskService) *BacktestService {
+	return &BacktestService{
+		db:          models.GetDB(),
+		fundService: fundService,
+		riskService: riskService,
+	}
+}
+
+// backtestSeries 单只基金的历史净值与模拟持仓
+type backtestSeries struct {
+	code    string
+	weight  float64
+	feeRate float64
+	navs    []valuationPoint
+	next    int
+	nav     float64
+	shares  float64
+}
+
+// advance 将当前净值推进到 date 当日（含）之前最近的净值
+func (f *backtestSeries) advance(date time.Time) {
+	for ; f.next < len(f.navs) && !f.navs[f.next].Date.After(date); f.next++ {
+		f.nav = f.navs[f.next].Value
+	}
+}
+
+// deviation 当前净值相对 days 日均线的偏离度，历史不足时返回 false
+func (f *backtestSeries) deviation(days int) (float64, bool) {
+	if f.next < days || f.nav <= 0 {
+		return 0, false
+	}
+	var sum float64
+	for _, point := range f.navs[f.next-days : f.next] {
+		sum += point.Value
+	}
+	return f.nav/(sum/float64(days)) - 1, true
+}
+
+// smartMultiple 按均线偏离度返回智能定投的扣款倍数
+func smartMultiple(deviation float64) float64 {
+	for _, tier := range smartTiers {
+		if deviation >= tier.Above {
+			return tier.Multiple
+		}
+	}
+	return smartMultipleBelow
+}
+
+// RunBacktest 按历史净值回放策略并保存结果，本地净值不足时先回填
+func (s *BacktestService) RunBacktest(params BacktestParams) (*BacktestResult, error) {
+	if err := validateBacktest(&params); err != nil {
+		return nil, err
+	}
+
+	// 智能定投需要开始日期之前的净值计算均线
+	loadFrom := params.From
+	if params.Strategy == StrategySmart {
+		loadFrom = params.From.AddDate(0, 0, -params.MADays*7/5-30)
+	}
+
+	funds := make([]*backtestSeries, 0, len(params.Funds))
+	dates := make(map[time.Time]bool)
+	for _, fund := range params.Funds {
+		s.riskService.ensureNavs(fund.Code, loadFrom, params.To)
+		history, err := s.riskService.navService.GetAdjustedNavHistory(fund.Code, loadFrom, params.To)
+		if err != nil {
+			return nil, err
+		}
+		series := &backtestSeries{code: fund.Code, weight: fund.Weight / 100}
+		if params.FeeRate != nil {
+			series.feeRate = *params.FeeRate
+		} else {
+			schedule, err := s.fundService.feeSchedule(fund.Code)
+			if err != nil {
+				return nil, err
+			}
+			series.feeRate = schedule.PurchaseRate
+		}
+		for _, nav := range history {
+			if nav.AdjNav <= 0 {
+				continue
+			}
+			point := valuationPoint{Date: dateOnly(nav.NavDate), Value: nav.AdjNav}
+			series.navs = append(series.navs, point)
+			if !point.Date.Before(params.From) {
+				dates[point.Date] = true
+			}
+		}
+		funds = append(funds, series)
+	}
+	if len(dates) == 0 {
+		return nil, fmt.Errorf("%w: no nav history between %s and %s", ErrInsufficientData,
+			params.From.Format(calendar.DateLayout), params.To.Format(calendar.DateLayout))
+	}
+	days := make([]time.Time, 0, len(dates))
+	for date := range dates {
+		days = append(days, date)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	buyDays := backtestBuyDays(params, days)
+
+	result := &BacktestResult{
+		Name:     params.Name,
+		Strategy: params.Strategy,
+		Params:   params,
+		From:     days[0],
+		To:       days[len(days)-1],
+		Equity:   make([]BacktestPoint, 0, len(days)),
+		Trades:   make([]BacktestTrade, 0),
+	}
+	var flows []CashFlow
+	var valuations []valuationPoint
+	periods := 0
+	for _, day := range days {
+		for _, fund := range funds {
+			fund.advance(day)
+		}
+
+		if buyDays[day] {
+			periods++
+			total := params.Amount
+			if params.Strategy == StrategyValueAveraging {
+				// 价值平均：使市值达到 期数 × 每期金额，只买不卖
+				var value float64
+				for _, fund := range funds {
+					value += fund.shares * fund.nav
+				}
+				total = math.Min(math.Max(float64(periods)*params.Amount-value, 0), params.MaxMultiple*params.Amount)
+			}
+
+			for _, fund := range funds {
+				// 尚未成立的基金跳过
+				if fund.nav <= 0 {
+					continue
+				}
+				amount := total * fund.weight
+				trade := BacktestTrade{Date: day, FundCode: fund.code, Nav: fund.nav}
+				if params.Strategy == StrategySmart {
+					trade.Multiple = 1
+					if deviation, ok := fund.deviation(params.MADays); ok {
+						trade.Multiple = smartMultiple(deviation)
+					}
+					amount *= trade.Multiple
+				}
+				amount = round(amount, 2)
+				if amount <= 0 {
+					continue
+				}
+
+				trade.Amount = amount
+				trade.Fee = purchaseFee(fund.feeRate, amount, 0, 0)
+				trade.Shares = round((amount-trade.Fee)/fund.nav, 2)
+				fund.shares += trade.Shares
+				result.Trades = append(result.Trades, trade)
+				result.TotalInvested += amount
+				result.TotalFees += trade.Fee
+				flows = append(flows, CashFlow{Date: day, Amount: -amount})
+			}
+		}
+
+		var value float64
+		for _, fund := range funds {
+			value += fund.shares * fund.nav
+		}
+		valuations = append(valuations, valuationPoint{Date: day, Value: value})
+		result.Equity = append(result.Equity, BacktestPoint{
+			Date:     day,
+			Invested: round(result.TotalInvested, 2),
+			Value:    round(value, 2),
+			Profit:   round(value-result.TotalInvested, 2),
+		})
+	}
+
+	last := valuations[len(valuations)-1]
+	result.FinalValue = round(last.Value, 2)
+	result.TotalInvested = round(result.TotalInvested, 2)
+	result.TotalFees = round(result.TotalFees, 2)
+	result.Profit = round(result.FinalValue-result.TotalInvested, 2)
+	result.TradeCount = len(result.Trades)
+	if result.TotalInvested > 0 {
+		result.ReturnRate = round(result.Profit/result.TotalInvested*100, 2)
+	}
+	if rate, ok := xirr(append(append([]CashFlow(nil), flows...), CashFlow{Date: last.Date, Amount: last.Value})); ok {
+		rate = round(rate*100, 2)
+		result.XIRR = &rate
+	}
+
+	// 剔除投入后的每日收益连乘得到净值序列，以此计算最大回撤
+	series := []valuationPoint{{Date: days[0].AddDate(0, 0, -1), Value: 1}}
+	wealth := 1.0
+	for _, point := range flowAdjustedReturns(valuations, flows, days[0].AddDate(0, 0, -1), last.Date) {
+		wealth *= 1 + point.Value
+		series = append(series, valuationPoint{Date: point.Date, Value: wealth})
+	}
+	var drawdown RiskMetrics
+	applyMaxDrawdown(&drawdown, series)
+	result.MaxDrawdown = drawdown.MaxDrawdown
+	result.DrawdownStart = drawdown.DrawdownStart
+	result.DrawdownTrough = drawdown.DrawdownTrough
+
+	if err := s.saveBacktest(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// validateBacktest 校验回测参数并补全默认值
+func validateBacktest(params *BacktestParams) error {
+	switch params.Strategy {
+	case StrategyLumpSum, StrategyFixed, StrategyValueAveraging, StrategySmart:
+	default:
+		return fmt.Errorf("%w: unknown strategy %q", ErrInvalidInput, params.Strategy)
+	}
+	if len(params.Funds) == 0 {
+		return fmt.Errorf("%w: at least one fund is required", ErrInvalidInput)
+	}
+	if params.Amount <= 0 {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidInput)
+	}
+	if params.From.IsZero() {
+		return fmt.Errorf("%w: from is required", ErrInvalidInput)
+	}
+	params.From = dateOnly(params.From)
+	if params.To.IsZero() {
+		params.To = dateOnly(time.Now())
+	}
+	params.To = dateOnly(params.To)
+	if !params.To.After(params.From) {
+		return fmt.Errorf("%w: to must be after from", ErrInvalidInput)
+	}
+	if params.FeeRate != nil && (*params.FeeRate < 0 || *params.FeeRate >= 1) {
+		return fmt.Errorf("%w: fee_rate must be in [0, 1)", ErrInvalidInput)
+	}
+
+	// 权重均为 0 时等权分配，否则按权重占比分配
+	var totalWeight float64
+	seen := make(map[string]bool)
+	for i := range params.Funds {
+		fund := &params.Funds[i]
+		fund.Code = strings.TrimSpace(fund.Code)
+		if fund.Code == "" || fund.Weight < 0 {
+			return fmt.Errorf("%w: fund code is required and weight must not be negative", ErrInvalidInput)
+		}
+		if seen[fund.Code] {
+			return fmt.Errorf("%w: duplicate fund %s", ErrInvalidInput, fund.Code)
+		}
+		seen[fund.Code] = true
+		totalWeight += fund.Weight
+	}
+	for i := range params.Funds {
+		if totalWeight > 0 {
+			params.Funds[i].Weight = params.Funds[i].Weight / totalWeight * 100
+		} else {
+			params.Funds[i].Weight = 100 / float64(len(params.Funds))
+		}
+	}
+
+	if params.Strategy != StrategyLumpSum {
+		if params.Frequency == "" {
+			params.Frequency = models.PlanMonthly
+		}
+		if params.Day == 0 {
+			params.Day = 1
+		}
+		if err := validateSchedule(params.Frequency, params.Day); err != nil {
+			return err
+		}
+	}
+	if params.Strategy == StrategySmart && params.MADays <= 0 {
+		params.MADays = defaultBacktestMADays
+	}
+	if params.Strategy == StrategyValueAveraging && params.MaxMultiple <= 0 {
+		params.MaxMultiple = defaultBacktestMaxMultiple
+	}
+	return nil
+}
+
+// backtestBuyDays 返回扣款所用的净值日：一次性投入为首个净值日，定投按计划扣款日取当日或之后最近的净值日
+func backtestBuyDays(params BacktestParams, days []time.Time) map[time.Time]bool {
+	buyDays := make(map[time.Time]bool)
+	if params.Strategy == StrategyLumpSum {
+		buyDays[days[0]] = true
+		return buyDays
+	}
+
+	end := params.To
+	plan := &models.InvestmentPlan{
+		Frequency: params.Frequency,
+		Day:       params.Day,
+		StartDate: params.From,
+		EndDate:   &end,
+	}
+	for _, date := range planDates(plan, params.From, params.To) {
+		i := sort.Search(len(days), func(i int) bool { return !days[i].Before(date) })
+		if i < len(days) {
+			buyDays[days[i]] = true
+		}
+	}
+	return buyDays
+}
+
+// saveBacktest 保存回测参数与结果
+func (s *BacktestService) saveBacktest(result *BacktestResult) error {
+	params, err := json.Marshal(result.Params)
+	if err != nil {
+		return err
+	}
+	result.CreatedAt = time.Now()
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.Exec(`
+		INSERT INTO backtests
+			(name, strategy, params, result, total_invested, final_value, return_rate, xirr, max_drawdown, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, result.Name, result.Strategy, string(params), string(data), result.TotalInvested, result.FinalValue,
+		result.ReturnRate, result.XIRR, result.MaxDrawdown, result.CreatedAt)
+	if err != nil {
+		return err
+	}
+	result.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// GetBacktests 列出已保存的回测结果（不含净值曲线与交易明细），按创建时间倒序，便于比较
+func (s *BacktestService) GetBacktests() ([]BacktestResult, error) {
+	rows, err := s.db.Query(`SELECT id, result, created_at FROM backtests ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]BacktestResult, 0)
+	for rows.Next() {
+		result, err := scanBacktest(rows)
+		if err != nil {
+			return nil, err
+		}
+		result.Equity, result.Trades = nil, nil
+		results = append(results, *result)
+	}
+	return results, rows.Err()
+}
+
+// GetBacktest 获取一次回测的完整结果
+func (s *BacktestService) GetBacktest(id int64) (*BacktestResult, error) {
+	return scanBacktest(s.db.QueryRow(`SELECT id, result, created_at FROM backtests WHERE id = ?`, id))
+}
+
+// DeleteBacktest 删除已保存的回测结果
+func (s *BacktestService) DeleteBacktest(id int64) error {
+	result, err := s.db.Exec(`DELETE FROM backtests WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanBacktest(row rowScanner) (*BacktestResult, error) {
+	var backtest models.Backtest
+	if err := row.Scan(&backtest.ID, &backtest.Result, &backtest.CreatedAt); err != nil {
+		return nil, err
+	}
+	var result BacktestResult
+	if err := json.Unmarshal([]byte(backtest.Result), &result); err != nil {
+		return nil, err
+	}
+	result.ID = backtest.ID
+	result.CreatedAt = backtest.CreatedAt
+	return &result, nil
+}
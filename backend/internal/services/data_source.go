@@ -0,0 +1,141 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/scrapers"
+)
+
+// FundDataSource 基金数据源
+type FundDataSource interface {
+	Name() string
+	FetchEstimate(code string) (*scrapers.FundEstimate, error)
+	FetchNav(code string) (*scrapers.FundNav, error)
+	FetchMetadata(code string) (*scrapers.FundMetadata, error)
+}
+
+// DataSourceRegistry 数据源注册表
+type DataSourceRegistry struct {
+	sources map[string]FundDataSource
+}
+
+// NewDataSourceRegistry 创建数据源注册表
+func NewDataSourceRegistry(sources ...FundDataSource) *DataSourceRegistry {
+	registry := &DataSourceRegistry{sources: make(map[string]FundDataSource)}
+	for _, source := range sources {
+		registry.Register(source)
+	}
+	return registry
+}
+
+// Register 注册数据源
+func (r *DataSourceRegistry) Register(source FundDataSource) {
+	r.sources[source.Name()] = source
+}
+
+// Get 按名称获取数据源
+func (r *DataSourceRegistry) Get(name string) (FundDataSource, bool) {
+	source, ok := r.sources[name]
+	return source, ok
+}
+
+// Chain 按给定顺序构建故障转移链
+func (r *DataSourceRegistry) Chain(names []string, staleAfter time.Duration) (*DataSourceChain, error) {
+	chain := &DataSourceChain{staleAfter: staleAfter}
+	for _, name := range names {
+		source, ok := r.sources[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown data source %q", name)
+		}
+		chain.sources = append(chain.sources, source)
+	}
+	if len(chain.sources) == 0 {
+		return nil, errors.New("no data source configured")
+	}
+	return chain, nil
+}
+
+// DataSourceChain 数据源故障转移链
+// 按优先级依次尝试，数据源出错或数据过期时尝试下一个；
+// 全部过期时返回其中最新的一份
+type DataSourceChain struct {
+	sources    []FundDataSource
+	staleAfter time.Duration
+}
+
+// Sources 返回链中的数据源
+func (c *DataSourceChain) Sources() []FundDataSource {
+	return c.sources
+}
+
+// FetchEstimate 获取实时估值
+func (c *DataSourceChain) FetchEstimate(code string) (*scrapers.FundEstimate, error) {
+	var freshest *scrapers.FundEstimate
+	var errs []error
+
+	for _, source := range c.sources {
+		estimate, err := source.FetchEstimate(code)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+			continue
+		}
+		// 非交易时段估值不再更新，不按时长判断过期
+		if !calendar.Default().IsTradingTime(time.Now()) || time.Since(estimate.EstimateTime) <= c.staleAfter {
+			return estimate, nil
+		}
+		if freshest == nil || estimate.EstimateTime.After(freshest.EstimateTime) {
+			freshest = estimate
+		}
+	}
+
+	if freshest != nil {
+		return freshest, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// FetchNav 获取最新官方净值
+func (c *DataSourceChain) FetchNav(code string) (*scrapers.FundNav, error) {
+	var freshest *scrapers.FundNav
+	var errs []error
+
+	// 上一个交易日的净值此时必然已经公布，早于它即视为过期
+	cal := calendar.Default()
+	expected := cal.FormatDate(cal.PrevTradingDay(time.Now()))
+
+	for _, source := range c.sources {
+		nav, err := source.FetchNav(code)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+			continue
+		}
+		if nav.NavDate >= expected {
+			return nav, nil
+		}
+		if freshest == nil || nav.NavDate > freshest.NavDate {
+			freshest = nav
+		}
+	}
+
+	if freshest != nil {
+		return freshest, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// FetchMetadata 获取基金基础信息
+func (c *DataSourceChain) FetchMetadata(code string) (*scrapers.FundMetadata, error) {
+	var errs []error
+	for _, source := range c.sources {
+		metadata, err := source.FetchMetadata(code)
+		if err == nil {
+			return metadata, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+	}
+	return nil, errors.Join(errs...)
+}
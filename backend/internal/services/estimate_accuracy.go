@@ -0,0 +1,238 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+)
+
+// marketCloseMinute 收盘时间（北京时间 15:00），之后的估值不再视为盘前估值
+const marketCloseMinute = 15 * 60
+
+// AccuracyStats 估值准确度统计，误差单位为百分点
+type AccuracyStats struct {
+	Source  string  `json:"source"`
+	Days    int     `json:"days"`
+	MAE     float64 `json:"mae"`
+	Bias    float64 `json:"bias"`
+	HitRate float64 `json:"hit_rate"`
+	MaxMiss float64 `json:"max_miss"`
+}
+
+// AccuracyReport 基金估值准确度报告
+type AccuracyReport struct {
+	Code     string          `json:"code"`
+	Days     int             `json:"days"`
+	Overall  AccuracyStats   `json:"overall"`
+	BySource []AccuracyStats `json:"by_source"`
+}
+
+// RecordEstimateSnapshot 记录交易日收盘前的最后一次估值，同一交易日同一来源只保留最新一条
+func (s *EstimateService) RecordEstimateSnapshot(estimate *EstimateResult) error {
+	if estimate.EstimateSource == "" || estimate.EstimateTime.IsZero() || estimate.EstimateNav <= 0 {
+		return nil
+	}
+	// QDII 基金的估值对应的净值日期不是估值当日，不计入准确度统计
+	if estimate.Market != "" && estimate.Market != calendar.MarketCN {
+		return nil
+	}
+
+	cal := calendar.Default()
+	estimateTime := estimate.EstimateTime.In(cal.Location())
+	if !cal.IsTradingDay(estimateTime) {
+		return nil
+	}
+	if estimateTime.Hour()*60+estimateTime.Minute() > marketCloseMinute {
+		return nil
+	}
+
+	now := time.Now()
+	_, err := s.db.Exec(`
+		INSERT INTO estimate_snapshots
+			(fund_code, trade_date, source, estimate_nav, estimate_growth, estimate_time, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (fund_code, trade_date, source) DO UPDATE SET
+			estimate_nav = excluded.estimate_nav,
+			estimate_growth = excluded.estimate_growth,
+			estimate_time = excluded.estimate_time,
+			updated_at = excluded.updated_at
+		WHERE settled = 0 AND excluded.estimate_time >= estimate_snapshots.estimate_time
+	`, estimate.Code, cal.FormatDate(estimateTime), estimate.EstimateSource,
+		estimate.EstimateNav, estimate.DailyGrowth, estimateTime, now, now)
+	return err
+}
+
+// RecordHoldingsSnapshot 按重仓股加权估算另记一条估值快照，来源为 holdings，与上游估值分开统计准确度；
+// 仅在交易时段内、且已抓取过重仓股的基金上估算，避免为没有股票持仓的基金反复抓取
+func (s *EstimateService) RecordHoldingsSnapshot(fund *models.Fund) error {
+	if isQDII(fund) || isMoneyMarket(fund) || !calendar.Default().IsTradingTime(time.Now()) {
+		return nil
+	}
+	holdings, err := s.getStoredHoldings(fund.Code)
+	if err != nil || len(holdings) == 0 {
+		return err
+	}
+
+	estimate, err := s.EstimateByHoldings(fund.Code)
+	if err != nil {
+		return err
+	}
+	return s.RecordEstimateSnapshot(&EstimateResult{
+		Code:           estimate.Code,
+		Name:           estimate.Name,
+		Nav:            estimate.Nav,
+		EstimateNav:    estimate.EstimateNav,
+		DailyGrowth:    estimate.DailyGrowth,
+		EstimateTime:   estimate.EstimateTime,
+		EstimateSource: EstimateMethodHoldings,
+		Market:         fund.Market,
+	})
+}
+
+// SettleEstimateSnapshots 用已公布的官方净值结算未结算的估值快照
+func (s *EstimateService) SettleEstimateSnapshots() error {
+	rows, err := s.db.Query(`
+		SELECT e.id, e.estimate_nav, e.estimate_growth, h.nav, h.daily_growth
+		FROM estimate_snapshots e
+		JOIN nav_history h ON h.fund_code = e.fund_code AND h.nav_date = e.trade_date
+		WHERE e.settled = 0
+	`)
+	if err != nil {
+		return err
+	}
+
+	type settlement struct {
+		id                      int64
+		actualNav, actualGrowth float64
+		growthError, navError   float64
+	}
+	var settlements []settlement
+	for rows.Next() {
+		var id int64
+		var estimateNav, estimateGrowth, actualNav, actualGrowth float64
+		if err := rows.Scan(&id, &estimateNav, &estimateGrowth, &actualNav, &actualGrowth); err != nil {
+			rows.Close()
+			return err
+		}
+		settlements = append(settlements, settlement{
+			id:           id,
+			actualNav:    actualNav,
+			actualGrowth: actualGrowth,
+			growthError:  estimateGrowth - actualGrowth,
+			navError:     estimateNav - actualNav,
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, item := range settlements {
+		_, err := s.db.Exec(`
+			UPDATE estimate_snapshots
+			SET actual_nav = ?, actual_growth = ?, growth_error = ?, nav_error = ?, settled = 1, updated_at = ?
+			WHERE id = ?
+		`, item.actualNav, item.actualGrowth, item.growthError, item.navError, now, item.id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAccuracy 统计基金近 days 天已结算估值的误差，按数据源分组
+// MAE 为平均绝对误差，Bias 为平均误差（正值表示估高），HitRate 为涨跌方向判断正确的比例
+func (s *EstimateService) GetAccuracy(code string, days int) (*AccuracyReport, error) {
+	since := calendar.Default().FormatDate(time.Now().AddDate(0, 0, -days))
+	rows, err := s.db.Query(`
+		SELECT source, estimate_growth, actual_growth, growth_error
+		FROM estimate_snapshots
+		WHERE fund_code = ? AND settled = 1 AND trade_date >= ?
+		ORDER BY trade_date
+	`, code, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overall := &accuracyAccumulator{}
+	bySource := make(map[string]*accuracyAccumulator)
+	var sources []string
+	for rows.Next() {
+		var source string
+		var estimateGrowth, actualGrowth, growthError float64
+		if err := rows.Scan(&source, &estimateGrowth, &actualGrowth, &growthError); err != nil {
+			return nil, err
+		}
+
+		if _, ok := bySource[source]; !ok {
+			bySource[source] = &accuracyAccumulator{}
+			sources = append(sources, source)
+		}
+		overall.add(estimateGrowth, actualGrowth, growthError)
+		bySource[source].add(estimateGrowth, actualGrowth, growthError)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &AccuracyReport{
+		Code:     code,
+		Days:     days,
+		Overall:  overall.stats("all"),
+		BySource: make([]AccuracyStats, 0, len(sources)),
+	}
+	for _, source := range sources {
+		report.BySource = append(report.BySource, bySource[source].stats(source))
+	}
+	return report, nil
+}
+
+// accuracyAccumulator 误差累加器
+type accuracyAccumulator struct {
+	count   int
+	absSum  float64
+	sum     float64
+	hits    int
+	maxMiss float64
+}
+
+func (a *accuracyAccumulator) add(estimateGrowth, actualGrowth, growthError float64) {
+	a.count++
+	a.absSum += math.Abs(growthError)
+	a.sum += growthError
+	if sign(estimateGrowth) == sign(actualGrowth) {
+		a.hits++
+	}
+	if math.Abs(growthError) > math.Abs(a.maxMiss) {
+		a.maxMiss = growthError
+	}
+}
+
+func (a *accuracyAccumulator) stats(source string) AccuracyStats {
+	stats := AccuracyStats{Source: source, Days: a.count}
+	if a.count == 0 {
+		return stats
+	}
+	stats.MAE = round(a.absSum/float64(a.count), 4)
+	stats.Bias = round(a.sum/float64(a.count), 4)
+	stats.HitRate = round(float64(a.hits)/float64(a.count)*100, 2)
+	stats.MaxMiss = round(a.maxMiss, 4)
+	return stats
+}
+
+// sign 返回数值的符号：-1、0 或 1
+func sign(value float64) int {
+	switch {
+	case value > 0:
+		return 1
+	case value < 0:
+		return -1
+	default:
+		return 0
+	}
+}
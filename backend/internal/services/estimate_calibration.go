@@ -0,0 +1,107 @@
+package services
+
+import "math"
+
+// minCalibrationSamples 拟合修正模型所需的最少样本数
+const minCalibrationSamples = 10
+
+// CalibrationModel 估值偏差修正模型：实际涨幅 = Intercept + Slope × 估算涨幅
+// RMSE 为修正后的残差均方根，RawRMSE 为未修正估值的误差均方根，单位均为百分点
+type CalibrationModel struct {
+	Source    string  `json:"source"`
+	Samples   int     `json:"samples"`
+	Intercept float64 `json:"intercept"`
+	Slope     float64 `json:"slope"`
+	RSquared  float64 `json:"r_squared"`
+	RMSE      float64 `json:"rmse"`
+	RawRMSE   float64 `json:"raw_rmse"`
+}
+
+// Apply 用模型修正估算涨幅
+func (m *CalibrationModel) Apply(estimateGrowth float64) float64 {
+	return m.Intercept + m.Slope*estimateGrowth
+}
+
+// FitCalibration 用最近 CalibrationWindow 个已结算交易日的估值与官方涨幅拟合线性修正模型
+// source 非空时只使用该数据源的样本；样本不足时返回 nil
+func (s *EstimateService) FitCalibration(code, source string) (*CalibrationModel, error) {
+	query := `
+		SELECT estimate_growth, actual_growth
+		FROM estimate_snapshots
+		WHERE fund_code = ? AND settled = 1
+	`
+	args := []interface{}{code}
+	if source != "" {
+		query += " AND source = ?"
+		args = append(args, source)
+	}
+	query += " ORDER BY trade_date DESC LIMIT ?"
+	args = append(args, s.cfg.CalibrationWindow)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var xs, ys []float64
+	for rows.Next() {
+		var x, y float64
+		if err := rows.Scan(&x, &y); err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+		ys = append(ys, y)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(xs) < minCalibrationSamples {
+		return nil, nil
+	}
+
+	model := fitLinear(xs, ys)
+	model.Source = source
+	return model, nil
+}
+
+// fitLinear 最小二乘拟合 y = a + b·x；x 无波动时退化为仅修正平均偏差
+func fitLinear(xs, ys []float64) *CalibrationModel {
+	n := float64(len(xs))
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	var sxx, sxy, syy, rawSS float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+		rawSS += (ys[i] - xs[i]) * (ys[i] - xs[i])
+	}
+
+	model := &CalibrationModel{Samples: len(xs), Slope: 1}
+	if sxx > 0 {
+		model.Slope = sxy / sxx
+	}
+	model.Intercept = meanY - model.Slope*meanX
+
+	var residualSS float64
+	for i := range xs {
+		residual := ys[i] - model.Apply(xs[i])
+		residualSS += residual * residual
+	}
+	if syy > 0 {
+		model.RSquared = round(1-residualSS/syy, 4)
+	}
+	model.Intercept = round(model.Intercept, 4)
+	model.Slope = round(model.Slope, 4)
+	model.RMSE = round(math.Sqrt(residualSS/n), 4)
+	model.RawRMSE = round(math.Sqrt(rawSS/n), 4)
+	return model
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFitLinear(t *testing.T) {
+	tests := []struct {
+		name      string
+		xs, ys    []float64
+		intercept float64
+		slope     float64
+		rSquared  float64
+		rmse      float64
+		rawRMSE   float64
+	}{
+		{
+			name:      "exact line",
+			xs:        []float64{-1, 0, 1, 2},
+			ys:        []float64{-1.5, 0.5, 2.5, 4.5},
+			intercept: 0.5,
+			slope:     2,
+			rSquared:  1,
+			rmse:      0,
+			rawRMSE:   1.5,
+		},
+		{
+			name:      "estimates already accurate",
+			xs:        []float64{-0.8, 0.3, 1.2},
+			ys:        []float64{-0.8, 0.3, 1.2},
+			intercept: 0,
+			slope:     1,
+			rSquared:  1,
+			rmse:      0,
+			rawRMSE:   0,
+		},
+		{
+			name:      "constant estimates only correct the bias",
+			xs:        []float64{1, 1, 1},
+			ys:        []float64{1.1, 1.3, 1.2},
+			intercept: 0.2,
+			slope:     1,
+			rSquared:  0,
+			rmse:      0.0816,
+			rawRMSE:   0.216,
+		},
+		{
+			name:      "noisy samples",
+			xs:        []float64{0, 1, 2, 3},
+			ys:        []float64{0.1, 0.9, 2.1, 2.9},
+			intercept: 0.06,
+			slope:     0.96,
+			rSquared:  0.9931,
+			rmse:      0.0894,
+			rawRMSE:   0.1,
+		},
+	}
+	for _, tt := range tests {
+		model := fitLinear(tt.xs, tt.ys)
+		if model.Samples != len(tt.xs) {
+			t.Errorf("%s: samples = %d, want %d", tt.name, model.Samples, len(tt.xs))
+		}
+		got := []float64{model.Intercept, model.Slope, model.RSquared, model.RMSE, model.RawRMSE}
+		want := []float64{tt.intercept, tt.slope, tt.rSquared, tt.rmse, tt.rawRMSE}
+		names := []string{"intercept", "slope", "r_squared", "rmse", "raw_rmse"}
+		for i := range got {
+			if math.Abs(got[i]-want[i]) > 1e-4 {
+				t.Errorf("%s: %s = %v, want %v", tt.name, names[i], got[i], want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"fundnet/backend/internal/models"
+)
+
+// holdingsRefreshAfter 持仓报告期早于该时长时重新抓取（季报披露周期）
+const holdingsRefreshAfter = 120 * 24 * time.Hour
+
+// 估算方式
+const (
+	EstimateMethodUpstream = "upstream"
+	EstimateMethodHoldings = "holdings"
+)
+
+// HoldingContribution 单只重仓股对估算的贡献
+type HoldingContribution struct {
+	Symbol       string  `json:"symbol"`
+	Name         string  `json:"name"`
+	Weight       float64 `json:"weight"`
+	ChangeRate   float64 `json:"change_rate"`
+	Contribution float64 `json:"contribution"`
+}
+
+// HoldingsEstimate 重仓股加权估算结果
+type HoldingsEstimate struct {
+	Code                  string                `json:"code"`
+	Name                  string                `json:"name"`
+	Method                string                `json:"method"`
+	Nav                   float64               `json:"nav"`
+	EstimateNav           float64               `json:"estimate_nav"`
+	DailyGrowth           float64               `json:"daily_growth"`
+	EstimateTime          time.Time             `json:"estimate_time"`
+	ReportDate            time.Time             `json:"report_date"`
+	KnownWeight           float64               `json:"known_weight"`
+	RemainderFactor       float64               `json:"remainder_factor"`
+	UpstreamEstimateNav   float64               `json:"upstream_estimate_nav"`
+	UpstreamDailyGrowth   float64               `json:"upstream_daily_growth"`
+	DeviationFromUpstream float64               `json:"deviation_from_upstream"`
+	Holdings              []HoldingContribution `json:"holdings"`
+}
+
+// EstimateByHoldings 按最新披露的重仓股实时行情加权估算净值
+// 未披露部分的涨跌 = 重仓股加权平均涨跌 × HoldingsRemainderFactor（未配置时为 1）
+func (s *EstimateService) EstimateByHoldings(code string) (*HoldingsEstimate, error) {
+	fund, err := s.GetFundFromDB(code)
+	if err != nil {
+		return nil, err
+	}
+	// 实时行情对应最近一个交易日，涨幅以其前一个交易日的官方净值为基准
+	now := time.Now()
+	baseNav, err := s.baseNav(fund, now)
+	if err != nil {
+		return nil, err
+	}
+	if baseNav <= 0 {
+		return nil, errors.New("fund has no official nav for the previous trading day")
+	}
+
+	holdings, err := s.GetHoldings(code)
+	if err != nil {
+		return nil, err
+	}
+	if len(holdings) == 0 {
+		return nil, errors.New("fund has no disclosed stock holdings")
+	}
+
+	symbols := make([]string, 0, len(holdings))
+	for _, holding := range holdings {
+		symbols = append(symbols, holding.Symbol)
+	}
+	quotes, err := s.quotes.FetchQuotes(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &HoldingsEstimate{
+		Code:                fund.Code,
+		Name:                fund.Name,
+		Method:              EstimateMethodHoldings,
+		Nav:                 baseNav,
+		EstimateTime:        now,
+		ReportDate:          holdings[0].ReportDate,
+		RemainderFactor:     s.cfg.RemainderFactor(),
+		UpstreamEstimateNav: fund.EstimateNav,
+		UpstreamDailyGrowth: fund.DailyGrowth,
+	}
+
+	var knownContribution float64
+	for _, holding := range holdings {
+		quote, ok := quotes[holding.Symbol]
+		if !ok {
+			continue
+		}
+		contribution := holding.Weight * quote.ChangeRate / 100
+		knownContribution += contribution
+		result.KnownWeight += holding.Weight
+		result.Holdings = append(result.Holdings, HoldingContribution{
+			Symbol:       holding.Symbol,
+			Name:         holding.StockName,
+			Weight:       holding.Weight,
+			ChangeRate:   round(quote.ChangeRate, 4),
+			Contribution: round(contribution, 4),
+		})
+	}
+	if result.KnownWeight <= 0 {
+		return nil, errors.New("no quotes available for fund holdings")
+	}
+
+	remainder := math.Max(0, 100-result.KnownWeight)
+	averageGrowth := knownContribution / result.KnownWeight * 100
+	dailyGrowth := knownContribution + remainder/100*averageGrowth*s.cfg.RemainderFactor()
+
+	result.DailyGrowth = round(dailyGrowth, 4)
+	result.EstimateNav = round(baseNav*(1+dailyGrowth/100), 4)
+	result.KnownWeight = round(result.KnownWeight, 2)
+	result.DeviationFromUpstream = round(result.DailyGrowth-fund.DailyGrowth, 4)
+
+	return result, nil
+}
+
+// GetHoldings 获取基金最新报告期的重仓股，本地没有或已过期时重新抓取
+func (s *EstimateService) GetHoldings(code string) ([]models.FundHolding, error) {
+	holdings, err := s.getStoredHoldings(code)
+	if err != nil {
+		return nil, err
+	}
+	if len(holdings) > 0 && time.Since(holdings[0].ReportDate) < holdingsRefreshAfter {
+		return holdings, nil
+	}
+
+	if err := s.RefreshHoldings(code); err != nil {
+		// 抓取失败时退回已有的旧持仓
+		if len(holdings) > 0 {
+			return holdings, nil
+		}
+		return nil, err
+	}
+	return s.getStoredHoldings(code)
+}
+
+// RefreshHoldings 抓取并保存基金最新披露的重仓股
+func (s *EstimateService) RefreshHoldings(code string) error {
+	holdings, err := s.holdings.FetchHoldings(code)
+	if err != nil {
+		return err
+	}
+	if holdings.ReportDate == "" || len(holdings.Stocks) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, stock := range holdings.Stocks {
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO fund_holdings
+				(fund_code, report_date, symbol, stock_code, stock_name, exchange, weight, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, code, holdings.ReportDate, stock.Symbol, stock.Code, stock.Name, stock.Exchange, stock.Weight, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// getStoredHoldings 读取本地最新报告期的重仓股
+func (s *EstimateService) getStoredHoldings(code string) ([]models.FundHolding, error) {
+	rows, err := s.db.Query(`
+		SELECT id, fund_code, report_date, symbol, stock_code, stock_name, exchange, weight, created_at
+		FROM fund_holdings
+		WHERE fund_code = ?
+		  AND report_date = (SELECT MAX(report_date) FROM fund_holdings WHERE fund_code = ?)
+		ORDER BY weight DESC
+	`, code, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holdings []models.FundHolding
+	for rows.Next() {
+		var holding models.FundHolding
+		err := rows.Scan(&holding.ID, &holding.FundCode, &holding.ReportDate, &holding.Symbol,
+			&holding.StockCode, &holding.StockName, &holding.Exchange, &holding.Weight, &holding.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		holdings = append(holdings, holding)
+	}
+
+	return holdings, nil
+}
+
+// round 按小数位四舍五入
+func round(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
@@ -2,20 +2,43 @@ package services
 
 import (
 	"database/sql"
+	"errors"
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/config"
 	"fundnet/backend/internal/models"
+	"fundnet/backend/internal/scrapers"
+	"log"
 	"math"
 	"strconv"
 	"time"
 )
 
 // EstimateResult 估算结果
+// QDII 基金的净值滞后 NavLag 个交易日公布，PendingNavDate 为最新官方净值之后的下一个净值日，
+// 即上游估值与重仓股估算所对应的净值日期，不做偏差修正；
+// 货币基金没有盘中估值，返回最新一日的每万份收益与七日年化收益率；
+// 场内上市的 LOF、ETF 另给出最新的二级市场价格与相对参考净值的溢价率（%）
 type EstimateResult struct {
-	Code         string    `json:"code"`
-	Name         string    `json:"name"`
-	Nav          float64   `json:"nav"`
-	EstimateNav  float64   `json:"estimate_nav"`
-	DailyGrowth  float64   `json:"daily_growth"`
-	EstimateTime time.Time `json:"estimate_time"`
+	Code                 string            `json:"code"`
+	Name                 string            `json:"name"`
+	Nav                  float64           `json:"nav"`
+	EstimateNav          float64           `json:"estimate_nav"`
+	DailyGrowth          float64           `json:"daily_growth"`
+	EstimateTime         time.Time         `json:"estimate_time"`
+	EstimateSource       string            `json:"estimate_source"`
+	CorrectedEstimateNav float64           `json:"corrected_estimate_nav"`
+	CorrectedGrowth      float64           `json:"corrected_daily_growth"`
+	Calibration          *CalibrationModel `json:"calibration"`
+	Market               string            `json:"market"`
+	NavLag               int               `json:"nav_lag"`
+	PendingNavDate       *time.Time        `json:"pending_nav_date,omitempty"`
+	FundType             string            `json:"fund_type"`
+	IncomePer10K         *float64          `json:"income_per_10k,omitempty"`
+	Yield7D              *float64          `json:"yield_7d,omitempty"`
+	ExchangeSymbol       string            `json:"exchange_symbol,omitempty"`
+	MarketPrice          *float64          `json:"market_price,omitempty"`
+	MarketPriceTime      *time.Time        `json:"market_price_time,omitempty"`
+	PremiumRate          *float64          `json:"premium_rate,omitempty"`
 }
 
 // HistoryPoint 历史数据点
@@ -25,15 +48,33 @@ type HistoryPoint struct {
 	DailyGrowth float64   `json:"daily_growth"`
 }
 
+// HoldingsSource 基金持仓数据源
+type HoldingsSource interface {
+	FetchHoldings(code string) (*scrapers.FundHoldings, error)
+}
+
+// QuoteSource 股票行情数据源
+type QuoteSource interface {
+	FetchQuotes(symbols []string) (map[string]scrapers.StockQuote, error)
+}
+
 // EstimateService 估算服务
 type EstimateService struct {
-	db *sql.DB
+	db       *sql.DB
+	holdings HoldingsSource
+	quotes   QuoteSource
+	fx       *FxService
+	cfg      config.AppConfig
 }
 
-// NewEstimateService 创建估算服务
-func NewEstimateService() *EstimateService {
+// NewEstimateService 创建估算服务，fx 用于将外币份额持仓折算为人民币
+func NewEstimateService(holdings HoldingsSource, quotes QuoteSource, fx *FxService, cfg config.AppConfig) *EstimateService {
 	return &EstimateService{
-		db: models.GetDB(),
+		db:       models.GetDB(),
+		holdings: holdings,
+		quotes:   quotes,
+		fx:       fx,
+		cfg:      cfg,
 	}
 }
 
@@ -44,14 +85,84 @@ func (s *EstimateService) GetEstimate(code string) (*EstimateResult, error) {
 		return nil, err
 	}
 
-	return &EstimateResult{
-		Code:         fund.Code,
-		Name:         fund.Name,
-		Nav:          fund.Nav,
-		EstimateNav:  fund.EstimateNav,
-		DailyGrowth:  fund.DailyGrowth,
-		EstimateTime: fund.EstimateTime,
-	}, nil
+	result := &EstimateResult{
+		Code:                 fund.Code,
+		Name:                 fund.Name,
+		Nav:                  fund.Nav,
+		EstimateNav:          fund.EstimateNav,
+		DailyGrowth:          fund.DailyGrowth,
+		EstimateTime:         fund.EstimateTime,
+		EstimateSource:       fund.EstimateSource,
+		CorrectedEstimateNav: fund.EstimateNav,
+		CorrectedGrowth:      fund.DailyGrowth,
+		Market:               fund.Market,
+		NavLag:               fund.NavLag,
+		FundType:             fund.FundType,
+		ExchangeSymbol:       fund.ExchangeSymbol,
+	}
+	if fund.ExchangeSymbol != "" && fund.MarketPrice > 0 {
+		result.MarketPrice, result.PremiumRate = &fund.MarketPrice, &fund.PremiumRate
+		result.MarketPriceTime = &fund.MarketPriceTime
+	}
+	if isMoneyMarket(fund) {
+		yield, err := latestMoneyYield(s.db, code, time.Now())
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if yield != nil {
+			result.IncomePer10K, result.Yield7D = &yield.IncomePer10K, &yield.Yield7D
+		}
+		return result, nil
+	}
+	if isQDII(fund) {
+		if !fund.NavDate.IsZero() {
+			pending := dateOnly(nextNavDate(fund, fund.NavDate))
+			result.PendingNavDate = &pending
+		}
+		return result, nil
+	}
+
+	// 拟合样本足够时给出偏差修正后的估值；估值日的官方净值已公布时不再修正
+	if fund.EstimateTime.IsZero() || !fund.NavDate.IsZero() &&
+		fund.NavDate.Format(calendar.DateLayout) >= calendar.Default().FormatDate(fund.EstimateTime) {
+		return result, nil
+	}
+	model, err := s.FitCalibration(code, fund.EstimateSource)
+	if err != nil {
+		return nil, err
+	}
+	if model == nil {
+		return result, nil
+	}
+	baseNav, err := s.baseNav(fund, fund.EstimateTime)
+	if err != nil {
+		return nil, err
+	}
+	if baseNav > 0 {
+		result.Calibration = model
+		result.CorrectedGrowth = round(model.Apply(fund.DailyGrowth), 4)
+		result.CorrectedEstimateNav = round(baseNav*(1+result.CorrectedGrowth/100), 4)
+	}
+
+	return result, nil
+}
+
+// baseNav 返回估值涨幅的基准：估值时刻所在交易日的前一个交易日的官方净值，没有该日净值时返回 0
+func (s *EstimateService) baseNav(fund *models.Fund, estimateTime time.Time) (float64, error) {
+	cal := calendar.Default()
+	baseDate := cal.FormatDate(cal.PrevTradingDay(cal.LatestTradingDay(estimateTime)))
+	if !fund.NavDate.IsZero() && fund.NavDate.Format(calendar.DateLayout) == baseDate {
+		return fund.Nav, nil
+	}
+
+	var nav float64
+	err := s.db.QueryRow(`
+		SELECT nav FROM nav_history WHERE fund_code = ? AND nav_date = ?
+	`, fund.Code, baseDate).Scan(&nav)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return nav, err
 }
 
 // CalculateEstimate 计算估算净值
@@ -61,13 +172,11 @@ func (s *EstimateService) CalculateEstimate(fundCode string, shares, cost float6
 		return 0, 0, err
 	}
 
-	var currentNav float64
-	if fund.EstimateNav > 0 {
-		currentNav = fund.EstimateNav
-	} else if fund.Nav > 0 {
-		currentNav = fund.Nav
-	} else {
-		currentNav = cost
+	currentNav := cost
+	if quote, err := s.valuationQuote(fund); err != nil {
+		return 0, 0, err
+	} else if quote.Nav > 0 {
+		currentNav = quote.Nav
 	}
 
 	currentValue := shares * currentNav
@@ -85,21 +194,49 @@ func (s *EstimateService) RefreshAllEstimates() {
 	for _, fund := range funds {
 		s.RefreshEstimate(fund.Code)
 	}
+
+	if err := s.SettleEstimateSnapshots(); err != nil {
+		log.Printf("Failed to settle estimate snapshots: %v", err)
+	}
+
+	if _, err := s.RefreshMarketPrices(); err != nil {
+		log.Printf("Failed to refresh exchange prices: %v", err)
+	}
+
+	s.RevalueAllPositions()
 }
 
 // RefreshEstimate 刷新单个基金估算
 func (s *EstimateService) RefreshEstimate(code string) error {
+	fund, err := s.GetFundFromDB(code)
+	if err != nil {
+		return err
+	}
 	estimate, err := s.GetEstimate(code)
 	if err != nil {
 		return err
 	}
 
-	dailyGrowth := float64(0)
-	if estimate.Nav > 0 {
-		dailyGrowth = ((estimate.EstimateNav - estimate.Nav) / estimate.Nav) * 100
+	// 涨幅相对估值日前一交易日的净值计算，没有该日净值时沿用上游给出的涨幅
+	dailyGrowth := estimate.DailyGrowth
+	if !estimate.EstimateTime.IsZero() {
+		baseNav, err := s.baseNav(fund, estimate.EstimateTime)
+		if err != nil {
+			return err
+		}
+		if baseNav > 0 {
+			dailyGrowth = ((estimate.EstimateNav - baseNav) / baseNav) * 100
+		}
 	}
 
 	s.SaveEstimateHistory(code, estimate.EstimateNav, dailyGrowth)
+
+	if err := s.RecordEstimateSnapshot(estimate); err != nil {
+		log.Printf("Failed to record estimate snapshot for %s: %v", code, err)
+	}
+	if err := s.RecordHoldingsSnapshot(fund); err != nil {
+		log.Printf("Failed to record holdings estimate snapshot for %s: %v", code, err)
+	}
 	return nil
 }
 
@@ -156,8 +293,7 @@ func (s *EstimateService) GetHistory(code string, days int) ([]HistoryPoint, err
 // GetAllSubscribedFunds 获取所有订阅的基金
 func (s *EstimateService) GetAllSubscribedFunds() ([]models.Fund, error) {
 	rows, err := s.db.Query(`
-		SELECT id, code, name, sector, nav, nav_date, estimate_nav, estimate_time,
-		       daily_growth, subscribed, subscribe_time, created_at, updated_at
+		SELECT ` + fundColumns + `
 		FROM funds
 		WHERE subscribed = 1
 	`)
@@ -168,27 +304,11 @@ func (s *EstimateService) GetAllSubscribedFunds() ([]models.Fund, error) {
 
 	var funds []models.Fund
 	for rows.Next() {
-		var fund models.Fund
-		var estimateTime, navDate sql.NullString
-
-		err := rows.Scan(
-			&fund.ID, &fund.Code, &fund.Name, &fund.Sector,
-			&fund.Nav, &navDate, &fund.EstimateNav, &estimateTime,
-			&fund.DailyGrowth, &fund.Subscribed, &fund.SubscribeTime,
-			&fund.CreatedAt, &fund.UpdatedAt,
-		)
+		fund, err := scanFund(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if estimateTime.Valid {
-			fund.EstimateTime, _ = time.Parse("2006-01-02 15:04:05", estimateTime.String)
-		}
-		if navDate.Valid {
-			fund.NavDate, _ = time.Parse("2006-01-02", navDate.String)
-		}
-
-		funds = append(funds, fund)
+		funds = append(funds, *fund)
 	}
 
 	return funds, nil
@@ -196,32 +316,12 @@ func (s *EstimateService) GetAllSubscribedFunds() ([]models.Fund, error) {
 
 // GetFundFromDB 从数据库获取基金信息
 func (s *EstimateService) GetFundFromDB(code string) (*models.Fund, error) {
-	var fund models.Fund
-	var estimateTime, navDate sql.NullString
-
-	err := s.db.QueryRow(`
-		SELECT id, code, name, sector, nav, nav_date, estimate_nav, estimate_time,
-		       daily_growth, subscribed, subscribe_time, created_at, updated_at
+	row := s.db.QueryRow(`
+		SELECT `+fundColumns+`
 		FROM funds
 		WHERE code = ?
-	`, code).Scan(
-		&fund.ID, &fund.Code, &fund.Name, &fund.Sector,
-		&fund.Nav, &navDate, &fund.EstimateNav, &estimateTime,
-		&fund.DailyGrowth, &fund.Subscribed, &fund.SubscribeTime,
-		&fund.CreatedAt, &fund.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	if estimateTime.Valid {
-		fund.EstimateTime, _ = time.Parse("2006-01-02 15:04:05", estimateTime.String)
-	}
-	if navDate.Valid {
-		fund.NavDate, _ = time.Parse("2006-01-02", navDate.String)
-	}
-
-	return &fund, nil
+	`, code)
+	return scanFund(row)
 }
 
 // CalculatePortfolioEstimate 计算组合估算
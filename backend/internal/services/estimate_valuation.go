@@ -0,0 +1,178 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+)
+
+// 持仓估值依据
+const (
+	ValuationBasisEstimate = "estimate"
+	ValuationBasisOfficial = "official"
+)
+
+// ValuationQuote 持仓估值所用的单位净值
+// DailyChange 为单位净值相对上一交易日的变动额
+type ValuationQuote struct {
+	Nav         float64
+	DailyGrowth float64
+	DailyChange float64
+	Basis       string
+}
+
+// valuationQuote 选择基金当前用于估值的净值：
+// 当日估值尚未被官方净值追上时使用估值，否则使用最新官方净值；
+// QDII 基金的盘中估值对应的不是当日净值，始终使用最新官方净值；
+// 货币基金按 1 元净值估值，当日变动额为最新一日的每份收益
+func (s *EstimateService) valuationQuote(fund *models.Fund) (*ValuationQuote, error) {
+	if isMoneyMarket(fund) {
+		quote := &ValuationQuote{Nav: moneyFundNav, Basis: ValuationBasisOfficial}
+		yield, err := latestMoneyYield(s.db, fund.Code, time.Now())
+		if errors.Is(err, sql.ErrNoRows) {
+			return quote, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		quote.DailyGrowth = round(yield.IncomePer10K/100, 4)
+		quote.DailyChange = yield.IncomePer10K / 10000
+		return quote, nil
+	}
+
+	cal := calendar.Default()
+	useEstimate := !isQDII(fund) && fund.EstimateNav > 0 && !fund.EstimateTime.IsZero() &&
+		cal.IsTradingDay(fund.EstimateTime) &&
+		(fund.NavDate.IsZero() || cal.FormatDate(fund.EstimateTime) > fund.NavDate.Format(calendar.DateLayout))
+
+	if useEstimate {
+		quote := &ValuationQuote{
+			Nav:         fund.EstimateNav,
+			DailyGrowth: fund.DailyGrowth,
+			Basis:       ValuationBasisEstimate,
+		}
+		if fund.Nav > 0 {
+			quote.DailyChange = fund.EstimateNav - fund.Nav
+		}
+		return quote, nil
+	}
+
+	if fund.Nav <= 0 {
+		return &ValuationQuote{Basis: ValuationBasisOfficial}, nil
+	}
+
+	// 官方涨幅取自历史净值，没有记录时视为持平
+	var dailyGrowth float64
+	err := s.db.QueryRow(`
+		SELECT daily_growth FROM nav_history WHERE fund_code = ? AND nav_date = ?
+	`, fund.Code, fund.NavDate.Format(calendar.DateLayout)).Scan(&dailyGrowth)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return &ValuationQuote{
+		Nav:         fund.Nav,
+		DailyGrowth: dailyGrowth,
+		DailyChange: fund.Nav - fund.Nav/(1+dailyGrowth/100),
+		Basis:       ValuationBasisOfficial,
+	}, nil
+}
+
+// RevalueAllPositions 按最新估值或官方净值重估全部持仓
+func (s *EstimateService) RevalueAllPositions() {
+	rows, err := s.db.Query(`SELECT id FROM positions`)
+	if err != nil {
+		log.Printf("Failed to load positions: %v", err)
+		return
+	}
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			log.Printf("Failed to load positions: %v", err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := s.RevaluePosition(id); err != nil {
+			log.Printf("Failed to revalue position %d: %v", id, err)
+		}
+	}
+}
+
+// RevaluePosition 重估单个持仓的市值、盈亏与当日盈亏
+// 金额均以基金份额的计价币种计，外币份额同时记录最新的人民币汇率中间价；
+// 汇率缺失时按需抓取，仍缺失时沿用同币种的上次汇率，没有可用汇率时汇率置空，人民币金额视为未知
+func (s *EstimateService) RevaluePosition(id int64) error {
+	var fundCode, currency string
+	var shares, costBasis float64
+	var fxRate sql.NullFloat64
+	err := s.db.QueryRow(`
+		SELECT fund_code, shares, cost_basis, currency, fx_rate FROM positions WHERE id = ?
+	`, id).Scan(&fundCode, &shares, &costBasis, &currency, &fxRate)
+	if err != nil {
+		return err
+	}
+
+	fund, err := s.GetFundFromDB(fundCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		// 未订阅的基金没有行情，保持原值
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	quote, err := s.valuationQuote(fund)
+	if err != nil {
+		return err
+	}
+	if quote.Nav <= 0 {
+		return nil
+	}
+
+	currentValue := round(shares*quote.Nav, 2)
+	profitLoss := round(currentValue-costBasis, 2)
+	profitRate := float64(0)
+	if costBasis > 0 {
+		profitRate = round(profitLoss/costBasis*100, 2)
+	}
+	dailyProfit := round(shares*quote.DailyChange, 2)
+
+	now := time.Now()
+	var rate float64
+	if s.fx != nil {
+		rate, err = s.fx.RateOn(fund.Currency, now)
+	} else {
+		rate, err = fxRateOn(s.db, fund.Currency, now)
+	}
+	switch {
+	case err == nil:
+		fxRate = sql.NullFloat64{Float64: rate, Valid: true}
+	case errors.Is(err, sql.ErrNoRows):
+		if currency != fund.Currency {
+			fxRate = sql.NullFloat64{}
+		}
+		log.Printf("No %s fx rate for position %d yet", fund.Currency, id)
+	default:
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE positions SET current_value = ?, profit_loss = ?, profit_rate = ?,
+		       daily_growth = ?, daily_profit = ?, valuation_nav = ?, valuation_basis = ?,
+		       currency = ?, fx_rate = ?, valued_at = ?, updated_at = ?
+		WHERE id = ?
+	`, currentValue, profitLoss, profitRate, quote.DailyGrowth, dailyProfit,
+		quote.Nav, quote.Basis, fund.Currency, fxRate, now, now, id)
+	return err
+}
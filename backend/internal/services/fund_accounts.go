@@ -0,0 +1,347 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fundnet/backend/internal/models"
+)
+
+// ErrInUse 组合下仍有账户、账户或持仓下仍有交易流水，或试图删除默认组合与默认账户
+var ErrInUse = errors.New("still in use")
+
+// AssetFilter 资产统计的范围，均为空时统计全部账户
+type AssetFilter struct {
+	PortfolioID *int64
+	AccountID   *int64
+}
+
+// appendFilter 在持仓查询的 WHERE 条件后追加账户或组合过滤，持仓表别名须为 p
+func appendFilter(query string, filter AssetFilter) (string, []interface{}) {
+	var args []interface{}
+	if filter.AccountID != nil {
+		query += " AND p.account_id = ?"
+		args = append(args, *filter.AccountID)
+	}
+	if filter.PortfolioID != nil {
+		query += " AND p.account_id IN (SELECT id FROM accounts WHERE portfolio_id = ?)"
+		args = append(args, *filter.PortfolioID)
+	}
+	return query, args
+}
+
+const portfolioColumns = `id, name, description, sort_order, created_at, updated_at`
+
+const accountColumns = `id, portfolio_id, name, platform, owner, note, sort_order, created_at, updated_at`
+
+func scanPortfolio(row rowScanner) (*models.Portfolio, error) {
+	var portfolio models.Portfolio
+	err := row.Scan(&portfolio.ID, &portfolio.Name, &portfolio.Description, &portfolio.SortOrder,
+		&portfolio.CreatedAt, &portfolio.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &portfolio, nil
+}
+
+func scanAccount(row rowScanner) (*models.Account, error) {
+	var account models.Account
+	err := row.Scan(&account.ID, &account.PortfolioID, &account.Name, &account.Platform, &account.Owner,
+		&account.Note, &account.SortOrder, &account.CreatedAt, &account.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// GetPortfolios 获取所有组合
+func (s *FundService) GetPortfolios() ([]models.Portfolio, error) {
+	rows, err := s.db.Query(`SELECT ` + portfolioColumns + ` FROM portfolios ORDER BY sort_order, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	portfolios := make([]models.Portfolio, 0)
+	for rows.Next() {
+		portfolio, err := scanPortfolio(rows)
+		if err != nil {
+			return nil, err
+		}
+		portfolios = append(portfolios, *portfolio)
+	}
+	return portfolios, rows.Err()
+}
+
+// GetPortfolioByID 根据ID获取组合
+func (s *FundService) GetPortfolioByID(id int64) (*models.Portfolio, error) {
+	return scanPortfolio(s.db.QueryRow(`SELECT `+portfolioColumns+` FROM portfolios WHERE id = ?`, id))
+}
+
+// CreatePortfolio 创建组合
+func (s *FundService) CreatePortfolio(portfolio *models.Portfolio) (*models.Portfolio, error) {
+	if strings.TrimSpace(portfolio.Name) == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	now := time.Now()
+	result, err := s.db.Exec(`
+		INSERT INTO portfolios (name, description, sort_order, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, portfolio.Name, portfolio.Description, portfolio.SortOrder, now, now)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	return s.GetPortfolioByID(id)
+}
+
+// UpdatePortfolio 更新组合
+func (s *FundService) UpdatePortfolio(portfolio *models.Portfolio) (*models.Portfolio, error) {
+	if strings.TrimSpace(portfolio.Name) == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	result, err := s.db.Exec(`
+		UPDATE portfolios SET name = ?, description = ?, sort_order = ?, updated_at = ?
+		WHERE id = ?
+	`, portfolio.Name, portfolio.Description, portfolio.SortOrder, time.Now(), portfolio.ID)
+	if err != nil {
+		return nil, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return s.GetPortfolioByID(portfolio.ID)
+}
+
+// DeletePortfolio 删除没有账户的组合，默认组合不可删除
+func (s *FundService) DeletePortfolio(id int64) error {
+	if id == models.DefaultPortfolioID {
+		return fmt.Errorf("%w: default portfolio cannot be deleted", ErrInUse)
+	}
+	var accounts int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM accounts WHERE portfolio_id = ?`, id).Scan(&accounts); err != nil {
+		return err
+	}
+	if accounts > 0 {
+		return fmt.Errorf("%w: portfolio has %d accounts", ErrInUse, accounts)
+	}
+	_, err := s.db.Exec(`DELETE FROM portfolios WHERE id = ?`, id)
+	return err
+}
+
+// GetAccounts 获取账户列表，portfolioID 不为空时只返回该组合下的账户
+func (s *FundService) GetAccounts(portfolioID *int64) ([]models.Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM accounts`
+	var args []interface{}
+	if portfolioID != nil {
+		query += " WHERE portfolio_id = ?"
+		args = append(args, *portfolioID)
+	}
+	query += " ORDER BY portfolio_id, sort_order, id"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := make([]models.Account, 0)
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, rows.Err()
+}
+
+// GetAccountByID 根据ID获取账户
+func (s *FundService) GetAccountByID(id int64) (*models.Account, error) {
+	return scanAccount(s.db.QueryRow(`SELECT `+accountColumns+` FROM accounts WHERE id = ?`, id))
+}
+
+// CreateAccount 创建账户，未指定组合时归入默认组合
+func (s *FundService) CreateAccount(account *models.Account) (*models.Account, error) {
+	if err := s.validateAccount(account); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result, err := s.db.Exec(`
+		INSERT INTO accounts (portfolio_id, name, platform, owner, note, sort_order, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, account.PortfolioID, account.Name, account.Platform, account.Owner, account.Note, account.SortOrder, now, now)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	return s.GetAccountByID(id)
+}
+
+// UpdateAccount 更新账户信息或所属组合
+func (s *FundService) UpdateAccount(account *models.Account) (*models.Account, error) {
+	if err := s.validateAccount(account); err != nil {
+		return nil, err
+	}
+	result, err := s.db.Exec(`
+		UPDATE accounts SET portfolio_id = ?, name = ?, platform = ?, owner = ?, note = ?, sort_order = ?,
+			updated_at = ?
+		WHERE id = ?
+	`, account.PortfolioID, account.Name, account.Platform, account.Owner, account.Note, account.SortOrder,
+		time.Now(), account.ID)
+	if err != nil {
+		return nil, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return s.GetAccountByID(account.ID)
+}
+
+// validateAccount 校验账户名称与所属组合
+func (s *FundService) validateAccount(account *models.Account) error {
+	if strings.TrimSpace(account.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	if account.PortfolioID == 0 {
+		account.PortfolioID = models.DefaultPortfolioID
+	}
+	if _, err := s.GetPortfolioByID(account.PortfolioID); errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: portfolio %d not found", ErrInvalidInput, account.PortfolioID)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteAccount 删除没有交易流水的账户，默认账户不可删除
+func (s *FundService) DeleteAccount(id int64) error {
+	if id == models.DefaultAccountID {
+		return fmt.Errorf("%w: default account cannot be deleted", ErrInUse)
+	}
+	var transactions int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM transactions WHERE account_id = ?`, id).Scan(&transactions); err != nil {
+		return err
+	}
+	if transactions > 0 {
+		return fmt.Errorf("%w: account has %d transactions", ErrInUse, transactions)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM positions WHERE account_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM accounts WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// resolveAccount 未指定账户（0）时使用默认账户，指定的账户须存在
+func (s *FundService) resolveAccount(accountID int64) (int64, error) {
+	if accountID == 0 {
+		return models.DefaultAccountID, nil
+	}
+	if _, err := s.GetAccountByID(accountID); errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: account %d not found", ErrInvalidInput, accountID)
+	} else if err != nil {
+		return 0, err
+	}
+	return accountID, nil
+}
+
+// accountBreakdown 按组合与账户汇总持仓，weight 为占 totalValue 的百分比
+func (s *FundService) accountBreakdown(positions []models.Position, totalValue float64) (
+	[]map[string]interface{}, []map[string]interface{}, error) {
+	accounts, err := s.GetAccounts(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	portfolios, err := s.GetPortfolios()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type totals struct {
+		costBasis, currentValue, profitLoss, dailyProfit float64
+		positions                                        int
+	}
+	byAccount := make(map[int64]*totals)
+	byPortfolio := make(map[int64]*totals)
+	portfolioOf := make(map[int64]int64, len(accounts))
+	for _, account := range accounts {
+		portfolioOf[account.ID] = account.PortfolioID
+	}
+	for _, pos := range positions {
+		for _, group := range []struct {
+			m  map[int64]*totals
+			id int64
+		}{{byAccount, pos.AccountID}, {byPortfolio, portfolioOf[pos.AccountID]}} {
+			t, ok := group.m[group.id]
+			if !ok {
+				t = &totals{}
+				group.m[group.id] = t
+			}
+			t.costBasis += pos.CostBasis
+			t.currentValue += pos.CurrentValue
+			t.profitLoss += pos.ProfitLoss
+			t.dailyProfit += pos.DailyProfit
+			t.positions++
+		}
+	}
+
+	stats := func(t *totals) map[string]interface{} {
+		weight, profitRate := float64(0), float64(0)
+		if totalValue > 0 {
+			weight = t.currentValue / totalValue * 100
+		}
+		if t.costBasis > 0 {
+			profitRate = t.profitLoss / t.costBasis * 100
+		}
+		return map[string]interface{}{
+			"cost_basis":     t.costBasis,
+			"current_value":  t.currentValue,
+			"profit_loss":    t.profitLoss,
+			"daily_profit":   t.dailyProfit,
+			"profit_rate":    profitRate,
+			"weight":         weight,
+			"position_count": t.positions,
+		}
+	}
+
+	portfolioStats := make([]map[string]interface{}, 0)
+	for _, portfolio := range portfolios {
+		t, ok := byPortfolio[portfolio.ID]
+		if !ok {
+			continue
+		}
+		item := stats(t)
+		item["id"] = portfolio.ID
+		item["name"] = portfolio.Name
+		portfolioStats = append(portfolioStats, item)
+	}
+
+	accountStats := make([]map[string]interface{}, 0)
+	for _, account := range accounts {
+		t, ok := byAccount[account.ID]
+		if !ok {
+			continue
+		}
+		item := stats(t)
+		item["id"] = account.ID
+		item["name"] = account.Name
+		item["platform"] = account.Platform
+		item["owner"] = account.Owner
+		item["portfolio_id"] = account.PortfolioID
+		accountStats = append(accountStats, item)
+	}
+	return portfolioStats, accountStats, nil
+}
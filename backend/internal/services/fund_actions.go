@@ -0,0 +1,306 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+	"fundnet/backend/internal/scrapers"
+)
+
+// CorporateActionSource 提供基金分红与拆分记录的数据源
+type CorporateActionSource interface {
+	FetchCorporateActions(code string) ([]scrapers.CorporateAction, error)
+}
+
+// actionColumns 公司行为表查询列，与 scanCorporateAction 的扫描顺序一致
+const actionColumns = `id, fund_code, type, record_date, ex_date, pay_date, cash_per_share, split_ratio,
+		       source, created_at`
+
+// scanCorporateAction 扫描一行分红或拆分记录
+func scanCorporateAction(row rowScanner) (*models.CorporateAction, error) {
+	var action models.CorporateAction
+	var payDate sql.NullTime
+
+	err := row.Scan(
+		&action.ID, &action.FundCode, &action.Type, &action.RecordDate, &action.ExDate, &payDate,
+		&action.CashPerShare, &action.SplitRatio, &action.Source, &action.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if payDate.Valid {
+		action.PayDate = &payDate.Time
+	}
+	return &action, nil
+}
+
+// GetCorporateActions 获取基金已入库的分红与拆分记录，按除权日升序
+func (s *FundService) GetCorporateActions(code string) ([]models.CorporateAction, error) {
+	rows, err := s.db.Query(`
+		SELECT `+actionColumns+`
+		FROM corporate_actions
+		WHERE fund_code = ?
+		ORDER BY ex_date, id
+	`, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := make([]models.CorporateAction, 0)
+	for rows.Next() {
+		action, err := scanCorporateAction(rows)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, *action)
+	}
+	return actions, rows.Err()
+}
+
+// RefreshCorporateActions 从数据源抓取基金的分红与拆分记录并入库，已有记录按（类型, 除权日）更新，返回抓取条数
+func (s *FundService) RefreshCorporateActions(code string) (int, error) {
+	if s.actions == nil {
+		return 0, errors.New("no corporate action data source configured")
+	}
+
+	actions, err := s.actions.FetchCorporateActions(code)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, action := range actions {
+		recordDate := action.RecordDate
+		if recordDate == "" {
+			recordDate = action.ExDate
+		}
+		var payDate interface{}
+		if action.PayDate != "" {
+			payDate = action.PayDate
+		}
+		_, err := tx.Exec(`
+			INSERT INTO corporate_actions
+				(fund_code, type, record_date, ex_date, pay_date, cash_per_share, split_ratio, source, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (fund_code, type, ex_date) DO UPDATE SET
+				record_date = excluded.record_date, pay_date = excluded.pay_date,
+				cash_per_share = excluded.cash_per_share, split_ratio = excluded.split_ratio,
+				source = excluded.source
+		`, code, action.Type, recordDate, action.ExDate, payDate, action.CashPerShare, action.SplitRatio,
+			action.Source, time.Now())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(actions), tx.Commit()
+}
+
+// RefreshAllCorporateActions 刷新所有订阅或持有基金的分红与拆分记录
+func (s *FundService) RefreshAllCorporateActions() {
+	rows, err := s.db.Query(`
+		SELECT code FROM funds WHERE subscribed = 1
+		UNION
+		SELECT fund_code FROM positions WHERE status != ?
+	`, models.PositionClosed)
+	if err != nil {
+		log.Printf("Failed to load funds for corporate actions: %v", err)
+		return
+	}
+	var codes []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			rows.Close()
+			log.Printf("Failed to load funds for corporate actions: %v", err)
+			return
+		}
+		codes = append(codes, code)
+	}
+	rows.Close()
+
+	for _, code := range codes {
+		if _, err := s.RefreshCorporateActions(code); err != nil {
+			log.Printf("Failed to refresh corporate actions of %s: %v", code, err)
+		}
+	}
+}
+
+// ApplyCorporateActions 将截至 now 已除权的分红与拆分记入持有账户的交易流水，返回新增的交易笔数
+// 权益登记日前一交易日（含）及之前确认的份额享有权益；分红按持仓的分红方式记为现金分红或按除权日净值再投资，
+// 除权日净值尚未入库时留待下次处理；账户在除权日已有同类交易（如手工记录的分红）时视为已处理
+func (s *FundService) ApplyCorporateActions(now time.Time) (int, error) {
+	today := calendar.Default().FormatDate(now)
+	rows, err := s.db.Query(`
+		SELECT `+actionColumns+`
+		FROM corporate_actions
+		WHERE ex_date <= ?
+		ORDER BY ex_date, id
+	`, today)
+	if err != nil {
+		return 0, err
+	}
+	var actions []models.CorporateAction
+	for rows.Next() {
+		action, err := scanCorporateAction(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		actions = append(actions, *action)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, action := range actions {
+		accounts, err := s.pendingActionAccounts(action)
+		if err != nil {
+			return count, err
+		}
+		for _, accountID := range accounts {
+			applied, err := s.applyCorporateAction(action, accountID)
+			if err != nil {
+				return count, err
+			}
+			if applied {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// pendingActionAccounts 权益登记日前持有该基金且尚未处理该记录的账户
+func (s *FundService) pendingActionAccounts(action models.CorporateAction) ([]int64, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT account_id FROM transactions
+		WHERE fund_code = ? AND status = ? AND trade_date < ?
+		  AND account_id NOT IN (SELECT account_id FROM applied_actions WHERE action_id = ?)
+		ORDER BY account_id
+	`, action.FundCode, models.TransactionConfirmed, calendar.Default().FormatDate(action.RecordDate), action.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []int64
+	for rows.Next() {
+		var accountID int64
+		if err := rows.Scan(&accountID); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, accountID)
+	}
+	return accounts, rows.Err()
+}
+
+// applyCorporateAction 为单个账户记入分红或拆分交易，返回是否新增了交易
+func (s *FundService) applyCorporateAction(action models.CorporateAction, accountID int64) (bool, error) {
+	cal := calendar.Default()
+	types := []string{models.TransactionSplit}
+	if action.Type == models.ActionDividend {
+		types = []string{models.TransactionDividendCash, models.TransactionDividendReinvest}
+	}
+	var existing int64
+	err := s.db.QueryRow(`
+		SELECT id FROM transactions
+		WHERE account_id = ? AND fund_code = ? AND trade_date = ? AND type IN (?, ?)
+		ORDER BY id LIMIT 1
+	`, accountID, action.FundCode, cal.FormatDate(action.ExDate), types[0], types[len(types)-1]).Scan(&existing)
+	if err == nil {
+		return false, s.markActionApplied(action.ID, accountID, existing)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	lots, err := s.openLots(accountID, action.FundCode, cal.PrevTradingDay(action.RecordDate))
+	if err != nil {
+		return false, err
+	}
+	var held float64
+	for _, lot := range lots {
+		held += lot.Shares
+	}
+	if held < shareEpsilon {
+		return false, s.markActionApplied(action.ID, accountID, 0)
+	}
+
+	t := &models.Transaction{
+		AccountID: accountID,
+		FundCode:  action.FundCode,
+		Status:    models.TransactionConfirmed,
+		OrderTime: action.ExDate,
+		TradeDate: action.ExDate,
+	}
+	switch action.Type {
+	case models.ActionSplit:
+		if action.SplitRatio <= 0 {
+			return false, fmt.Errorf("invalid split ratio %.4f for %s", action.SplitRatio, action.FundCode)
+		}
+		t.Type = models.TransactionSplit
+		t.Shares = round(held*(action.SplitRatio-1), 2)
+		t.Price = action.SplitRatio
+		t.Note = fmt.Sprintf("份额拆分 1:%.4f", action.SplitRatio)
+	case models.ActionDividend:
+		t.Amount = round(held*action.CashPerShare, 2)
+		if t.Amount <= 0 {
+			return false, s.markActionApplied(action.ID, accountID, 0)
+		}
+		t.Type = models.TransactionDividendCash
+		t.Note = fmt.Sprintf("每份派现金 %.4f 元", action.CashPerShare)
+
+		var mode string
+		err := s.db.QueryRow(`
+			SELECT COALESCE(dividend_mode, '') FROM positions WHERE account_id = ? AND fund_code = ?
+		`, accountID, action.FundCode).Scan(&mode)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return false, err
+		}
+		if mode == models.DividendModeReinvest {
+			nav, err := s.navService.navOn(action.FundCode, action.ExDate)
+			if errors.Is(err, sql.ErrNoRows) {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			t.Type = models.TransactionDividendReinvest
+			t.Price = nav
+			t.Shares = round(t.Amount/nav, 2)
+			t.Note = fmt.Sprintf("每份派现金 %.4f 元，红利再投资", action.CashPerShare)
+		}
+	default:
+		return false, fmt.Errorf("unknown corporate action type %q", action.Type)
+	}
+
+	input := TransactionInput{AccountID: accountID, FundCode: action.FundCode}
+	if _, err := s.insertTransaction(input, t); err != nil {
+		return false, err
+	}
+	return true, s.markActionApplied(action.ID, accountID, t.ID)
+}
+
+// markActionApplied 记录账户已处理该分红或拆分，transactionID 为 0 表示无需记账
+func (s *FundService) markActionApplied(actionID, accountID, transactionID int64) error {
+	_, err := s.db.Exec(`
+		INSERT OR IGNORE INTO applied_actions (action_id, account_id, transaction_id, created_at)
+		VALUES (?, ?, ?, ?)
+	`, actionID, accountID, transactionID, time.Now())
+	return err
+}
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+	"fundnet/backend/internal/scrapers"
+)
+
+// FeeSource 提供基金费率的数据源
+type FeeSource interface {
+	FetchFees(code string) (*scrapers.FundFees, error)
+}
+
+// feeSourceManual 手工录入的费率
+const feeSourceManual = "manual"
+
+// feeRetryInterval 抓取费率失败后再次抓取同一基金的最小间隔
+const feeRetryInterval = 24 * time.Hour
+
+// feeFailure 最近一次抓取费率失败的时间与原因
+type feeFailure struct {
+	at  time.Time
+	err error
+}
+
+// RedeemLotFee 赎回预览中单个批次的费用
+type RedeemLotFee struct {
+	LotID       int64     `json:"lot_id"`
+	TradeDate   time.Time `json:"trade_date"`
+	ConfirmDate time.Time `json:"confirm_date"`
+	Shares      float64   `json:"shares"`
+	HoldingDays int       `json:"holding_days"`
+	Rate        float64   `json:"rate"`
+	Fee         float64   `json:"fee"`
+}
+
+// RedeemPreview 赎回费用预览
+// NextTierDate 为所赎回批次中最早降档的日期，届时赎回的费用为 NextTierFee；无更低档位时为空
+type RedeemPreview struct {
+	PositionID   int64          `json:"position_id"`
+	FundCode     string         `json:"fund_code"`
+	Shares       float64        `json:"shares"`
+	Nav          float64        `json:"nav"`
+	RedeemDate   time.Time      `json:"redeem_date"`
+	GrossAmount  float64        `json:"gross_amount"`
+	Fee          float64        `json:"fee"`
+	FeeRate      float64        `json:"fee_rate"`
+	NetProceeds  float64        `json:"net_proceeds"`
+	Lots         []RedeemLotFee `json:"lots"`
+	NextTierDate *time.Time     `json:"next_tier_date"`
+	NextTierFee  float64        `json:"next_tier_fee"`
+}
+
+// GetFeeSchedule 获取本地保存的基金费率表，没有时返回 sql.ErrNoRows；
+// 费率在订阅时与每日收盘后的定时任务中抓取，读取时不访问数据源
+func (s *FundService) GetFeeSchedule(code string) (*models.FeeSchedule, error) {
+	return s.loadFeeSchedule(code)
+}
+
+// ensureFeeSchedule 本地没有费率表时从数据源抓取一次；
+// 抓取失败的基金在 feeRetryInterval 内不再重复抓取，直接返回上次的错误
+func (s *FundService) ensureFeeSchedule(code string) error {
+	if _, err := s.loadFeeSchedule(code); !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	s.feeMu.Lock()
+	failure, failed := s.feeFailures[code]
+	s.feeMu.Unlock()
+	if failed && time.Since(failure.at) < feeRetryInterval {
+		return failure.err
+	}
+	return s.RefreshFeeSchedule(code)
+}
+
+// RefreshMissingFeeSchedules 为尚无费率表的订阅基金与持仓基金抓取费率
+func (s *FundService) RefreshMissingFeeSchedules() {
+	rows, err := s.db.Query(`
+		SELECT code FROM funds WHERE subscribed = 1
+		UNION
+		SELECT fund_code FROM positions
+		EXCEPT
+		SELECT fund_code FROM fund_fees
+	`)
+	if err != nil {
+		log.Printf("Failed to load funds for fee refresh: %v", err)
+		return
+	}
+	var codes []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			rows.Close()
+			log.Printf("Failed to load funds for fee refresh: %v", err)
+			return
+		}
+		codes = append(codes, code)
+	}
+	rows.Close()
+
+	for _, code := range codes {
+		if err := s.ensureFeeSchedule(code); err != nil {
+			log.Printf("Failed to fetch fee schedule of %s: %v", code, err)
+		}
+	}
+}
+
+// RefreshFeeSchedule 从数据源抓取并保存基金费率表，失败时记录下来供 ensureFeeSchedule 跳过重试
+func (s *FundService) RefreshFeeSchedule(code string) error {
+	if s.fees == nil {
+		return errors.New("no fee data source configured")
+	}
+
+	fees, err := s.fees.FetchFees(code)
+	s.feeMu.Lock()
+	if err != nil {
+		s.feeFailures[code] = feeFailure{at: time.Now(), err: err}
+	} else {
+		delete(s.feeFailures, code)
+	}
+	s.feeMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	schedule := &models.FeeSchedule{
+		FundCode:       code,
+		PurchaseRate:   fees.PurchaseRate,
+		ManagementRate: fees.ManagementRate,
+		CustodyRate:    fees.CustodyRate,
+		ServiceRate:    fees.ServiceRate,
+		Source:         fees.Source,
+	}
+	for _, tier := range fees.RedemptionTiers {
+		schedule.RedemptionTiers = append(schedule.RedemptionTiers, models.RedemptionTier(tier))
+	}
+	return s.SaveFeeSchedule(schedule)
+}
+
+// SaveFeeSchedule 保存费率表，覆盖该基金已有的费率；Source 为空时记为手工录入
+func (s *FundService) SaveFeeSchedule(schedule *models.FeeSchedule) error {
+	if err := validateFeeSchedule(schedule); err != nil {
+		return err
+	}
+	if schedule.Source == "" {
+		schedule.Source = feeSourceManual
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT OR REPLACE INTO fund_fees
+			(fund_code, purchase_rate, management_rate, custody_rate, service_rate, source, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, schedule.FundCode, schedule.PurchaseRate, schedule.ManagementRate, schedule.CustodyRate,
+		schedule.ServiceRate, schedule.Source, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM fund_redemption_fees WHERE fund_code = ?`, schedule.FundCode); err != nil {
+		return err
+	}
+	for _, tier := range schedule.RedemptionTiers {
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO fund_redemption_fees (fund_code, min_days, max_days, rate)
+			VALUES (?, ?, ?, ?)
+		`, schedule.FundCode, tier.MinDays, tier.MaxDays, tier.Rate)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// validateFeeSchedule 校验费率取值范围与赎回档位区间
+func validateFeeSchedule(schedule *models.FeeSchedule) error {
+	if schedule.FundCode == "" {
+		return fmt.Errorf("%w: fund_code is required", ErrInvalidInput)
+	}
+	for _, rate := range []float64{schedule.PurchaseRate, schedule.ManagementRate, schedule.CustodyRate, schedule.ServiceRate} {
+		if rate < 0 || rate >= 1 {
+			return fmt.Errorf("%w: fee rates must be in [0, 1)", ErrInvalidInput)
+		}
+	}
+	for _, tier := range schedule.RedemptionTiers {
+		if tier.MinDays < 0 || (tier.MaxDays != 0 && tier.MaxDays <= tier.MinDays) || tier.Rate < 0 || tier.Rate >= 1 {
+			return fmt.Errorf("%w: invalid redemption tier %+v", ErrInvalidInput, tier)
+		}
+	}
+	return nil
+}
+
+// loadFeeSchedule 读取本地费率表，赎回档位按持有天数升序
+func (s *FundService) loadFeeSchedule(code string) (*models.FeeSchedule, error) {
+	schedule := &models.FeeSchedule{FundCode: code}
+	err := s.db.QueryRow(`
+		SELECT purchase_rate, management_rate, custody_rate, service_rate, source, updated_at
+		FROM fund_fees WHERE fund_code = ?
+	`, code).Scan(&schedule.PurchaseRate, &schedule.ManagementRate, &schedule.CustodyRate,
+		&schedule.ServiceRate, &schedule.Source, &schedule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT min_days, max_days, rate FROM fund_redemption_fees
+		WHERE fund_code = ? ORDER BY min_days
+	`, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedule.RedemptionTiers = make([]models.RedemptionTier, 0)
+	for rows.Next() {
+		var tier models.RedemptionTier
+		if err := rows.Scan(&tier.MinDays, &tier.MaxDays, &tier.Rate); err != nil {
+			return nil, err
+		}
+		schedule.RedemptionTiers = append(schedule.RedemptionTiers, tier)
+	}
+	return schedule, rows.Err()
+}
+
+// feeSchedule 获取用于计算手续费的费率表，本地没有时返回 ErrInsufficientData，而不是按零费率处理
+func (s *FundService) feeSchedule(code string) (*models.FeeSchedule, error) {
+	schedule, err := s.loadFeeSchedule(code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: fee schedule of %s unknown, enter it manually or pass the fee", ErrInsufficientData, code)
+	}
+	return schedule, err
+}
+
+// purchaseFee 计算申购费：按金额申购时 手续费 = 金额 - 金额 / (1 + 费率)，按份额时 手续费 = 份额 × 净值 × 费率
+func purchaseFee(rate, amount, shares, price float64) float64 {
+	if amount > 0 {
+		return round(amount-amount/(1+rate), 2)
+	}
+	return round(shares*price*rate, 2)
+}
+
+// redemptionRate 返回持有 days 天适用的赎回费率，未落入任何档位时为 0
+func redemptionRate(tiers []models.RedemptionTier, days int) float64 {
+	for _, tier := range tiers {
+		if days >= tier.MinDays && (tier.MaxDays == 0 || days < tier.MaxDays) {
+			return tier.Rate
+		}
+	}
+	return 0
+}
+
+// nextCheaperTier 返回持有 days 天之后第一个费率更低的档位起始天数，没有时返回 false
+func nextCheaperTier(tiers []models.RedemptionTier, days int) (int, bool) {
+	current := redemptionRate(tiers, days)
+	sorted := append([]models.RedemptionTier(nil), tiers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinDays < sorted[j].MinDays })
+	for _, tier := range sorted {
+		if tier.MinDays > days && tier.Rate < current {
+			return tier.MinDays, true
+		}
+	}
+	return 0, false
+}
+
+// redemptionFee 计算从 consumed 各批次赎回共 shares 份、总额 gross 时的赎回费，返回各批次明细
+func redemptionFee(tiers []models.RedemptionTier, consumed []lotConsumption, shares, gross float64, on time.Time) (float64, []RedeemLotFee) {
+	if shares <= 0 {
+		return 0, nil
+	}
+
+	var total float64
+	var details []RedeemLotFee
+	for _, item := range consumed {
+		days := holdingDays(item.Lot, on)
+		rate := redemptionRate(tiers, days)
+		fee := round(gross*item.Shares/shares*rate, 2)
+		total += fee
+		details = append(details, RedeemLotFee{
+			LotID:       item.Lot.TransactionID,
+			TradeDate:   item.Lot.TradeDate,
+			ConfirmDate: item.Lot.ConfirmDate,
+			Shares:      round(item.Shares, 2),
+			HoldingDays: days,
+			Rate:        rate,
+			Fee:         fee,
+		})
+	}
+	return round(total, 2), details
+}
+
+// sellFee 按费率表与所赎回批次（lotIDs 为空时先进先出）计算一笔赎回的手续费
+func (s *FundService) sellFee(accountID int64, fundCode string, shares, gross float64, tradeDate time.Time, lotIDs []int64) (float64, error) {
+	lots, err := s.openLots(accountID, fundCode, tradeDate)
+	if err != nil {
+		return 0, err
+	}
+	consumed, err := selectLots(lots, shares, lotIDs)
+	if err != nil {
+		return 0, err
+	}
+	schedule, err := s.feeSchedule(fundCode)
+	if err != nil {
+		return 0, err
+	}
+	fee, _ := redemptionFee(schedule.RedemptionTiers, consumed, shares, gross, tradeDate)
+	return fee, nil
+}
+
+// PreviewRedemption 预览按最新估值净值赎回持仓 shares 份的费用与到账金额
+// lotIDs 为空时按先进先出赎回，shares 为 0 表示全部持有份额或所选批次的全部份额
+func (s *FundService) PreviewRedemption(positionID int64, shares float64, lotIDs []int64) (*RedeemPreview, error) {
+	position, err := s.GetPositionByID(positionID)
+	if err != nil {
+		return nil, err
+	}
+
+	lots, err := s.openLots(position.AccountID, position.FundCode, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if shares <= 0 {
+		shares = position.Shares
+		if len(lotIDs) > 0 {
+			shares = lotShares(lots, lotIDs)
+		}
+	}
+	if shares > position.Shares+shareEpsilon {
+		return nil, fmt.Errorf("%w: redeem %.2f shares exceeds holding %.2f", ErrInvalidTransaction, shares, position.Shares)
+	}
+	consumed, err := selectLots(lots, shares, lotIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	nav := s.positionNav(position)
+
+	schedule, err := s.feeSchedule(position.FundCode)
+	if err != nil {
+		return nil, err
+	}
+
+	redeemDate := calendar.Default().NavDate(time.Now())
+	gross := round(shares*nav, 2)
+	fee, details := redemptionFee(schedule.RedemptionTiers, consumed, shares, gross, redeemDate)
+
+	preview := &RedeemPreview{
+		PositionID:  position.ID,
+		FundCode:    position.FundCode,
+		Shares:      round(shares, 2),
+		Nav:         nav,
+		RedeemDate:  redeemDate,
+		GrossAmount: gross,
+		Fee:         fee,
+		NetProceeds: round(gross-fee, 2),
+		Lots:        details,
+	}
+	if gross > 0 {
+		preview.FeeRate = round(fee/gross, 6)
+	}
+
+	// 所赎回批次中最早进入更低费率档的日期
+	for _, detail := range details {
+		minDays, ok := nextCheaperTier(schedule.RedemptionTiers, detail.HoldingDays)
+		if !ok {
+			continue
+		}
+		unlock := tierUnlockDate(fundLot{ConfirmDate: detail.ConfirmDate}, minDays)
+		if preview.NextTierDate == nil || unlock.Before(*preview.NextTierDate) {
+			preview.NextTierDate = &unlock
+		}
+	}
+	if preview.NextTierDate != nil {
+		preview.NextTierFee, _ = redemptionFee(schedule.RedemptionTiers, consumed, shares, gross, *preview.NextTierDate)
+	}
+
+	return preview, nil
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"testing"
+
+	"fundnet/backend/internal/models"
+)
+
+func TestRedemptionRate(t *testing.T) {
+	tiers := []models.RedemptionTier{
+		{MinDays: 0, MaxDays: 7, Rate: 0.015},
+		{MinDays: 7, MaxDays: 365, Rate: 0.005},
+		{MinDays: 365, MaxDays: 730, Rate: 0.0025},
+		{MinDays: 730, MaxDays: 0, Rate: 0},
+	}
+	gapped := []models.RedemptionTier{
+		{MinDays: 0, MaxDays: 7, Rate: 0.015},
+		{MinDays: 30, MaxDays: 0, Rate: 0.001},
+	}
+
+	tests := []struct {
+		name  string
+		tiers []models.RedemptionTier
+		days  int
+		want  float64
+	}{
+		{"first day", tiers, 0, 0.015},
+		{"last day of short tier", tiers, 6, 0.015},
+		{"tier lower bound is inclusive", tiers, 7, 0.005},
+		{"tier upper bound is exclusive", tiers, 365, 0.0025},
+		{"open-ended tier", tiers, 2000, 0},
+		{"between tiers", gapped, 10, 0},
+		{"open-ended tier after gap", gapped, 30, 0.001},
+		{"no tiers", nil, 3, 0},
+	}
+	for _, tt := range tests {
+		if got := redemptionRate(tt.tiers, tt.days); got != tt.want {
+			t.Errorf("%s: redemptionRate(%d) = %v, want %v", tt.name, tt.days, got, tt.want)
+		}
+	}
+}
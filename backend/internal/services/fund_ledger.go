@@ -0,0 +1,501 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+)
+
+// shareEpsilon 份额比较容差，低于该值视为清仓
+const shareEpsilon = 1e-6
+
+// ErrInvalidTransaction 交易参数不合法或与已有流水冲突
+var ErrInvalidTransaction = errors.New("invalid transaction")
+
+// ErrPositionDerived 持仓份额与成本由交易流水推导，不能直接修改
+var ErrPositionDerived = errors.New("position shares and cost are derived from transactions")
+
+// transactionColumns 交易表查询列，与 scanTransaction 的扫描顺序一致
+const transactionColumns = `id, account_id, fund_code, type, status, order_time, trade_date, shares, price,
+		       amount, fee, fee_rate, lot_ids, realized_profit, note, created_at, updated_at`
+
+// scanTransaction 扫描一行交易数据
+func scanTransaction(row rowScanner) (*models.Transaction, error) {
+	var t models.Transaction
+	var orderTime sql.NullTime
+	var lotIDs string
+
+	err := row.Scan(
+		&t.ID, &t.AccountID, &t.FundCode, &t.Type, &t.Status, &orderTime, &t.TradeDate,
+		&t.Shares, &t.Price, &t.Amount, &t.Fee, &t.FeeRate, &lotIDs, &t.RealizedProfit, &t.Note,
+		&t.CreatedAt, &t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	t.OrderTime = orderTime.Time
+	if t.LotIDs, err = ParseLotIDs(lotIDs); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// TransactionInput 记录交易的参数
+// Amount 的含义随交易类型而定：
+//   - buy / transfer_in：实际支付金额（含手续费），为 0 时按 Shares × Price + Fee 计算；
+//     Shares 为 0 时按 (Amount - Fee) / Price 折算份额
+//   - sell：赎回总额（未扣手续费），为 0 时按 Shares × Price 计算
+//   - dividend_cash / fee：现金分红金额 / 额外扣费金额
+//   - dividend_reinvest：再投资的分红金额，仅作记录，为 0 时按 Shares × Price 计算
+//
+// Fee 为空时，买入按费率表的申购费率、卖出按各批次持有期对应的赎回费率计算手续费。
+// LotIDs 仅用于卖出，按顺序指定要赎回的批次（买入交易 ID），为空时按先进先出；
+// 指定批次且 Shares 为 0 时赎回这些批次的全部份额
+type TransactionInput struct {
+	AccountID int64
+	FundCode  string
+	FundName  string
+	Type      string
+	TradeDate time.Time
+	Shares    float64
+	Price     float64
+	Amount    float64
+	Fee       *float64
+	LotIDs    []int64
+	Note      string
+	Sector    string
+}
+
+// TransactionFilter 交易流水查询条件，零值字段不参与过滤
+type TransactionFilter struct {
+	AccountID   *int64
+	PortfolioID *int64
+	FundCode    string
+	Type        string
+	Status      string
+	From        time.Time
+	To          time.Time
+}
+
+// ledgerState 按流水回放得到的持仓状态
+type ledgerState struct {
+	shares    float64
+	costBasis float64
+	realized  float64
+}
+
+// apply 按加权平均成本法应用一笔交易，返回该笔交易产生的已实现盈亏
+// 红利再投资与货币基金收益结转的份额成本为 0，摊薄持仓均价；份额拆分按 Price 记录的比例折算份额，成本不变；
+// 单独的费用计入已实现亏损
+func (l *ledgerState) apply(t *models.Transaction) (float64, error) {
+	switch t.Type {
+	case models.TransactionBuy, models.TransactionTransferIn:
+		l.shares += t.Shares
+		l.costBasis += t.Amount
+		return 0, nil
+	case models.TransactionSell:
+		if t.Shares > l.shares+shareEpsilon {
+			return 0, fmt.Errorf("%w: sell %.2f shares of %s on %s exceeds holding %.2f",
+				ErrInvalidTransaction, t.Shares, t.FundCode, t.TradeDate.Format(calendar.DateLayout), l.shares)
+		}
+		costOut := l.costBasis * t.Shares / l.shares
+		realized := t.Amount - t.Fee - costOut
+		l.shares -= t.Shares
+		l.costBasis -= costOut
+		if l.shares < shareEpsilon {
+			l.shares, l.costBasis = 0, 0
+		}
+		l.realized += realized
+		return realized, nil
+	case models.TransactionDividendCash:
+		l.realized += t.Amount
+		return t.Amount, nil
+	case models.TransactionDividendReinvest, models.TransactionIncome:
+		l.shares += t.Shares
+		return 0, nil
+	case models.TransactionSplit:
+		if t.Price <= 0 {
+			return 0, fmt.Errorf("%w: invalid split ratio %.4f", ErrInvalidTransaction, t.Price)
+		}
+		l.shares *= t.Price
+		return 0, nil
+	case models.TransactionFee:
+		l.realized -= t.Amount
+		return -t.Amount, nil
+	}
+	return 0, fmt.Errorf("%w: unknown type %q", ErrInvalidTransaction, t.Type)
+}
+
+// normalizeTransaction 校验交易参数并补全缺省的金额或份额
+func normalizeTransaction(input TransactionInput) (*models.Transaction, error) {
+	if input.FundCode == "" {
+		return nil, fmt.Errorf("%w: fund_code is required", ErrInvalidTransaction)
+	}
+	var fee float64
+	if input.Fee != nil {
+		fee = *input.Fee
+	}
+	if input.Shares < 0 || input.Price < 0 || input.Amount < 0 || fee < 0 {
+		return nil, fmt.Errorf("%w: shares, price, amount and fee must not be negative", ErrInvalidTransaction)
+	}
+
+	tradeDate := input.TradeDate
+	if tradeDate.IsZero() {
+		tradeDate = time.Now()
+	}
+
+	t := &models.Transaction{
+		AccountID: input.AccountID,
+		FundCode:  input.FundCode,
+		Type:      input.Type,
+		Status:    models.TransactionConfirmed,
+		OrderTime: tradeDate,
+		TradeDate: tradeDate,
+		Shares:    input.Shares,
+		Price:     input.Price,
+		Amount:    input.Amount,
+		Fee:       fee,
+		Note:      input.Note,
+	}
+	if len(input.LotIDs) > 0 {
+		if t.Type != models.TransactionSell {
+			return nil, fmt.Errorf("%w: lot_ids only applies to sell", ErrInvalidTransaction)
+		}
+		if err := checkLotIDs(input.LotIDs); err != nil {
+			return nil, err
+		}
+		t.LotIDs = input.LotIDs
+	}
+
+	switch t.Type {
+	case models.TransactionBuy, models.TransactionTransferIn:
+		if t.Shares == 0 && t.Price > 0 {
+			t.Shares = round((t.Amount-t.Fee)/t.Price, 2)
+		}
+		if t.Amount == 0 {
+			t.Amount = round(t.Shares*t.Price+t.Fee, 2)
+		}
+		if t.Shares <= 0 {
+			return nil, fmt.Errorf("%w: shares or amount with price is required", ErrInvalidTransaction)
+		}
+	case models.TransactionSell, models.TransactionDividendReinvest:
+		if t.Shares <= 0 {
+			return nil, fmt.Errorf("%w: shares is required", ErrInvalidTransaction)
+		}
+		if t.Amount == 0 {
+			t.Amount = round(t.Shares*t.Price, 2)
+		}
+	case models.TransactionDividendCash, models.TransactionFee:
+		if t.Amount <= 0 {
+			return nil, fmt.Errorf("%w: amount is required", ErrInvalidTransaction)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidTransaction, t.Type)
+	}
+
+	return t, nil
+}
+
+// RecordTransaction 记录一笔交易并重新推导对应持仓
+// 持仓不存在时以 FundName、Sector（缺省取订阅基金的名称与板块）创建
+func (s *FundService) RecordTransaction(input TransactionInput) (*models.Position, error) {
+	accountID, err := s.resolveAccount(input.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	input.AccountID = accountID
+
+	if input.Type == models.TransactionSell && len(input.LotIDs) > 0 {
+		shares, err := s.selectedLotShares(input)
+		if err != nil {
+			return nil, err
+		}
+		if input.Shares == 0 {
+			input.Shares = shares
+		}
+	}
+
+	if input.Fee == nil {
+		fee, err := s.defaultFee(input)
+		if err != nil {
+			return nil, err
+		}
+		input.Fee = &fee
+	}
+
+	t, err := normalizeTransaction(input)
+	if err != nil {
+		return nil, err
+	}
+	return s.insertTransaction(input, t)
+}
+
+// defaultFee 按费率表估算买入或卖出的手续费，其他交易类型不收费
+func (s *FundService) defaultFee(input TransactionInput) (float64, error) {
+	switch input.Type {
+	case models.TransactionBuy:
+		schedule, err := s.feeSchedule(input.FundCode)
+		if err != nil {
+			return 0, err
+		}
+		return purchaseFee(schedule.PurchaseRate, input.Amount, input.Shares, input.Price), nil
+	case models.TransactionSell:
+		tradeDate := input.TradeDate
+		if tradeDate.IsZero() {
+			tradeDate = time.Now()
+		}
+		gross := input.Amount
+		if gross == 0 {
+			gross = round(input.Shares*input.Price, 2)
+		}
+		return s.sellFee(input.AccountID, input.FundCode, input.Shares, gross, tradeDate, input.LotIDs)
+	}
+	return 0, nil
+}
+
+// insertTransaction 在事务中写入交易并重新推导持仓
+func (s *FundService) insertTransaction(input TransactionInput, t *models.Transaction) (*models.Position, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := s.ensurePosition(tx, input); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		INSERT INTO transactions
+			(account_id, fund_code, type, status, order_time, trade_date, shares, price, amount, fee, fee_rate,
+			 lot_ids, note, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, t.AccountID, t.FundCode, t.Type, t.Status, t.OrderTime, calendar.Default().FormatDate(t.TradeDate),
+		t.Shares, t.Price, t.Amount, t.Fee, t.FeeRate, formatLotIDs(t.LotIDs), t.Note, now, now)
+	if err != nil {
+		return nil, err
+	}
+	t.ID, _ = result.LastInsertId()
+
+	id, err := rebuildPosition(tx, t.AccountID, t.FundCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetPositionByID(id)
+}
+
+// ensurePosition 持仓不存在时创建空持仓
+func (s *FundService) ensurePosition(tx *sql.Tx, input TransactionInput) error {
+	fundName, sector := input.FundName, input.Sector
+	if fundName == "" || sector == "" {
+		var name, fundSector sql.NullString
+		err := tx.QueryRow(`SELECT name, sector FROM funds WHERE code = ?`, input.FundCode).Scan(&name, &fundSector)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if fundName == "" {
+			fundName = name.String
+		}
+		if sector == "" {
+			sector = fundSector.String
+		}
+	}
+
+	now := time.Now()
+	_, err := tx.Exec(`
+		INSERT INTO positions (account_id, fund_code, fund_name, sector, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (account_id, fund_code) DO NOTHING
+	`, input.AccountID, input.FundCode, fundName, sector, now, now)
+	return err
+}
+
+// DeleteTransaction 删除一笔交易并重新推导对应持仓
+func (s *FundService) DeleteTransaction(id int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var accountID int64
+	var fundCode string
+	err = tx.QueryRow(`SELECT account_id, fund_code FROM transactions WHERE id = ?`, id).Scan(&accountID, &fundCode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM transactions WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := rebuildPosition(tx, accountID, fundCode); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetTransactions 按条件查询交易流水，按交易日期升序
+func (s *FundService) GetTransactions(filter TransactionFilter) ([]models.Transaction, error) {
+	query := `
+		SELECT ` + transactionColumns + `
+		FROM transactions
+		WHERE 1 = 1
+	`
+	var args []interface{}
+	if filter.AccountID != nil {
+		query += " AND account_id = ?"
+		args = append(args, *filter.AccountID)
+	}
+	if filter.PortfolioID != nil {
+		query += " AND account_id IN (SELECT id FROM accounts WHERE portfolio_id = ?)"
+		args = append(args, *filter.PortfolioID)
+	}
+	if filter.FundCode != "" {
+		query += " AND fund_code = ?"
+		args = append(args, filter.FundCode)
+	}
+	if filter.Type != "" {
+		query += " AND type = ?"
+		args = append(args, filter.Type)
+	}
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+	if !filter.From.IsZero() {
+		query += " AND trade_date >= ?"
+		args = append(args, filter.From.Format(calendar.DateLayout))
+	}
+	if !filter.To.IsZero() {
+		query += " AND trade_date <= ?"
+		args = append(args, filter.To.Format(calendar.DateLayout))
+	}
+	query += " ORDER BY trade_date, id"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := make([]models.Transaction, 0)
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, *t)
+	}
+
+	return transactions, rows.Err()
+}
+
+// rebuildPosition 按交易日期顺序回放已确认的流水，更新每笔交易的已实现盈亏与持仓的份额、成本
+// 待确认申购计入在途金额，持仓状态随之为 pending；流水为空时删除持仓；市值按持仓最近一次估值所用净值重算。返回持仓 ID
+func rebuildPosition(tx *sql.Tx, accountID int64, fundCode string) (int64, error) {
+	rows, err := tx.Query(`
+		SELECT id, fund_code, type, status, trade_date, shares, price, amount, fee
+		FROM transactions
+		WHERE account_id = ? AND fund_code = ?
+		ORDER BY trade_date, id
+	`, accountID, fundCode)
+	if err != nil {
+		return 0, err
+	}
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		var t models.Transaction
+		if err := rows.Scan(&t.ID, &t.FundCode, &t.Type, &t.Status, &t.TradeDate, &t.Shares, &t.Price, &t.Amount, &t.Fee); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		transactions = append(transactions, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var positionID int64
+	var valuationNav float64
+	err = tx.QueryRow(`
+		SELECT id, valuation_nav FROM positions WHERE account_id = ? AND fund_code = ?
+	`, accountID, fundCode).Scan(&positionID, &valuationNav)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(transactions) == 0 {
+		_, err := tx.Exec(`DELETE FROM positions WHERE id = ?`, positionID)
+		return positionID, err
+	}
+
+	var state ledgerState
+	var pendingAmount float64
+	for i := range transactions {
+		// 待确认的申购尚无份额，只计入在途金额
+		if transactions[i].Status == models.TransactionPending {
+			pendingAmount += transactions[i].Amount
+			continue
+		}
+		realized, err := state.apply(&transactions[i])
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`
+			UPDATE transactions SET realized_profit = ? WHERE id = ?
+		`, round(realized, 2), transactions[i].ID); err != nil {
+			return 0, err
+		}
+	}
+
+	costBasis := round(state.costBasis, 2)
+	cost := float64(0)
+	if state.shares > 0 {
+		cost = round(state.costBasis/state.shares, 4)
+	}
+	currentValue, profitLoss, profitRate := float64(0), float64(0), float64(0)
+	if valuationNav > 0 {
+		currentValue = round(state.shares*valuationNav, 2)
+		profitLoss = round(currentValue-costBasis, 2)
+		if costBasis > 0 {
+			profitRate = round(profitLoss/costBasis*100, 2)
+		}
+	}
+
+	status := models.PositionHolding
+	switch {
+	case pendingAmount > 0:
+		status = models.PositionPending
+	case state.shares == 0:
+		status = models.PositionClosed
+	}
+
+	// 分红方式以 position_settings 中保存的设置为准，持仓行重新创建后也能恢复
+	_, err = tx.Exec(`
+		UPDATE positions SET shares = ?, cost = ?, cost_basis = ?, current_value = ?, profit_loss = ?,
+		       profit_rate = ?, realized_profit = ?, pending_amount = ?, status = ?,
+		       dividend_mode = COALESCE((
+		           SELECT dividend_mode FROM position_settings WHERE account_id = ? AND fund_code = ?
+		       ), dividend_mode),
+		       updated_at = ?
+		WHERE id = ?
+	`, round(state.shares, 2), cost, costBasis, currentValue, profitLoss, profitRate,
+		round(state.realized, 2), round(pendingAmount, 2), status, accountID, fundCode, time.Now(), positionID)
+	if err != nil {
+		return 0, err
+	}
+
+	return positionID, nil
+}
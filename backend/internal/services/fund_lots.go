@@ -0,0 +1,354 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+)
+
+// fundLot 一笔买入（或转入、红利再投资）形成的持仓批次
+// ConfirmDate 为份额确认日（净值日的下一个交易日，QDII 基金按净值滞后顺延），持有期自该日起算
+type fundLot struct {
+	TransactionID int64
+	TradeDate     time.Time
+	ConfirmDate   time.Time
+	Shares        float64
+	Cost          float64
+}
+
+// lotConsumption 赎回时从某一批次扣减的份额
+type lotConsumption struct {
+	Lot    fundLot
+	Shares float64
+}
+
+// LotView 持仓批次明细：持有天数与费率档按当前提交赎回的净值日计算
+// FeeRate 为当前适用的赎回费率，NextTierDate 为进入更低费率档的日期（已是最低档时为空）；
+// 基金尚无费率表时 FeeKnown 为 false，不给出费率档信息
+type LotView struct {
+	LotID        int64      `json:"lot_id"`
+	TradeDate    time.Time  `json:"trade_date"`
+	ConfirmDate  time.Time  `json:"confirm_date"`
+	Shares       float64    `json:"shares"`
+	Cost         float64    `json:"cost"`
+	UnitCost     float64    `json:"unit_cost"`
+	HoldingDays  int        `json:"holding_days"`
+	FeeKnown     bool       `json:"fee_known"`
+	FeeRate      float64    `json:"fee_rate"`
+	FeeFree      bool       `json:"fee_free"`
+	NextTierDate *time.Time `json:"next_tier_date"`
+	NextTierRate float64    `json:"next_tier_rate"`
+	Nav          float64    `json:"nav"`
+	CurrentValue float64    `json:"current_value"`
+	ProfitLoss   float64    `json:"profit_loss"`
+	ProfitRate   float64    `json:"profit_rate"`
+}
+
+// GetPositionLots 列出持仓仍持有的各批次及其持有期、赎回费率档与浮动盈亏
+func (s *FundService) GetPositionLots(positionID int64) ([]LotView, error) {
+	position, err := s.GetPositionByID(positionID)
+	if err != nil {
+		return nil, err
+	}
+	lots, err := s.openLots(position.AccountID, position.FundCode, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	nav := s.positionNav(position)
+	schedule, err := s.feeSchedule(position.FundCode)
+	if err != nil && !errors.Is(err, ErrInsufficientData) {
+		return nil, err
+	}
+	redeemDate := calendar.Default().NavDate(time.Now())
+
+	views := make([]LotView, 0, len(lots))
+	for _, lot := range lots {
+		days := holdingDays(lot, redeemDate)
+		view := LotView{
+			LotID:       lot.TransactionID,
+			TradeDate:   lot.TradeDate,
+			ConfirmDate: lot.ConfirmDate,
+			Shares:      round(lot.Shares, 2),
+			Cost:        round(lot.Cost, 2),
+			UnitCost:    round(lot.Cost/lot.Shares, 4),
+			HoldingDays: days,
+			Nav:         nav,
+		}
+		if schedule != nil {
+			view.FeeKnown = true
+			view.FeeRate = redemptionRate(schedule.RedemptionTiers, days)
+			view.FeeFree = view.FeeRate == 0
+			if minDays, ok := nextCheaperTier(schedule.RedemptionTiers, days); ok {
+				unlock := tierUnlockDate(lot, minDays)
+				view.NextTierDate = &unlock
+				view.NextTierRate = redemptionRate(schedule.RedemptionTiers, minDays)
+			}
+		}
+		if nav > 0 {
+			view.CurrentValue = round(lot.Shares*nav, 2)
+			view.ProfitLoss = round(view.CurrentValue-lot.Cost, 2)
+			if lot.Cost > 0 {
+				view.ProfitRate = round(view.ProfitLoss/lot.Cost*100, 2)
+			}
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// positionNav 持仓估值所用净值，尚未估值时取基金最新官方净值
+func (s *FundService) positionNav(position *models.Position) float64 {
+	if position.ValuationNav > 0 {
+		return position.ValuationNav
+	}
+	if fund, err := s.GetFundByCode(position.FundCode); err == nil {
+		return fund.Nav
+	}
+	return 0
+}
+
+// tierUnlockDate 批次持有满 minDays 天的日期，遇非交易日顺延至下一个交易日
+func tierUnlockDate(lot fundLot, minDays int) time.Time {
+	cal := calendar.Default()
+	unlock := lot.ConfirmDate.AddDate(0, 0, minDays)
+	if !cal.IsTradingDay(unlock) {
+		unlock = cal.NextTradingDay(unlock)
+	}
+	return unlock
+}
+
+// openLots 按交易日期回放已确认的流水，返回 asOf 当日（含）及之前形成且仍持有的批次
+// 卖出按其指定的批次扣减，未指定时先进先出；份额拆分按比例折算各批次份额；asOf 为零值时回放全部流水
+func (s *FundService) openLots(accountID int64, fundCode string, asOf time.Time) ([]fundLot, error) {
+	query := `
+		SELECT id, type, trade_date, shares, price, amount, lot_ids
+		FROM transactions
+		WHERE account_id = ? AND fund_code = ? AND status = ?
+	`
+	args := []interface{}{accountID, fundCode, models.TransactionConfirmed}
+	if !asOf.IsZero() {
+		query += " AND trade_date <= ?"
+		args = append(args, calendar.Default().FormatDate(asOf))
+	}
+	query += " ORDER BY trade_date, id"
+
+	navLag := s.fundNavLag(fundCode)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []fundLot
+	for rows.Next() {
+		var t models.Transaction
+		var lotIDs string
+		if err := rows.Scan(&t.ID, &t.Type, &t.TradeDate, &t.Shares, &t.Price, &t.Amount, &lotIDs); err != nil {
+			return nil, err
+		}
+		if t.LotIDs, err = ParseLotIDs(lotIDs); err != nil {
+			return nil, err
+		}
+
+		switch t.Type {
+		case models.TransactionBuy, models.TransactionTransferIn, models.TransactionDividendReinvest,
+			models.TransactionIncome:
+			cost := t.Amount
+			if t.Type != models.TransactionBuy && t.Type != models.TransactionTransferIn {
+				cost = 0
+			}
+			lots = append(lots, fundLot{
+				TransactionID: t.ID,
+				TradeDate:     t.TradeDate,
+				ConfirmDate:   confirmDate(t.TradeDate, navLag),
+				Shares:        t.Shares,
+				Cost:          cost,
+			})
+		case models.TransactionSell:
+			consumed, err := selectLots(lots, t.Shares, t.LotIDs)
+			if err != nil {
+				return nil, err
+			}
+			lots = removeConsumed(lots, consumed)
+		case models.TransactionSplit:
+			for i := range lots {
+				lots[i].Shares *= t.Price
+			}
+		}
+	}
+
+	return lots, rows.Err()
+}
+
+// selectLots 计算赎回 shares 份时各批次的扣减份额：lotIDs 为空时先进先出，否则按指定批次的顺序扣减
+func selectLots(lots []fundLot, shares float64, lotIDs []int64) ([]lotConsumption, error) {
+	if len(lotIDs) == 0 {
+		return consumeFIFO(lots, shares), nil
+	}
+
+	if err := checkLotIDs(lotIDs); err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]fundLot, len(lots))
+	for _, lot := range lots {
+		byID[lot.TransactionID] = lot
+	}
+
+	var consumed []lotConsumption
+	remaining := shares
+	for _, id := range lotIDs {
+		lot, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: lot %d is not held", ErrInvalidTransaction, id)
+		}
+		if remaining < shareEpsilon {
+			break
+		}
+		take := lot.Shares
+		if take > remaining {
+			take = remaining
+		}
+		consumed = append(consumed, lotConsumption{Lot: lot, Shares: take})
+		remaining -= take
+	}
+	if remaining >= shareEpsilon {
+		return nil, fmt.Errorf("%w: selected lots hold %.2f shares, %.2f requested",
+			ErrInvalidTransaction, shares-remaining, shares)
+	}
+	return consumed, nil
+}
+
+// checkLotIDs 校验指定的批次 ID 不重复，重复指定会使同一批次被扣减两次
+func checkLotIDs(lotIDs []int64) error {
+	seen := make(map[int64]bool, len(lotIDs))
+	for _, id := range lotIDs {
+		if seen[id] {
+			return fmt.Errorf("%w: lot %d is selected more than once", ErrInvalidTransaction, id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// lotShares 指定批次的份额合计，未持有的批次忽略
+func lotShares(lots []fundLot, lotIDs []int64) float64 {
+	selected := make(map[int64]bool, len(lotIDs))
+	for _, id := range lotIDs {
+		selected[id] = true
+	}
+	var total float64
+	for _, lot := range lots {
+		if selected[lot.TransactionID] {
+			total += lot.Shares
+		}
+	}
+	return round(total, 2)
+}
+
+// selectedLotShares 校验卖出指定的批次在交易日持有，返回这些批次的份额合计
+func (s *FundService) selectedLotShares(input TransactionInput) (float64, error) {
+	tradeDate := input.TradeDate
+	if tradeDate.IsZero() {
+		tradeDate = time.Now()
+	}
+	lots, err := s.openLots(input.AccountID, input.FundCode, tradeDate)
+	if err != nil {
+		return 0, err
+	}
+
+	shares := lotShares(lots, input.LotIDs)
+	if input.Shares > 0 {
+		shares = input.Shares
+	}
+	if _, err := selectLots(lots, shares, input.LotIDs); err != nil {
+		return 0, err
+	}
+	return shares, nil
+}
+
+// consumeFIFO 按先进先出计算赎回 shares 份时各批次的扣减份额
+func consumeFIFO(lots []fundLot, shares float64) []lotConsumption {
+	var consumed []lotConsumption
+	remaining := shares
+	for _, lot := range lots {
+		if remaining < shareEpsilon {
+			break
+		}
+		take := lot.Shares
+		if take > remaining {
+			take = remaining
+		}
+		consumed = append(consumed, lotConsumption{Lot: lot, Shares: take})
+		remaining -= take
+	}
+	return consumed
+}
+
+// removeConsumed 从批次中扣除已赎回的份额，成本按份额比例扣减，扣完的批次移除
+func removeConsumed(lots []fundLot, consumed []lotConsumption) []fundLot {
+	taken := make(map[int64]float64, len(consumed))
+	for _, item := range consumed {
+		taken[item.Lot.TransactionID] += item.Shares
+	}
+
+	remaining := lots[:0]
+	for _, lot := range lots {
+		if shares, ok := taken[lot.TransactionID]; ok {
+			lot.Cost -= lot.Cost * shares / lot.Shares
+			lot.Shares -= shares
+		}
+		if lot.Shares >= shareEpsilon {
+			remaining = append(remaining, lot)
+		}
+	}
+	return remaining
+}
+
+// holdingDays 计算批次自份额确认日至 on 的持有天数
+func holdingDays(lot fundLot, on time.Time) int {
+	from := dateOnly(lot.ConfirmDate)
+	to := dateOnly(on)
+	if to.Before(from) {
+		return 0
+	}
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// dateOnly 取日期部分，消除时区差异以便按天相减
+func dateOnly(t time.Time) time.Time {
+	if t.Location() != time.UTC {
+		t = t.In(calendar.Location)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ParseLotIDs 解析以逗号分隔的批次 ID
+func ParseLotIDs(value string) ([]int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var ids []int64
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lot id %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// formatLotIDs 将批次 ID 格式化为逗号分隔的字符串
+func formatLotIDs(ids []int64) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+// testLots 三个批次：#1 100 份、#2 50 份、#3 200 份
+func testLots() []fundLot {
+	return []fundLot{
+		{TransactionID: 1, TradeDate: day(2024, 1, 2), Shares: 100, Cost: 100},
+		{TransactionID: 2, TradeDate: day(2024, 2, 1), Shares: 50, Cost: 60},
+		{TransactionID: 3, TradeDate: day(2024, 3, 1), Shares: 200, Cost: 220},
+	}
+}
+
+// consumed 以批次 ID 与扣减份额表示的扣减结果
+type consumed struct {
+	id     int64
+	shares float64
+}
+
+func checkConsumption(t *testing.T, name string, got []lotConsumption, want []consumed) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: consumed %d lots %+v, want %d", name, len(got), got, len(want))
+		return
+	}
+	for i := range got {
+		if got[i].Lot.TransactionID != want[i].id || math.Abs(got[i].Shares-want[i].shares) > 1e-9 {
+			t.Errorf("%s: consumption %d = lot %d %.2f shares, want lot %d %.2f shares", name, i,
+				got[i].Lot.TransactionID, got[i].Shares, want[i].id, want[i].shares)
+		}
+	}
+}
+
+func TestConsumeFIFO(t *testing.T) {
+	tests := []struct {
+		name   string
+		shares float64
+		want   []consumed
+	}{
+		{"part of the oldest lot", 30, []consumed{{1, 30}}},
+		{"exactly the oldest lot", 100, []consumed{{1, 100}}},
+		{"spans lots", 170, []consumed{{1, 100}, {2, 50}, {3, 20}}},
+		{"whole position", 350, []consumed{{1, 100}, {2, 50}, {3, 200}}},
+		{"more than held stops at the last lot", 400, []consumed{{1, 100}, {2, 50}, {3, 200}}},
+		{"nothing", 0, nil},
+	}
+	for _, tt := range tests {
+		checkConsumption(t, tt.name, consumeFIFO(testLots(), tt.shares), tt.want)
+	}
+}
+
+func TestSelectLots(t *testing.T) {
+	tests := []struct {
+		name    string
+		shares  float64
+		lotIDs  []int64
+		want    []consumed
+		wantErr bool
+	}{
+		{name: "no lots selected falls back to FIFO", shares: 120, want: []consumed{{1, 100}, {2, 20}}},
+		{name: "single lot", shares: 150, lotIDs: []int64{3}, want: []consumed{{3, 150}}},
+		{name: "in the given order", shares: 220, lotIDs: []int64{3, 1}, want: []consumed{{3, 200}, {1, 20}}},
+		{name: "extra lots are not touched", shares: 50, lotIDs: []int64{2, 1}, want: []consumed{{2, 50}}},
+		{name: "lot not held", shares: 10, lotIDs: []int64{4}, wantErr: true},
+		{name: "selected lots hold too few shares", shares: 200, lotIDs: []int64{1, 2}, wantErr: true},
+		{name: "duplicate lot", shares: 150, lotIDs: []int64{1, 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := selectLots(testLots(), tt.shares, tt.lotIDs)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidTransaction) {
+				t.Errorf("%s: err = %v, want ErrInvalidTransaction", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		checkConsumption(t, tt.name, got, tt.want)
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"fundnet/backend/internal/models"
+)
+
+// metadataRefreshInterval 基金基础资料的刷新周期
+const metadataRefreshInterval = 7 * 24 * time.Hour
+
+// shareClassPattern 基金简称末尾的份额类别，如“混合A”“债券C类”
+var shareClassPattern = regexp.MustCompile(`([A-Z])类?$`)
+
+// fundCategory 将数据源的基金类型（如“混合型-偏股”“指数型-股票”“QDII-普通股票”）归类
+func fundCategory(fundType string) string {
+	switch {
+	case fundType == "":
+		return ""
+	case strings.HasPrefix(fundType, "QDII"):
+		return models.FundCategoryQDII
+	case strings.Contains(fundType, moneyFundType):
+		return models.FundCategoryMoney
+	case strings.HasPrefix(fundType, "指数"):
+		return models.FundCategoryIndex
+	case strings.HasPrefix(fundType, "股票"):
+		return models.FundCategoryEquity
+	case strings.HasPrefix(fundType, "混合"):
+		return models.FundCategoryHybrid
+	case strings.HasPrefix(fundType, "债券"):
+		return models.FundCategoryBond
+	case strings.HasPrefix(fundType, "FOF"):
+		return models.FundCategoryFOF
+	}
+	return models.FundCategoryOther
+}
+
+// shareClass 从基金简称中识别份额类别，没有后缀时返回空串
+func shareClass(name string) string {
+	match := shareClassPattern.FindStringSubmatch(strings.TrimSpace(name))
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// nullableDate 空日期写入 NULL
+func nullableDate(date string) interface{} {
+	if date == "" {
+		return nil
+	}
+	return date
+}
+
+// GetFundDetail 获取基金及其现任基金经理
+func (s *FundService) GetFundDetail(code string) (*models.Fund, error) {
+	fund, err := s.GetFundByCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, fund_code, name, start_date, created_at
+		FROM fund_managers
+		WHERE fund_code = ?
+		ORDER BY start_date, id
+	`, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	today := dateOnly(time.Now())
+	fund.Managers = make([]models.FundManager, 0)
+	for rows.Next() {
+		var manager models.FundManager
+		if err := rows.Scan(&manager.ID, &manager.FundCode, &manager.Name, &manager.StartDate, &manager.CreatedAt); err != nil {
+			return nil, err
+		}
+		if !manager.StartDate.IsZero() {
+			manager.TenureDays = int(today.Sub(dateOnly(manager.StartDate)).Hours() / 24)
+		}
+		fund.Managers = append(fund.Managers, manager)
+	}
+	return fund, rows.Err()
+}
+
+// RefreshFundMetadata 从数据源链抓取基金的类型、份额类别、基金公司、现任经理、规模、成立日、业绩基准与风险等级并入库
+// 基金名称为空时以数据源简称补全，并同步到名称为空的持仓；数据源未给出类型或经理信息时保留原有记录
+func (s *FundService) RefreshFundMetadata(code string) error {
+	metadata, err := s.sources.FetchMetadata(code)
+	if err != nil {
+		return err
+	}
+	fund, err := s.GetFundByCode(code)
+	if err != nil {
+		return err
+	}
+
+	name := fund.Name
+	if name == "" {
+		name = metadata.Name
+	}
+	fundType := fund.FundType
+	if metadata.Type != "" {
+		fundType = metadata.Type
+	}
+	class := shareClass(metadata.Name)
+	if metadata.Name == "" {
+		class = shareClass(name)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE funds SET name = ?, fund_type = ?, category = ?, share_class = ?, company = ?, fund_size = ?,
+		       size_date = ?, inception_date = ?, benchmark = ?, risk_level = ?, metadata_updated_at = ?, updated_at = ?
+		WHERE code = ?
+	`, name, fundType, fundCategory(fundType), class, metadata.Company, metadata.Size,
+		nullableDate(metadata.SizeDate), nullableDate(metadata.InceptionDate), metadata.Benchmark, metadata.RiskLevel,
+		now, now, code)
+	if err != nil {
+		return err
+	}
+
+	// 经理信息未能获取时保留上次的记录
+	if metadata.Managers != nil {
+		if _, err := tx.Exec(`DELETE FROM fund_managers WHERE fund_code = ?`, code); err != nil {
+			return err
+		}
+		for _, manager := range metadata.Managers {
+			_, err := tx.Exec(`
+				INSERT OR IGNORE INTO fund_managers (fund_code, name, start_date, created_at) VALUES (?, ?, ?, ?)
+			`, code, manager.Name, nullableDate(manager.StartDate), now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if name != "" {
+		_, err := tx.Exec(`
+			UPDATE positions SET fund_name = ? WHERE fund_code = ? AND (fund_name IS NULL OR fund_name = '')
+		`, name, code)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RefreshStaleFundMetadata 刷新所有订阅基金中超过一周未更新的基础资料
+func (s *FundService) RefreshStaleFundMetadata(now time.Time) {
+	rows, err := s.db.Query(`
+		SELECT code FROM funds
+		WHERE subscribed = 1 AND (metadata_updated_at IS NULL OR metadata_updated_at < ?)
+	`, now.Add(-metadataRefreshInterval))
+	if err != nil {
+		log.Printf("Failed to load funds for metadata refresh: %v", err)
+		return
+	}
+	var codes []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			rows.Close()
+			log.Printf("Failed to load funds for metadata refresh: %v", err)
+			return
+		}
+		codes = append(codes, code)
+	}
+	rows.Close()
+
+	for _, code := range codes {
+		if err := s.RefreshFundMetadata(code); err != nil {
+			log.Printf("Failed to refresh metadata of %s: %v", code, err)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+	"fundnet/backend/internal/scrapers"
+)
+
+// moneyFundType 货币基金的基金类型关键字，如“货币型”“货币型-普通货币”
+const moneyFundType = "货币"
+
+// moneyFundNav 货币基金的单位净值固定为 1 元，收益按日结转为份额
+const moneyFundNav = 1.0
+
+// MoneyYieldSource 支持分页获取货币基金每万份收益的数据源
+type MoneyYieldSource interface {
+	FundDataSource
+	FetchMoneyYieldPage(code string, pageIndex, pageSize int) ([]scrapers.MoneyYield, int, error)
+}
+
+// isMoneyMarket 基金是否为货币市场基金
+func isMoneyMarket(fund *models.Fund) bool {
+	return strings.Contains(fund.FundType, moneyFundType)
+}
+
+// isMoneyFund 按基金代码判断是否为货币市场基金，未订阅的基金视为否
+func (s *NavService) isMoneyFund(code string) bool {
+	var fundType string
+	if err := s.db.QueryRow(`SELECT fund_type FROM funds WHERE code = ?`, code).Scan(&fundType); err != nil {
+		return false
+	}
+	return strings.Contains(fundType, moneyFundType)
+}
+
+// BackfillMoneyYields 回填货币基金自 from 起的每万份收益与七日年化收益率，from 为零值时回填全部历史，返回写入条数
+func (s *NavService) BackfillMoneyYields(code string, from time.Time) (int, error) {
+	var errs []error
+	for _, source := range s.sources.Sources() {
+		yieldSource, ok := source.(MoneyYieldSource)
+		if !ok {
+			continue
+		}
+
+		count, err := s.backfillYieldsFrom(yieldSource, code, from)
+		if err == nil {
+			return count, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+	}
+
+	if len(errs) == 0 {
+		return 0, errors.New("no data source supports money market yields")
+	}
+	return 0, errors.Join(errs...)
+}
+
+// backfillYieldsFrom 从指定数据源按日期倒序翻页，直到早于 from 或翻完
+func (s *NavService) backfillYieldsFrom(source MoneyYieldSource, code string, from time.Time) (int, error) {
+	fromDate := ""
+	if !from.IsZero() {
+		fromDate = from.Format(calendar.DateLayout)
+	}
+
+	count := 0
+	for page := 1; ; page++ {
+		yields, total, err := source.FetchMoneyYieldPage(code, page, navPageSize)
+		if err != nil {
+			return count, err
+		}
+
+		reachedFrom := false
+		batch := make([]scrapers.MoneyYield, 0, len(yields))
+		for _, yield := range yields {
+			if fromDate != "" && yield.YieldDate < fromDate {
+				reachedFrom = true
+				break
+			}
+			batch = append(batch, yield)
+		}
+
+		if err := s.SaveMoneyYields(batch); err != nil {
+			return count, err
+		}
+		count += len(batch)
+
+		if reachedFrom || len(yields) == 0 || page*navPageSize >= total {
+			return count, nil
+		}
+	}
+}
+
+// SaveMoneyYields 批量写入货币基金每日收益，同一日期的记录会被覆盖
+// 同时按单位净值 1 元写入历史净值，当日涨幅为每万份收益折算的日收益率，供申购确认与收益统计使用
+func (s *NavService) SaveMoneyYields(yields []scrapers.MoneyYield) error {
+	if len(yields) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, yield := range yields {
+		if yield.YieldDate == "" {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO money_yields (fund_code, yield_date, income_per_10k, yield_7d, source, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, yield.Code, yield.YieldDate, yield.IncomePer10K, yield.Yield7D, yield.Source, now)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT OR REPLACE INTO nav_history (fund_code, nav_date, nav, acc_nav, daily_growth, source, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, yield.Code, yield.YieldDate, moneyFundNav, moneyFundNav, round(yield.IncomePer10K/100, 4), yield.Source, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetMoneyYields 获取 [from, to] 区间内货币基金的每日收益，按日期升序；零值表示不限
+func (s *NavService) GetMoneyYields(code string, from, to time.Time) ([]models.MoneyYield, error) {
+	query := `
+		SELECT id, fund_code, yield_date, income_per_10k, yield_7d, source, created_at
+		FROM money_yields
+		WHERE fund_code = ?
+	`
+	args := []interface{}{code}
+	if !from.IsZero() {
+		query += " AND yield_date >= ?"
+		args = append(args, from.Format(calendar.DateLayout))
+	}
+	if !to.IsZero() {
+		query += " AND yield_date <= ?"
+		args = append(args, to.Format(calendar.DateLayout))
+	}
+	query += " ORDER BY yield_date"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	yields := make([]models.MoneyYield, 0)
+	for rows.Next() {
+		var yield models.MoneyYield
+		err := rows.Scan(&yield.ID, &yield.FundCode, &yield.YieldDate, &yield.IncomePer10K, &yield.Yield7D,
+			&yield.Source, &yield.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		yields = append(yields, yield)
+	}
+	return yields, rows.Err()
+}
+
+// latestMoneyYield 获取货币基金不晚于 date 的最近一日收益
+func latestMoneyYield(db *sql.DB, code string, date time.Time) (*models.MoneyYield, error) {
+	var yield models.MoneyYield
+	err := db.QueryRow(`
+		SELECT id, fund_code, yield_date, income_per_10k, yield_7d, source, created_at
+		FROM money_yields
+		WHERE fund_code = ? AND yield_date <= ?
+		ORDER BY yield_date DESC LIMIT 1
+	`, code, date.Format(calendar.DateLayout)).Scan(&yield.ID, &yield.FundCode, &yield.YieldDate,
+		&yield.IncomePer10K, &yield.Yield7D, &yield.Source, &yield.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &yield, nil
+}
+
+// UpdateFundType 手动设置基金类型，如“货币型”，用于数据源未能识别的基金
+// 改为货币基金时在后台重新回填每日收益，覆盖此前按普通基金写入的历史净值
+func (s *FundService) UpdateFundType(code, fundType string) (*models.Fund, error) {
+	fund, err := s.GetFundByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	fundType = strings.TrimSpace(fundType)
+	_, err = s.db.Exec(`UPDATE funds SET fund_type = ?, updated_at = ? WHERE code = ?`, fundType, time.Now(), code)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isMoneyMarket(fund) && strings.Contains(fundType, moneyFundType) {
+		go func() {
+			if _, err := s.navService.BackfillMoneyYields(code, time.Time{}); err != nil {
+				log.Printf("Failed to backfill money market yields for %s: %v", code, err)
+			}
+		}()
+	}
+	return s.GetFundByCode(code)
+}
+
+// refreshMoneyFund 抓取货币基金自最新净值日起的每日收益，单位净值固定为 1 元，当日涨幅为最新一日的收益率
+func (s *FundService) refreshMoneyFund(fund *models.Fund) error {
+	if _, err := s.navService.BackfillMoneyYields(fund.Code, fund.NavDate); err != nil {
+		return err
+	}
+	yield, err := latestMoneyYield(s.db, fund.Code, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.UpdateFundData(fund.Code, FundDataUpdate{
+		Nav:         moneyFundNav,
+		NavDate:     yield.YieldDate.Format(calendar.DateLayout),
+		DailyGrowth: round(yield.IncomePer10K/100, 4),
+		NavSource:   yield.Source,
+	})
+}
+
+// AccrueMoneyIncome 将截至 now 已公布的货币基金每日收益按 1 元净值结转为份额，记为收益交易，返回新增的交易笔数
+// 收益日期前一日（含）及之前确认的份额享有当日收益，即申购份额自确认日起计息；
+// 每个账户从最近一笔收益交易之后的日期继续结转，每万份收益为负或不足 0.01 元的日期不记账
+func (s *FundService) AccrueMoneyIncome(now time.Time) (int, error) {
+	rows, err := s.db.Query(`
+		SELECT p.account_id, p.fund_code FROM positions p
+		JOIN funds f ON f.code = p.fund_code
+		WHERE p.status != ? AND f.fund_type LIKE ?
+		ORDER BY p.account_id, p.fund_code
+	`, models.PositionClosed, "%"+moneyFundType+"%")
+	if err != nil {
+		return 0, err
+	}
+	type holding struct {
+		accountID int64
+		fundCode  string
+	}
+	var holdings []holding
+	for rows.Next() {
+		var h holding
+		if err := rows.Scan(&h.accountID, &h.fundCode); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		holdings = append(holdings, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, h := range holdings {
+		n, err := s.accrueMoneyIncome(h.accountID, h.fundCode, now)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// accrueMoneyIncome 为单个账户结转一只货币基金尚未记账的每日收益
+func (s *FundService) accrueMoneyIncome(accountID int64, fundCode string, now time.Time) (int, error) {
+	var lastIncome, firstTrade sql.NullString
+	err := s.db.QueryRow(`
+		SELECT MAX(CASE WHEN type = ? THEN trade_date END), MIN(trade_date) FROM transactions
+		WHERE account_id = ? AND fund_code = ? AND status = ?
+	`, models.TransactionIncome, accountID, fundCode, models.TransactionConfirmed).Scan(&lastIncome, &firstTrade)
+	if err != nil {
+		return 0, err
+	}
+	since := lastIncome
+	if !since.Valid {
+		since = firstTrade
+	}
+	if !since.Valid {
+		return 0, nil
+	}
+
+	from, err := time.Parse(calendar.DateLayout, since.String[:len(calendar.DateLayout)])
+	if err != nil {
+		return 0, err
+	}
+	yields, err := s.navService.GetMoneyYields(fundCode, from.AddDate(0, 0, 1), now)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, yield := range yields {
+		if yield.IncomePer10K <= 0 {
+			continue
+		}
+		lots, err := s.openLots(accountID, fundCode, yield.YieldDate.AddDate(0, 0, -1))
+		if err != nil {
+			return count, err
+		}
+		var held float64
+		for _, lot := range lots {
+			held += lot.Shares
+		}
+		income := round(held*yield.IncomePer10K/10000, 2)
+		if income < 0.01 {
+			continue
+		}
+
+		t := &models.Transaction{
+			AccountID: accountID,
+			FundCode:  fundCode,
+			Type:      models.TransactionIncome,
+			Status:    models.TransactionConfirmed,
+			OrderTime: yield.YieldDate,
+			TradeDate: yield.YieldDate,
+			Shares:    income,
+			Price:     moneyFundNav,
+			Amount:    income,
+			Note:      fmt.Sprintf("每万份收益 %.4f 元，七日年化 %.3f%%", yield.IncomePer10K, yield.Yield7D),
+		}
+		input := TransactionInput{AccountID: accountID, FundCode: fundCode}
+		if _, err := s.insertTransaction(input, t); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+)
+
+// BuyOrderInput 按金额申购的参数
+// FeeRate 为申购费率（如 0.0015 表示 0.15%），份额确认时按 金额 / (1 + 费率) / 净值 计算；
+// 为空时取费率表的申购费率
+type BuyOrderInput struct {
+	AccountID int64
+	FundCode  string
+	FundName  string
+	Amount    float64
+	FeeRate   *float64
+	OrderTime time.Time
+	Note      string
+	Sector    string
+}
+
+// PlaceBuyOrder 记录一笔待确认的按金额申购
+// 交易日 15:00 前下单按当日净值确认，否则顺延至下一个交易日（QDII 基金还跳过境外市场休市日）；对应净值已入库时立即确认
+func (s *FundService) PlaceBuyOrder(input BuyOrderInput) (*models.Position, error) {
+	_, position, err := s.placeBuyOrder(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.ConfirmPendingOrders(); err != nil {
+		log.Printf("Failed to confirm pending orders: %v", err)
+	}
+	return s.GetPositionByID(position.ID)
+}
+
+// placeBuyOrder 校验并写入一笔待确认申购，返回该笔交易与所属持仓
+func (s *FundService) placeBuyOrder(input BuyOrderInput) (*models.Transaction, *models.Position, error) {
+	if input.FundCode == "" {
+		return nil, nil, fmt.Errorf("%w: fund_code is required", ErrInvalidTransaction)
+	}
+	if input.Amount <= 0 {
+		return nil, nil, fmt.Errorf("%w: amount is required", ErrInvalidTransaction)
+	}
+	accountID, err := s.resolveAccount(input.AccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	input.AccountID = accountID
+
+	var feeRate float64
+	if input.FeeRate != nil {
+		feeRate = *input.FeeRate
+	} else {
+		schedule, err := s.feeSchedule(input.FundCode)
+		if err != nil {
+			return nil, nil, err
+		}
+		feeRate = schedule.PurchaseRate
+	}
+	if feeRate < 0 || feeRate >= 1 {
+		return nil, nil, fmt.Errorf("%w: fee_rate must be in [0, 1)", ErrInvalidTransaction)
+	}
+
+	orderTime := input.OrderTime
+	if orderTime.IsZero() {
+		orderTime = time.Now()
+	}
+	tradeDate := calendar.Default().NavDate(orderTime)
+	if fund, err := s.GetFundByCode(input.FundCode); err == nil {
+		tradeDate = fundOrderDate(fund, orderTime)
+	}
+
+	t := &models.Transaction{
+		AccountID: input.AccountID,
+		FundCode:  input.FundCode,
+		Type:      models.TransactionBuy,
+		Status:    models.TransactionPending,
+		OrderTime: orderTime,
+		TradeDate: tradeDate,
+		Amount:    round(input.Amount, 2),
+		FeeRate:   feeRate,
+		Note:      input.Note,
+	}
+	position, err := s.insertTransaction(TransactionInput{
+		AccountID: input.AccountID,
+		FundCode:  input.FundCode,
+		FundName:  input.FundName,
+		Sector:    input.Sector,
+	}, t)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t, position, nil
+}
+
+// pendingOrder 待确认申购及其适用净值
+type pendingOrder struct {
+	id        int64
+	accountID int64
+	fundCode  string
+	tradeDate time.Time
+	amount    float64
+	feeRate   float64
+	nav       float64
+}
+
+// ConfirmPendingOrders 用已入库的官方净值确认待确认申购的份额，返回确认笔数
+// 净值应已公布（QDII 基金按其净值滞后）但本地缺少净值时，先回填该基金的历史净值
+func (s *FundService) ConfirmPendingOrders() (int, error) {
+	orders, err := s.getPendingOrders()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	backfilled := make(map[string]bool)
+	for _, order := range orders {
+		if order.nav > 0 || s.navService == nil || backfilled[order.fundCode] {
+			continue
+		}
+		fund := &models.Fund{Code: order.fundCode}
+		if subscribed, err := s.GetFundByCode(order.fundCode); err == nil {
+			fund = subscribed
+		}
+		published := publishedNavDate(fund, now).Format(calendar.DateLayout)
+		if order.tradeDate.Format(calendar.DateLayout) > published {
+			continue
+		}
+		backfilled[order.fundCode] = true
+		if _, err := s.navService.Backfill(order.fundCode, order.tradeDate); err != nil {
+			log.Printf("Failed to backfill nav for pending order of %s: %v", order.fundCode, err)
+		}
+	}
+	if len(backfilled) > 0 {
+		if orders, err = s.getPendingOrders(); err != nil {
+			return 0, err
+		}
+	}
+
+	confirmed := 0
+	for _, order := range orders {
+		if order.nav <= 0 {
+			continue
+		}
+		if err := s.confirmOrder(order); err != nil {
+			log.Printf("Failed to confirm order %d: %v", order.id, err)
+			continue
+		}
+		confirmed++
+	}
+	return confirmed, nil
+}
+
+// getPendingOrders 读取全部待确认申购，nav 为对应净值日的官方净值（未公布时为 0）
+func (s *FundService) getPendingOrders() ([]pendingOrder, error) {
+	rows, err := s.db.Query(`
+		SELECT t.id, t.account_id, t.fund_code, t.trade_date, t.amount, t.fee_rate, COALESCE(h.nav, 0)
+		FROM transactions t
+		LEFT JOIN nav_history h ON h.fund_code = t.fund_code AND h.nav_date = t.trade_date
+		WHERE t.status = ?
+		ORDER BY t.trade_date, t.id
+	`, models.TransactionPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []pendingOrder
+	for rows.Next() {
+		var order pendingOrder
+		err := rows.Scan(&order.id, &order.accountID, &order.fundCode, &order.tradeDate,
+			&order.amount, &order.feeRate, &order.nav)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+// confirmOrder 按净值确认份额：净申购金额 = 金额 / (1 + 费率)，手续费 = 金额 - 净申购金额
+func (s *FundService) confirmOrder(order pendingOrder) error {
+	netAmount := order.amount / (1 + order.feeRate)
+	fee := round(order.amount-netAmount, 2)
+	shares := round((order.amount-fee)/order.nav, 2)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE transactions SET status = ?, shares = ?, price = ?, fee = ?, updated_at = ?
+		WHERE id = ? AND status = ?
+	`, models.TransactionConfirmed, shares, order.nav, fee, time.Now(), order.id, models.TransactionPending)
+	if err != nil {
+		return err
+	}
+	if _, err := rebuildPosition(tx, order.accountID, order.fundCode); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,447 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+)
+
+// planOrderHour 定投扣款按扣款日该时刻（北京时间）下单，确保按当日净值确认
+const planOrderHour = 10
+
+// PlanSummary 定投计划及其累计结果
+// Invested 与 Fees 只统计已确认的申购，CurrentValue 按持仓估值净值（或最新官方净值）计算
+type PlanSummary struct {
+	models.InvestmentPlan
+	Executions    int        `json:"executions"`
+	PendingCount  int        `json:"pending_count"`
+	PendingAmount float64    `json:"pending_amount"`
+	Invested      float64    `json:"invested"`
+	Fees          float64    `json:"fees"`
+	Shares        float64    `json:"shares"`
+	AverageCost   float64    `json:"average_cost"`
+	Nav           float64    `json:"nav"`
+	CurrentValue  float64    `json:"current_value"`
+	ProfitLoss    float64    `json:"profit_loss"`
+	ProfitRate    float64    `json:"profit_rate"`
+	NextDate      *time.Time `json:"next_date"`
+}
+
+// UpcomingExecution 即将执行的一次定投扣款
+type UpcomingExecution struct {
+	PlanID    int64     `json:"plan_id"`
+	AccountID int64     `json:"account_id"`
+	FundCode  string    `json:"fund_code"`
+	FundName  string    `json:"fund_name"`
+	Date      time.Time `json:"date"`
+	Amount    float64   `json:"amount"`
+}
+
+const planColumns = `id, account_id, fund_code, fund_name, amount, frequency, day, start_date, end_date, status, note,
+	last_run_date, created_at, updated_at`
+
+func scanPlan(row rowScanner) (*models.InvestmentPlan, error) {
+	var plan models.InvestmentPlan
+	var endDate, lastRunDate sql.NullTime
+	err := row.Scan(&plan.ID, &plan.AccountID, &plan.FundCode, &plan.FundName, &plan.Amount, &plan.Frequency,
+		&plan.Day, &plan.StartDate, &endDate, &plan.Status, &plan.Note, &lastRunDate, &plan.CreatedAt, &plan.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if endDate.Valid {
+		plan.EndDate = &endDate.Time
+	}
+	if lastRunDate.Valid {
+		plan.LastRunDate = &lastRunDate.Time
+	}
+	return &plan, nil
+}
+
+// GetPlans 获取全部定投计划
+func (s *FundService) GetPlans() ([]models.InvestmentPlan, error) {
+	rows, err := s.db.Query(`SELECT ` + planColumns + ` FROM investment_plans ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := make([]models.InvestmentPlan, 0)
+	for rows.Next() {
+		plan, err := scanPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, *plan)
+	}
+	return plans, rows.Err()
+}
+
+// GetPlanByID 根据ID获取定投计划
+func (s *FundService) GetPlanByID(id int64) (*models.InvestmentPlan, error) {
+	return scanPlan(s.db.QueryRow(`SELECT `+planColumns+` FROM investment_plans WHERE id = ?`, id))
+}
+
+// CreatePlan 创建定投计划，未指定账户时归入默认账户
+func (s *FundService) CreatePlan(plan *models.InvestmentPlan) (*models.InvestmentPlan, error) {
+	if err := s.validatePlan(plan); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result, err := s.db.Exec(`
+		INSERT INTO investment_plans
+			(account_id, fund_code, fund_name, amount, frequency, day, start_date, end_date, status, note,
+			 created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, plan.AccountID, plan.FundCode, plan.FundName, plan.Amount, plan.Frequency, plan.Day,
+		plan.StartDate.Format(calendar.DateLayout), formatPlanDate(plan.EndDate), plan.Status, plan.Note, now, now)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	return s.GetPlanByID(id)
+}
+
+// UpdatePlan 更新定投计划，修改自当日起生效，此前错过的扣款日不再补扣
+func (s *FundService) UpdatePlan(plan *models.InvestmentPlan) (*models.InvestmentPlan, error) {
+	if err := s.validatePlan(plan); err != nil {
+		return nil, err
+	}
+	result, err := s.db.Exec(`
+		UPDATE investment_plans SET account_id = ?, fund_code = ?, fund_name = ?, amount = ?, frequency = ?, day = ?,
+			start_date = ?, end_date = ?, status = ?, note = ?, updated_at = ?
+		WHERE id = ?
+	`, plan.AccountID, plan.FundCode, plan.FundName, plan.Amount, plan.Frequency, plan.Day,
+		plan.StartDate.Format(calendar.DateLayout), formatPlanDate(plan.EndDate), plan.Status, plan.Note,
+		time.Now(), plan.ID)
+	if err != nil {
+		return nil, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return s.GetPlanByID(plan.ID)
+}
+
+// DeletePlan 删除定投计划，已生成的申购流水保留
+func (s *FundService) DeletePlan(id int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM investment_plans WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`DELETE FROM plan_executions WHERE plan_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// validatePlan 校验定投计划并补全默认值
+func (s *FundService) validatePlan(plan *models.InvestmentPlan) error {
+	if plan.FundCode == "" {
+		return fmt.Errorf("%w: fund_code is required", ErrInvalidInput)
+	}
+	if plan.Amount <= 0 {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidInput)
+	}
+	if err := validateSchedule(plan.Frequency, plan.Day); err != nil {
+		return err
+	}
+	switch plan.Status {
+	case "":
+		plan.Status = models.PlanActive
+	case models.PlanActive, models.PlanPaused:
+	default:
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidInput, plan.Status)
+	}
+
+	if plan.StartDate.IsZero() {
+		plan.StartDate = time.Now()
+	}
+	plan.StartDate = dateOnly(plan.StartDate)
+	if plan.EndDate != nil {
+		end := dateOnly(*plan.EndDate)
+		if end.Before(plan.StartDate) {
+			return fmt.Errorf("%w: end_date is before start_date", ErrInvalidInput)
+		}
+		plan.EndDate = &end
+	}
+
+	accountID, err := s.resolveAccount(plan.AccountID)
+	if err != nil {
+		return err
+	}
+	plan.AccountID = accountID
+
+	if plan.FundName == "" {
+		if fund, err := s.GetFundByCode(plan.FundCode); err == nil {
+			plan.FundName = fund.Name
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSchedule 校验定投频率与扣款日
+func validateSchedule(frequency string, day int) error {
+	switch frequency {
+	case models.PlanWeekly, models.PlanBiweekly:
+		if day < 1 || day > 7 {
+			return fmt.Errorf("%w: day must be a weekday in [1, 7]", ErrInvalidInput)
+		}
+	case models.PlanMonthly:
+		if day < 1 || day > 28 {
+			return fmt.Errorf("%w: day must be a day of month in [1, 28]", ErrInvalidInput)
+		}
+	default:
+		return fmt.Errorf("%w: unknown frequency %q", ErrInvalidInput, frequency)
+	}
+	return nil
+}
+
+// formatPlanDate 格式化可为空的日期
+func formatPlanDate(date *time.Time) interface{} {
+	if date == nil {
+		return nil
+	}
+	return date.Format(calendar.DateLayout)
+}
+
+// planDates 返回定投计划在 [from, to] 区间内的扣款日
+// 名义扣款日不是交易日时顺延至下一个交易日，名义扣款日晚于结束日期的不再扣款
+func planDates(plan *models.InvestmentPlan, from, to time.Time) []time.Time {
+	cal := calendar.Default()
+	start := dateOnly(plan.StartDate)
+	var dates []time.Time
+	add := func(nominal time.Time) {
+		if plan.EndDate != nil && nominal.After(dateOnly(*plan.EndDate)) {
+			return
+		}
+		date := nominal
+		if !cal.IsTradingDay(date) {
+			date = dateOnly(cal.NextTradingDay(date))
+		}
+		if !date.Before(from) && !date.After(to) {
+			dates = append(dates, date)
+		}
+	}
+
+	switch plan.Frequency {
+	case models.PlanWeekly, models.PlanBiweekly:
+		step := 7
+		if plan.Frequency == models.PlanBiweekly {
+			step = 14
+		}
+		// 首个扣款日为开始日期当天或之后的第一个指定星期几，双周定投以此为基准隔周扣款
+		offset := (plan.Day%7 - int(start.Weekday()) + 7) % 7
+		for nominal := start.AddDate(0, 0, offset); !nominal.After(to); nominal = nominal.AddDate(0, 0, step) {
+			add(nominal)
+		}
+	case models.PlanMonthly:
+		month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+		for ; !month.After(to); month = month.AddDate(0, 1, 0) {
+			nominal := month.AddDate(0, 0, plan.Day-1)
+			if !nominal.Before(start) {
+				add(nominal)
+			}
+		}
+	}
+	return dates
+}
+
+// RunInvestmentPlans 为生效中的定投计划生成截至 now 当日的待确认申购，返回生成笔数
+// 只补扣计划创建或最近一次修改之后的扣款日，同一扣款日不会重复生成
+func (s *FundService) RunInvestmentPlans(now time.Time) (int, error) {
+	plans, err := s.GetPlans()
+	if err != nil {
+		return 0, err
+	}
+
+	cal := calendar.Default()
+	today := dateOnly(now)
+	created := 0
+	for i := range plans {
+		plan := &plans[i]
+		if plan.Status != models.PlanActive {
+			continue
+		}
+		from := dateOnly(plan.UpdatedAt)
+		if start := dateOnly(plan.StartDate); start.After(from) {
+			from = start
+		}
+		for _, date := range planDates(plan, from, today) {
+			result, err := s.db.Exec(`
+				INSERT OR IGNORE INTO plan_executions (plan_id, execute_date, created_at)
+				VALUES (?, ?, ?)
+			`, plan.ID, date.Format(calendar.DateLayout), time.Now())
+			if err != nil {
+				return created, err
+			}
+			if affected, _ := result.RowsAffected(); affected == 0 {
+				continue
+			}
+
+			orderTime := time.Date(date.Year(), date.Month(), date.Day(), planOrderHour, 0, 0, 0, cal.Location())
+			t, _, err := s.placeBuyOrder(BuyOrderInput{
+				AccountID: plan.AccountID,
+				FundCode:  plan.FundCode,
+				FundName:  plan.FundName,
+				Amount:    plan.Amount,
+				OrderTime: orderTime,
+				Note:      fmt.Sprintf("定投计划 #%d", plan.ID),
+			})
+			if err != nil {
+				log.Printf("Failed to execute investment plan %d on %s: %v", plan.ID, date.Format(calendar.DateLayout), err)
+				if _, err := s.db.Exec(`DELETE FROM plan_executions WHERE plan_id = ? AND execute_date = ?`,
+					plan.ID, date.Format(calendar.DateLayout)); err != nil {
+					return created, err
+				}
+				continue
+			}
+
+			_, err = s.db.Exec(`
+				UPDATE plan_executions SET transaction_id = ? WHERE plan_id = ? AND execute_date = ?
+			`, t.ID, plan.ID, date.Format(calendar.DateLayout))
+			if err != nil {
+				return created, err
+			}
+			_, err = s.db.Exec(`UPDATE investment_plans SET last_run_date = ? WHERE id = ?`,
+				date.Format(calendar.DateLayout), plan.ID)
+			if err != nil {
+				return created, err
+			}
+			created++
+		}
+	}
+
+	if created > 0 {
+		if _, err := s.ConfirmPendingOrders(); err != nil {
+			log.Printf("Failed to confirm pending orders: %v", err)
+		}
+	}
+	return created, nil
+}
+
+// GetUpcomingExecutions 列出 now 之后 days 天内（不含当日已生成的扣款）生效中定投计划的扣款日，按日期排序
+func (s *FundService) GetUpcomingExecutions(now time.Time, days int) ([]UpcomingExecution, error) {
+	if days <= 0 {
+		return nil, fmt.Errorf("%w: days must be positive", ErrInvalidInput)
+	}
+	plans, err := s.GetPlans()
+	if err != nil {
+		return nil, err
+	}
+
+	today := dateOnly(now)
+	to := today.AddDate(0, 0, days)
+	upcoming := make([]UpcomingExecution, 0)
+	for i := range plans {
+		plan := &plans[i]
+		if plan.Status != models.PlanActive {
+			continue
+		}
+		from := today
+		if plan.LastRunDate != nil && !dateOnly(*plan.LastRunDate).Before(today) {
+			from = today.AddDate(0, 0, 1)
+		}
+		for _, date := range planDates(plan, from, to) {
+			upcoming = append(upcoming, UpcomingExecution{
+				PlanID:    plan.ID,
+				AccountID: plan.AccountID,
+				FundCode:  plan.FundCode,
+				FundName:  plan.FundName,
+				Date:      date,
+				Amount:    plan.Amount,
+			})
+		}
+	}
+	sort.SliceStable(upcoming, func(i, j int) bool { return upcoming[i].Date.Before(upcoming[j].Date) })
+	return upcoming, nil
+}
+
+// GetPlanSummaries 获取全部定投计划及其累计扣款、份额、市值与收益
+func (s *FundService) GetPlanSummaries(now time.Time) ([]PlanSummary, error) {
+	plans, err := s.GetPlans()
+	if err != nil {
+		return nil, err
+	}
+
+	today := dateOnly(now)
+	summaries := make([]PlanSummary, 0, len(plans))
+	for _, plan := range plans {
+		summary := PlanSummary{InvestmentPlan: plan}
+		err := s.db.QueryRow(`
+			SELECT
+				COUNT(t.id),
+				COALESCE(SUM(CASE WHEN t.status = ? THEN 1 ELSE 0 END), 0),
+				COALESCE(SUM(CASE WHEN t.status = ? THEN t.amount ELSE 0 END), 0),
+				COALESCE(SUM(CASE WHEN t.status = ? THEN t.amount ELSE 0 END), 0),
+				COALESCE(SUM(CASE WHEN t.status = ? THEN t.fee ELSE 0 END), 0),
+				COALESCE(SUM(CASE WHEN t.status = ? THEN t.shares ELSE 0 END), 0)
+			FROM plan_executions e
+			JOIN transactions t ON t.id = e.transaction_id
+			WHERE e.plan_id = ?
+		`, models.TransactionPending, models.TransactionPending, models.TransactionConfirmed,
+			models.TransactionConfirmed, models.TransactionConfirmed, plan.ID).Scan(
+			&summary.Executions, &summary.PendingCount, &summary.PendingAmount,
+			&summary.Invested, &summary.Fees, &summary.Shares)
+		if err != nil {
+			return nil, err
+		}
+
+		summary.Nav = s.planNav(&plan)
+		summary.CurrentValue = round(summary.Shares*summary.Nav, 2)
+		summary.ProfitLoss = round(summary.CurrentValue-summary.Invested, 2)
+		if summary.Shares > 0 {
+			summary.AverageCost = round(summary.Invested/summary.Shares, 4)
+		}
+		if summary.Invested > 0 {
+			summary.ProfitRate = round(summary.ProfitLoss/summary.Invested*100, 2)
+		}
+		summary.Invested = round(summary.Invested, 2)
+		summary.PendingAmount = round(summary.PendingAmount, 2)
+		summary.Fees = round(summary.Fees, 2)
+		summary.Shares = round(summary.Shares, 2)
+
+		if plan.Status == models.PlanActive {
+			from := today
+			if plan.LastRunDate != nil && !dateOnly(*plan.LastRunDate).Before(today) {
+				from = today.AddDate(0, 0, 1)
+			}
+			// 双周与按月定投在一年内必有扣款日，一年内没有扣款日说明计划已结束
+			if dates := planDates(&plan, from, today.AddDate(1, 0, 0)); len(dates) > 0 {
+				summary.NextDate = &dates[0]
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// planNav 定投基金的估值净值：优先取所在账户持仓的估值净值，否则取基金最新官方净值
+func (s *FundService) planNav(plan *models.InvestmentPlan) float64 {
+	position, err := scanPosition(s.db.QueryRow(`
+		SELECT `+positionColumns+` FROM positions WHERE account_id = ? AND fund_code = ?
+	`, plan.AccountID, plan.FundCode))
+	if err == nil {
+		return s.positionNav(position)
+	}
+	if fund, err := s.GetFundByCode(plan.FundCode); err == nil {
+		return fund.Nav
+	}
+	return 0
+}
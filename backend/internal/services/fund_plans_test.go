@@ -0,0 +1,85 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+)
+
+func TestPlanDates(t *testing.T) {
+	end := day(2024, 4, 15)
+	tests := []struct {
+		name     string
+		plan     models.InvestmentPlan
+		from, to time.Time
+		want     []time.Time
+	}{
+		{
+			name: "weekly, holiday Monday moves to the next trading day",
+			plan: models.InvestmentPlan{Frequency: models.PlanWeekly, Day: 1, StartDate: day(2024, 9, 20)},
+			from: day(2024, 9, 20),
+			to:   day(2024, 10, 14),
+			want: []time.Time{day(2024, 9, 23), day(2024, 9, 30), day(2024, 10, 8), day(2024, 10, 14)},
+		},
+		{
+			name: "biweekly Sunday always moves to Monday",
+			plan: models.InvestmentPlan{Frequency: models.PlanBiweekly, Day: 7, StartDate: day(2024, 3, 1)},
+			from: day(2024, 3, 1),
+			to:   day(2024, 3, 31),
+			want: []time.Time{day(2024, 3, 4), day(2024, 3, 18)},
+		},
+		{
+			name: "monthly, holidays and weekends",
+			plan: models.InvestmentPlan{Frequency: models.PlanMonthly, Day: 1, StartDate: day(2024, 1, 1)},
+			from: day(2024, 1, 1),
+			to:   day(2024, 6, 30),
+			want: []time.Time{
+				day(2024, 1, 2), day(2024, 2, 1), day(2024, 3, 1), day(2024, 4, 1), day(2024, 5, 6), day(2024, 6, 3),
+			},
+		},
+		{
+			name: "monthly, start date after the debit day skips that month",
+			plan: models.InvestmentPlan{Frequency: models.PlanMonthly, Day: 10, StartDate: day(2024, 1, 15)},
+			from: day(2024, 1, 1),
+			to:   day(2024, 3, 31),
+			want: []time.Time{day(2024, 2, 19), day(2024, 3, 11)},
+		},
+		{
+			name: "window only returns dates inside [from, to]",
+			plan: models.InvestmentPlan{Frequency: models.PlanMonthly, Day: 15, StartDate: day(2024, 1, 1)},
+			from: day(2024, 2, 1),
+			to:   day(2024, 4, 14),
+			want: []time.Time{day(2024, 2, 19), day(2024, 3, 15)},
+		},
+		{
+			name: "no debits after the end date",
+			plan: models.InvestmentPlan{Frequency: models.PlanMonthly, Day: 15, StartDate: day(2024, 1, 1), EndDate: &end},
+			from: day(2024, 1, 1),
+			to:   day(2024, 6, 30),
+			want: []time.Time{day(2024, 1, 15), day(2024, 2, 19), day(2024, 3, 15), day(2024, 4, 15)},
+		},
+	}
+	for _, tt := range tests {
+		got := planDates(&tt.plan, tt.from, tt.to)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, formatDates(got), formatDates(tt.want))
+			continue
+		}
+		for i := range got {
+			if !got[i].Equal(tt.want[i]) {
+				t.Errorf("%s: got %v, want %v", tt.name, formatDates(got), formatDates(tt.want))
+				break
+			}
+		}
+	}
+}
+
+func formatDates(dates []time.Time) []string {
+	formatted := make([]string, len(dates))
+	for i, date := range dates {
+		formatted[i] = date.Format(calendar.DateLayout)
+	}
+	return formatted
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+)
+
+// 场内价格列表支持的排序字段及对应的 SQL 表达式
+var premiumSortColumns = map[string]string{
+	"premium_rate":     "premium_rate",
+	"abs_premium_rate": "ABS(premium_rate)",
+	"market_price":     "market_price",
+	"code":             "code",
+}
+
+// exchangeSymbolPattern 场内交易代码，如 sh510300、sz161725
+var exchangeSymbolPattern = regexp.MustCompile(`^(sh|sz)\d{6}$`)
+
+// exchangeSymbol 按基金代码推断场内交易代码，不在交易所上市的返回空串
+// 上交所：50、51（519 为场外基金）、56、58 开头的 ETF 与 LOF；深交所：15、16 开头的 ETF 与 LOF
+func exchangeSymbol(code string) string {
+	if len(code) != 6 {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(code, "519"):
+		return ""
+	case strings.HasPrefix(code, "50"), strings.HasPrefix(code, "51"),
+		strings.HasPrefix(code, "56"), strings.HasPrefix(code, "58"):
+		return "sh" + code
+	case strings.HasPrefix(code, "15"), strings.HasPrefix(code, "16"):
+		return "sz" + code
+	}
+	return ""
+}
+
+// premiumBase 计算溢价率所用的参考净值：当日估值尚未被官方净值追上时使用估值，否则使用最新官方净值
+func premiumBase(fund *models.Fund) float64 {
+	if fund.EstimateNav > 0 && !fund.EstimateTime.IsZero() &&
+		(fund.NavDate.IsZero() || calendar.Default().FormatDate(fund.EstimateTime) > fund.NavDate.Format(calendar.DateLayout)) {
+		return fund.EstimateNav
+	}
+	return fund.Nav
+}
+
+// RefreshMarketPrices 批量抓取订阅基金中场内上市的 LOF、ETF 的二级市场价格，
+// 并按参考净值计算溢价率（正为溢价、负为折价，%），返回更新的基金数
+func (s *EstimateService) RefreshMarketPrices() (int, error) {
+	funds, err := s.GetAllSubscribedFunds()
+	if err != nil {
+		return 0, err
+	}
+
+	var listed []models.Fund
+	var symbols []string
+	for _, fund := range funds {
+		if fund.ExchangeSymbol == "" {
+			continue
+		}
+		listed = append(listed, fund)
+		symbols = append(symbols, fund.ExchangeSymbol)
+	}
+	if len(symbols) == 0 {
+		return 0, nil
+	}
+
+	quotes, err := s.quotes.FetchQuotes(symbols)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	count := 0
+	for i := range listed {
+		fund := &listed[i]
+		quote, ok := quotes[fund.ExchangeSymbol]
+		if !ok || quote.Price <= 0 {
+			// 停牌或代码未上市时没有成交价
+			continue
+		}
+
+		premium := float64(0)
+		if base := premiumBase(fund); base > 0 {
+			premium = round((quote.Price-base)/base*100, 2)
+		}
+		_, err := s.db.Exec(`
+			UPDATE funds SET market_price = ?, market_price_time = ?, premium_rate = ? WHERE code = ?
+		`, quote.Price, now, premium, fund.Code)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// GetPremiums 获取有场内价格的订阅基金，按 sort 排序（premium_rate、abs_premium_rate、market_price、code），
+// order 为 asc 或 desc，缺省按溢价率从高到低
+func (s *FundService) GetPremiums(sort, order string) ([]models.Fund, error) {
+	if sort == "" {
+		sort = "premium_rate"
+	}
+	column, ok := premiumSortColumns[sort]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort %q", ErrInvalidInput, sort)
+	}
+	switch order {
+	case "":
+		order = "desc"
+	case "asc", "desc":
+	default:
+		return nil, fmt.Errorf("%w: unknown order %q", ErrInvalidInput, order)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT ` + fundColumns + `
+		FROM funds
+		WHERE subscribed = 1 AND exchange_symbol != '' AND market_price > 0
+		ORDER BY ` + column + ` ` + order + `, code
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	funds := make([]models.Fund, 0)
+	for rows.Next() {
+		fund, err := scanFund(rows)
+		if err != nil {
+			return nil, err
+		}
+		funds = append(funds, *fund)
+	}
+	return funds, rows.Err()
+}
+
+// UpdateExchangeSymbol 设置基金的场内交易代码（如 sh510300），为空表示不在场内交易，清空已有的场内价格
+func (s *FundService) UpdateExchangeSymbol(code, symbol string) (*models.Fund, error) {
+	if _, err := s.GetFundByCode(code); err != nil {
+		return nil, err
+	}
+	symbol = strings.ToLower(strings.TrimSpace(symbol))
+	if symbol != "" && !exchangeSymbolPattern.MatchString(symbol) {
+		return nil, fmt.Errorf("%w: invalid exchange symbol %q", ErrInvalidInput, symbol)
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE funds SET exchange_symbol = ?, market_price = 0, market_price_time = NULL, premium_rate = 0,
+		       updated_at = ?
+		WHERE code = ?
+	`, symbol, time.Now(), code)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetFundByCode(code)
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+)
+
+// qdiiSector 默认板块中的 QDII 板块
+const qdiiSector = "QDII"
+
+// defaultQDIINavLag QDII 基金默认的净值公布滞后：T 日净值于 T+1 日晚间公布
+const defaultQDIINavLag = 1
+
+// maxNavLag 净值公布滞后的最大交易日数
+const maxNavLag = 3
+
+// isQDII 基金是否投资境外市场
+func isQDII(fund *models.Fund) bool {
+	return fund.Market != "" && fund.Market != calendar.MarketCN
+}
+
+// fundNavDay 判断 day 是否为基金的净值日：A 股交易日，QDII 基金还要求所投资的境外市场开市
+func fundNavDay(fund *models.Fund, day time.Time) bool {
+	if !calendar.Default().IsTradingDay(day) {
+		return false
+	}
+	if !isQDII(fund) {
+		return true
+	}
+	overseas := calendar.Market(fund.Market)
+	return overseas == nil || overseas.IsTradingDay(day)
+}
+
+// fundOrderDate 在 t 时刻提交的申购/赎回所适用的净值日期
+// 与 A 股基金相同按 15:00 划分，QDII 基金遇境外市场休市时继续顺延
+func fundOrderDate(fund *models.Fund, t time.Time) time.Time {
+	cal := calendar.Default()
+	day := cal.NavDate(t)
+	for !fundNavDay(fund, day) {
+		day = cal.NextTradingDay(day)
+	}
+	return day
+}
+
+// publishedNavDate now 时刻应已公布的最新净值日期
+// A 股基金收盘后公布当日净值；QDII 基金 T 日净值于其后第 NavLag 个交易日晚间公布
+func publishedNavDate(fund *models.Fund, now time.Time) time.Time {
+	cal := calendar.Default()
+	day := cal.PrevTradingDay(now)
+	if cal.IsTradingDay(now) && cal.IsAfterClose(now) {
+		day = cal.LatestTradingDay(now)
+	}
+	for i := 0; i < fund.NavLag; i++ {
+		day = cal.PrevTradingDay(day)
+	}
+	for !fundNavDay(fund, day) {
+		day = cal.PrevTradingDay(day)
+	}
+	return day
+}
+
+// nextNavDate date 之后的下一个净值日
+func nextNavDate(fund *models.Fund, date time.Time) time.Time {
+	cal := calendar.Default()
+	day := cal.NextTradingDay(date)
+	for !fundNavDay(fund, day) {
+		day = cal.NextTradingDay(day)
+	}
+	return day
+}
+
+// confirmDate T 日的申购在其后第 NavLag + 1 个交易日确认份额
+func confirmDate(tradeDate time.Time, navLag int) time.Time {
+	cal := calendar.Default()
+	day := tradeDate
+	for i := 0; i <= navLag; i++ {
+		day = cal.NextTradingDay(day)
+	}
+	return day
+}
+
+// UpdateFundMarket 设置基金投资的市场（cn、us、hk）、份额计价币种（CNY、USD、HKD）与净值公布滞后的交易日数
+// 市场为 cn 时滞后固定为 0；境外市场滞后为 0 时取默认值
+func (s *FundService) UpdateFundMarket(code, market, currency string, navLag int) (*models.Fund, error) {
+	if _, err := s.GetFundByCode(code); err != nil {
+		return nil, err
+	}
+	if calendar.Market(market) == nil {
+		return nil, fmt.Errorf("%w: unknown market %q", ErrInvalidInput, market)
+	}
+	switch currency {
+	case models.CurrencyCNY, models.CurrencyUSD, models.CurrencyHKD:
+	default:
+		return nil, fmt.Errorf("%w: unknown currency %q", ErrInvalidInput, currency)
+	}
+	if navLag < 0 || navLag > maxNavLag {
+		return nil, fmt.Errorf("%w: nav_lag must be between 0 and %d", ErrInvalidInput, maxNavLag)
+	}
+	switch {
+	case market == calendar.MarketCN:
+		navLag = 0
+	case navLag == 0:
+		navLag = defaultQDIINavLag
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE funds SET market = ?, currency = ?, nav_lag = ?, updated_at = ? WHERE code = ?
+	`, market, currency, navLag, time.Now(), code)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetFundByCode(code)
+}
+
+// fundNavLag 基金的净值公布滞后，未订阅的基金视为 0
+func (s *FundService) fundNavLag(code string) int {
+	fund, err := s.GetFundByCode(code)
+	if err != nil {
+		return 0
+	}
+	return fund.NavLag
+}
@@ -2,29 +2,137 @@ package services
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"math"
+	"sync"
 	"time"
 
+	"fundnet/backend/internal/calendar"
 	"fundnet/backend/internal/models"
+	"fundnet/backend/internal/scrapers"
 )
 
+// ErrInvalidInput 请求参数不合法，如必填项为空、取值未知或越界；交易流水的校验使用 ErrInvalidTransaction
+var ErrInvalidInput = errors.New("invalid input")
+
+// fundColumns 基金表查询列，与 scanFund 的扫描顺序一致
+const fundColumns = `id, code, name, sector, nav, nav_date, estimate_nav, estimate_time,
+		       daily_growth, nav_source, estimate_source, subscribed, subscribe_time,
+		       market, currency, nav_lag, fund_type, exchange_symbol, market_price, market_price_time, premium_rate,
+		       category, share_class, company, fund_size, size_date, inception_date, benchmark, risk_level,
+		       metadata_updated_at, created_at, updated_at`
+
+// rowScanner 兼容 *sql.Row 与 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanFund 扫描一行基金数据
+func scanFund(row rowScanner) (*models.Fund, error) {
+	var fund models.Fund
+	var navDate, estimateTime, marketPriceTime, sizeDate, inceptionDate, metadataUpdatedAt sql.NullTime
+
+	err := row.Scan(
+		&fund.ID, &fund.Code, &fund.Name, &fund.Sector,
+		&fund.Nav, &navDate, &fund.EstimateNav, &estimateTime,
+		&fund.DailyGrowth, &fund.NavSource, &fund.EstimateSource,
+		&fund.Subscribed, &fund.SubscribeTime,
+		&fund.Market, &fund.Currency, &fund.NavLag, &fund.FundType,
+		&fund.ExchangeSymbol, &fund.MarketPrice, &marketPriceTime, &fund.PremiumRate,
+		&fund.Category, &fund.ShareClass, &fund.Company, &fund.FundSize, &sizeDate, &inceptionDate,
+		&fund.Benchmark, &fund.RiskLevel, &metadataUpdatedAt,
+		&fund.CreatedAt, &fund.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	fund.NavDate = navDate.Time
+	fund.EstimateTime = estimateTime.Time
+	fund.MarketPriceTime = marketPriceTime.Time
+	if sizeDate.Valid {
+		fund.SizeDate = &sizeDate.Time
+	}
+	if inceptionDate.Valid {
+		fund.InceptionDate = &inceptionDate.Time
+	}
+	if metadataUpdatedAt.Valid {
+		fund.MetadataUpdatedAt = &metadataUpdatedAt.Time
+	}
+	return &fund, nil
+}
+
+// positionColumns 持仓表查询列，与 scanPosition 的扫描顺序一致
+const positionColumns = `id, account_id, fund_code, fund_name, shares, cost, cost_basis, current_value,
+		       profit_loss, profit_rate, realized_profit, pending_amount, status, daily_growth, daily_profit, valuation_nav,
+		       valuation_basis, valued_at, sector, dividend_mode, currency, fx_rate, created_at, updated_at`
+
+// scanPosition 扫描一行持仓数据
+func scanPosition(row rowScanner) (*models.Position, error) {
+	var position models.Position
+	var valuedAt sql.NullTime
+	var fxRate sql.NullFloat64
+
+	err := row.Scan(
+		&position.ID, &position.AccountID, &position.FundCode, &position.FundName,
+		&position.Shares, &position.Cost, &position.CostBasis,
+		&position.CurrentValue, &position.ProfitLoss, &position.ProfitRate, &position.RealizedProfit,
+		&position.PendingAmount, &position.Status,
+		&position.DailyGrowth, &position.DailyProfit, &position.ValuationNav,
+		&position.ValuationBasis, &valuedAt, &position.Sector, &position.DividendMode,
+		&position.Currency, &fxRate,
+		&position.CreatedAt, &position.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	position.ValuedAt = valuedAt.Time
+	// 人民币份额汇率恒为 1；外币份额在取得汇率前不折算
+	if position.Currency == "" || position.Currency == models.CurrencyCNY {
+		fxRate = sql.NullFloat64{Float64: 1, Valid: true}
+	}
+	if fxRate.Valid && fxRate.Float64 > 0 {
+		rate := fxRate.Float64
+		position.FxRate = &rate
+		position.CostBasisCNY = cnyAmount(position.CostBasis, rate)
+		position.ValueCNY = cnyAmount(position.CurrentValue, rate)
+		position.ProfitLossCNY = cnyAmount(position.ProfitLoss, rate)
+		position.DailyProfitCNY = cnyAmount(position.DailyProfit, rate)
+	}
+	return &position, nil
+}
+
 // FundService 基金服务
 type FundService struct {
-	db *sql.DB
+	db         *sql.DB
+	sources    *DataSourceChain
+	navService *NavService
+	fees       FeeSource
+	actions    CorporateActionSource
+
+	feeMu       sync.Mutex
+	feeFailures map[string]feeFailure
 }
 
-// NewFundService 创建基金服务
-func NewFundService() *FundService {
+// NewFundService 创建基金服务，fees 为空时费率只能手工录入，actions 为空时不抓取分红与拆分
+func NewFundService(sources *DataSourceChain, navService *NavService, fees FeeSource, actions CorporateActionSource) *FundService {
 	return &FundService{
-		db: models.GetDB(),
+		db:          models.GetDB(),
+		sources:     sources,
+		navService:  navService,
+		fees:        fees,
+		actions:     actions,
+		feeFailures: make(map[string]feeFailure),
 	}
 }
 
 // GetAllFunds 获取所有订阅的基金
 func (s *FundService) GetAllFunds() ([]models.Fund, error) {
 	rows, err := s.db.Query(`
-		SELECT id, code, name, sector, nav, nav_date, estimate_nav, estimate_time,
-		       daily_growth, subscribed, subscribe_time, created_at, updated_at
+		SELECT ` + fundColumns + `
 		FROM funds
 		WHERE subscribed = 1
 		ORDER BY updated_at DESC
@@ -36,27 +144,11 @@ func (s *FundService) GetAllFunds() ([]models.Fund, error) {
 
 	var funds []models.Fund
 	for rows.Next() {
-		var fund models.Fund
-		var estimateTime, navDate sql.NullString
-
-		err := rows.Scan(
-			&fund.ID, &fund.Code, &fund.Name, &fund.Sector,
-			&fund.Nav, &navDate, &fund.EstimateNav, &estimateTime,
-			&fund.DailyGrowth, &fund.Subscribed, &fund.SubscribeTime,
-			&fund.CreatedAt, &fund.UpdatedAt,
-		)
+		fund, err := scanFund(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if estimateTime.Valid {
-			fund.EstimateTime, _ = time.Parse("2006-01-02 15:04:05", estimateTime.String)
-		}
-		if navDate.Valid {
-			fund.NavDate, _ = time.Parse("2006-01-02", navDate.String)
-		}
-
-		funds = append(funds, fund)
+		funds = append(funds, *fund)
 	}
 
 	return funds, nil
@@ -64,55 +156,63 @@ func (s *FundService) GetAllFunds() ([]models.Fund, error) {
 
 // GetFundByCode 根据代码获取基金
 func (s *FundService) GetFundByCode(code string) (*models.Fund, error) {
-	var fund models.Fund
-	var estimateTime, navDate sql.NullString
-
-	err := s.db.QueryRow(`
-		SELECT id, code, name, sector, nav, nav_date, estimate_nav, estimate_time,
-		       daily_growth, subscribed, subscribe_time, created_at, updated_at
+	row := s.db.QueryRow(`
+		SELECT `+fundColumns+`
 		FROM funds
 		WHERE code = ?
-	`, code).Scan(
-		&fund.ID, &fund.Code, &fund.Name, &fund.Sector,
-		&fund.Nav, &navDate, &fund.EstimateNav, &estimateTime,
-		&fund.DailyGrowth, &fund.Subscribed, &fund.SubscribeTime,
-		&fund.CreatedAt, &fund.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	if estimateTime.Valid {
-		fund.EstimateTime, _ = time.Parse("2006-01-02 15:04:05", estimateTime.String)
-	}
-	if navDate.Valid {
-		fund.NavDate, _ = time.Parse("2006-01-02", navDate.String)
-	}
-
-	return &fund, nil
+	`, code)
+	return scanFund(row)
 }
 
 // AddFund 添加基金订阅
+// 归入 QDII 板块的基金默认按投资美股、净值滞后一个交易日公布处理，可通过 UpdateFundMarket 调整；
+// 按代码识别场内上市的 LOF、ETF，可通过 UpdateExchangeSymbol 调整
 func (s *FundService) AddFund(code, name, sector string) (*models.Fund, error) {
+	market, navLag := calendar.MarketCN, 0
+	if sector == qdiiSector {
+		market, navLag = calendar.MarketUS, defaultQDIINavLag
+	}
+
+	symbol := exchangeSymbol(code)
+
 	now := time.Now()
 	result, err := s.db.Exec(`
-		INSERT OR REPLACE INTO funds (code, name, sector, subscribed, subscribe_time, created_at, updated_at)
-		VALUES (?, ?, ?, 1, ?, ?, ?)
-	`, code, name, sector, now, now, now)
+		INSERT OR REPLACE INTO funds
+			(code, name, sector, subscribed, subscribe_time, market, currency, nav_lag, exchange_symbol,
+			 created_at, updated_at)
+		VALUES (?, ?, ?, 1, ?, ?, ?, ?, ?, ?, ?)
+	`, code, name, sector, now, market, models.CurrencyCNY, navLag, symbol, now, now)
 	if err != nil {
 		return nil, err
 	}
 
+	// 订阅后在后台抓取基础资料（名称为空时一并补全）与费率表，并回填全部历史净值，货币基金回填每日收益
+	go func() {
+		if err := s.RefreshFundMetadata(code); err != nil {
+			log.Printf("Failed to fetch metadata of %s: %v", code, err)
+		}
+		if err := s.ensureFeeSchedule(code); err != nil {
+			log.Printf("Failed to fetch fee schedule of %s: %v", code, err)
+		}
+		if _, err := s.navService.Backfill(code, time.Time{}); err != nil {
+			log.Printf("Failed to backfill nav history for %s: %v", code, err)
+		}
+	}()
+
 	id, _ := result.LastInsertId()
 	return &models.Fund{
-		ID:            id,
-		Code:          code,
-		Name:          name,
-		Sector:        sector,
-		Subscribed:    true,
-		SubscribeTime: now,
-		CreatedAt:     now,
-		UpdatedAt:     now,
+		ID:             id,
+		Code:           code,
+		Name:           name,
+		Sector:         sector,
+		Subscribed:     true,
+		SubscribeTime:  now,
+		Market:         market,
+		Currency:       models.CurrencyCNY,
+		NavLag:         navLag,
+		ExchangeSymbol: symbol,
+		CreatedAt:      now,
+		UpdatedAt:      now,
 	}, nil
 }
 
@@ -136,26 +236,88 @@ func (s *FundService) UpdateFund(code, name, sector string) (*models.Fund, error
 	return s.GetFundByCode(code)
 }
 
+// FundDataUpdate 基金行情数据，NavSource/EstimateSource 记录数据来源
+type FundDataUpdate struct {
+	Nav            float64
+	NavDate        string
+	EstimateNav    float64
+	EstimateTime   time.Time
+	DailyGrowth    float64
+	NavSource      string
+	EstimateSource string
+}
+
 // UpdateFundData 更新基金数据
-func (s *FundService) UpdateFundData(code string, nav, estimateNav float64, navDate, dailyGrowth string) error {
-	now := time.Now()
+func (s *FundService) UpdateFundData(code string, data FundDataUpdate) error {
 	_, err := s.db.Exec(`
 		UPDATE funds SET nav = ?, nav_date = ?, estimate_nav = ?,
-		       estimate_time = ?, daily_growth = ?, updated_at = ?
+		       estimate_time = ?, daily_growth = ?, nav_source = ?, estimate_source = ?,
+		       updated_at = ?
 		WHERE code = ?
-	`, nav, navDate, estimateNav, now, dailyGrowth, now, code)
+	`, data.Nav, data.NavDate, data.EstimateNav, data.EstimateTime, data.DailyGrowth,
+		data.NavSource, data.EstimateSource, time.Now(), code)
 	return err
 }
 
 // UpdateAllFundData 更新所有基金数据
 func (s *FundService) UpdateAllFundData() {
-	funds, _ := s.GetAllFunds()
+	funds, err := s.GetAllFunds()
+	if err != nil {
+		log.Printf("Failed to load subscribed funds: %v", err)
+		return
+	}
+
 	for _, fund := range funds {
-		// 这里可以调用爬虫获取最新数据
-		_ = fund
+		if err := s.RefreshFundData(fund.Code); err != nil {
+			log.Printf("Failed to update fund %s: %v", fund.Code, err)
+		}
+	}
+
+	if _, err := s.ConfirmPendingOrders(); err != nil {
+		log.Printf("Failed to confirm pending orders: %v", err)
 	}
 }
 
+// RefreshFundData 从数据源链抓取单个基金的最新估值与净值并写入数据库，货币基金改为抓取每日收益
+func (s *FundService) RefreshFundData(code string) error {
+	if fund, err := s.GetFundByCode(code); err == nil && isMoneyMarket(fund) {
+		return s.refreshMoneyFund(fund)
+	}
+
+	estimate, estimateErr := s.sources.FetchEstimate(code)
+	nav, navErr := s.sources.FetchNav(code)
+	if estimateErr != nil && navErr != nil {
+		return fmt.Errorf("estimate: %v; nav: %v", estimateErr, navErr)
+	}
+
+	var data FundDataUpdate
+	if estimate != nil {
+		data = FundDataUpdate{
+			Nav:            estimate.Nav,
+			NavDate:        estimate.NavDate,
+			EstimateNav:    estimate.EstimateNav,
+			EstimateTime:   estimate.EstimateTime,
+			DailyGrowth:    estimate.DailyGrowth,
+			NavSource:      estimate.Source,
+			EstimateSource: estimate.Source,
+		}
+	}
+	if nav != nil {
+		// 官方净值以更新的一方为准
+		if nav.NavDate >= data.NavDate {
+			data.Nav, data.NavDate, data.NavSource = nav.Nav, nav.NavDate, nav.Source
+		}
+		if estimate == nil {
+			data.EstimateNav, data.EstimateTime, data.DailyGrowth = nav.Nav, time.Now(), nav.DailyGrowth
+		}
+		if err := s.navService.SaveNavs([]scrapers.FundNav{*nav}); err != nil {
+			log.Printf("Failed to save nav history for %s: %v", code, err)
+		}
+	}
+
+	return s.UpdateFundData(code, data)
+}
+
 // GetAllSectors 获取所有板块
 func (s *FundService) GetAllSectors() ([]models.Sector, error) {
 	rows, err := s.db.Query(`
@@ -244,14 +406,31 @@ func (s *FundService) GetSectorByID(id int64) (*models.Sector, error) {
 	return &sector, nil
 }
 
-// GetAllPositions 获取所有持仓
+// GetAllPositions 获取所有持有中或待确认的持仓
 func (s *FundService) GetAllPositions() ([]models.Position, error) {
-	rows, err := s.db.Query(`
-		SELECT id, fund_code, fund_name, shares, cost, cost_basis, current_value,
-		       profit_loss, profit_rate, daily_growth, sector, created_at, updated_at
-		FROM positions
-		ORDER BY created_at DESC
-	`)
+	return s.GetPositions(false)
+}
+
+// GetPositions 获取持仓列表，includeClosed 为 true 时包含已清仓的持仓
+func (s *FundService) GetPositions(includeClosed bool) ([]models.Position, error) {
+	return s.FilterPositions(AssetFilter{}, includeClosed)
+}
+
+// FilterPositions 获取指定账户或组合的持仓列表
+func (s *FundService) FilterPositions(filter AssetFilter, includeClosed bool) ([]models.Position, error) {
+	query := `
+		SELECT ` + positionColumns + `
+		FROM positions p
+		WHERE 1 = 1
+	`
+	query, args := appendFilter(query, filter)
+	if !includeClosed {
+		query += " AND status != ?"
+		args = append(args, models.PositionClosed)
+	}
+	query += " ORDER BY created_at DESC"
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -259,102 +438,132 @@ func (s *FundService) GetAllPositions() ([]models.Position, error) {
 
 	var positions []models.Position
 	for rows.Next() {
-		var position models.Position
-		err := rows.Scan(
-			&position.ID, &position.FundCode, &position.FundName,
-			&position.Shares, &position.Cost, &position.CostBasis,
-			&position.CurrentValue, &position.ProfitLoss, &position.ProfitRate,
-			&position.DailyGrowth, &position.Sector,
-			&position.CreatedAt, &position.UpdatedAt,
-		)
+		position, err := scanPosition(rows)
 		if err != nil {
 			return nil, err
 		}
-		positions = append(positions, position)
+		positions = append(positions, *position)
 	}
 
 	return positions, nil
 }
 
-// AddPosition 添加持仓
-func (s *FundService) AddPosition(fundCode, fundName string, shares, cost float64, sector string) (*models.Position, error) {
-	now := time.Now()
-	costBasis := shares * cost
-	result, err := s.db.Exec(`
-		INSERT INTO positions (fund_code, fund_name, shares, cost, cost_basis, sector, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`, fundCode, fundName, shares, cost, costBasis, sector, now, now)
-	if err != nil {
-		return nil, err
-	}
-
-	id, _ := result.LastInsertId()
-	return &models.Position{
-		ID:        id,
+// AddPosition 添加持仓，记为一笔转入交易；accountID 为 0 时归入默认账户
+func (s *FundService) AddPosition(accountID int64, fundCode, fundName string, shares, cost float64,
+	sector string) (*models.Position, error) {
+	return s.RecordTransaction(TransactionInput{
+		AccountID: accountID,
 		FundCode:  fundCode,
 		FundName:  fundName,
+		Type:      models.TransactionTransferIn,
 		Shares:    shares,
-		Cost:      cost,
-		CostBasis: costBasis,
+		Price:     cost,
 		Sector:    sector,
-		CreatedAt: now,
-		UpdatedAt: now,
-	}, nil
+	})
 }
 
-// UpdatePosition 更新持仓
-func (s *FundService) UpdatePosition(id int64, shares, cost float64, sector string) (*models.Position, error) {
-	now := time.Now()
-	costBasis := shares * cost
-	_, err := s.db.Exec(`
-		UPDATE positions SET shares = ?, cost = ?, cost_basis = ?, sector = ?, updated_at = ?
-		WHERE id = ?
-	`, shares, cost, costBasis, sector, now, id)
+// UpdatePosition 更新持仓的板块与分红方式（为空时不变）；份额与成本由交易流水推导，不允许直接修改
+func (s *FundService) UpdatePosition(id int64, shares, cost float64, sector, dividendMode string) (*models.Position, error) {
+	position, err := s.GetPositionByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if (shares != 0 && math.Abs(shares-position.Shares) > shareEpsilon) ||
+		(cost != 0 && math.Abs(cost-position.Cost) > 1e-4) {
+		return nil, ErrPositionDerived
+	}
+	switch dividendMode {
+	case "":
+		dividendMode = position.DividendMode
+	case models.DividendModeCash, models.DividendModeReinvest:
+	default:
+		return nil, fmt.Errorf("%w: unknown dividend_mode %q", ErrInvalidInput, dividendMode)
+	}
+
+	// 分红方式另存于 position_settings，持仓由流水重新推导时据此恢复
+	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.Exec(`
+		INSERT INTO position_settings (account_id, fund_code, dividend_mode, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (account_id, fund_code) DO UPDATE SET
+			dividend_mode = excluded.dividend_mode,
+			updated_at = excluded.updated_at
+	`, position.AccountID, position.FundCode, dividendMode, now); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE positions SET sector = ?, dividend_mode = ?, updated_at = ? WHERE id = ?
+	`, sector, dividendMode, now, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
 	return s.GetPositionByID(id)
 }
 
-// DeletePosition 删除持仓
+// DeletePosition 删除没有交易流水的持仓；仍有流水时返回 ErrInUse，需先逐笔删除交易
 func (s *FundService) DeletePosition(id int64) error {
-	_, err := s.db.Exec(`DELETE FROM positions WHERE id = ?`, id)
+	position, err := s.GetPositionByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var transactions int
+	if err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM transactions WHERE account_id = ? AND fund_code = ?
+	`, position.AccountID, position.FundCode).Scan(&transactions); err != nil {
+		return err
+	}
+	if transactions > 0 {
+		return fmt.Errorf("%w: position has %d transactions", ErrInUse, transactions)
+	}
+
+	_, err = s.db.Exec(`DELETE FROM positions WHERE id = ?`, id)
 	return err
 }
 
 // GetPositionByID 根据ID获取持仓
 func (s *FundService) GetPositionByID(id int64) (*models.Position, error) {
-	var position models.Position
-	err := s.db.QueryRow(`
-		SELECT id, fund_code, fund_name, shares, cost, cost_basis, current_value,
-		       profit_loss, profit_rate, daily_growth, sector, created_at, updated_at
+	row := s.db.QueryRow(`
+		SELECT `+positionColumns+`
 		FROM positions WHERE id = ?
-	`, id).Scan(
-		&position.ID, &position.FundCode, &position.FundName,
-		&position.Shares, &position.Cost, &position.CostBasis,
-		&position.CurrentValue, &position.ProfitLoss, &position.ProfitRate,
-		&position.DailyGrowth, &position.Sector,
-		&position.CreatedAt, &position.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return &position, nil
+	`, id)
+	return scanPosition(row)
 }
 
-// GetAssetStats 获取资产统计
-func (s *FundService) GetAssetStats() (map[string]interface{}, error) {
-	positions, err := s.GetAllPositions()
+// GetAssetStats 获取资产统计，filter 为空时统计全部账户；外币份额持仓折算为人民币，
+// 尚无汇率的持仓不计入合计，其基金代码列于 fx_pending
+func (s *FundService) GetAssetStats(filter AssetFilter) (map[string]interface{}, error) {
+	positions, err := s.FilterPositions(filter, true)
 	if err != nil {
 		return nil, err
 	}
+	positions, pending := positionsInCNY(positions)
 
-	var totalCostBasis, totalCurrentValue, totalProfitLoss float64
+	// 已实现盈亏包含已清仓的持仓，其余统计只计持有中或待确认的持仓
+	var totalCostBasis, totalCurrentValue, totalProfitLoss, totalDailyProfit, totalRealizedProfit float64
+	positionCount := 0
 	for _, pos := range positions {
+		totalRealizedProfit += pos.RealizedProfit
+		if pos.Status == models.PositionClosed {
+			continue
+		}
+		positionCount++
 		totalCostBasis += pos.CostBasis
 		totalCurrentValue += pos.CurrentValue
 		totalProfitLoss += pos.ProfitLoss
+		totalDailyProfit += pos.DailyProfit
 	}
 
 	profitRate := float64(0)
@@ -363,20 +572,25 @@ func (s *FundService) GetAssetStats() (map[string]interface{}, error) {
 	}
 
 	return map[string]interface{}{
-		"total_cost_basis":    totalCostBasis,
-		"total_current_value": totalCurrentValue,
-		"total_profit_loss":   totalProfitLoss,
-		"profit_rate":         profitRate,
-		"position_count":      len(positions),
+		"total_cost_basis":      totalCostBasis,
+		"total_current_value":   totalCurrentValue,
+		"total_profit_loss":     totalProfitLoss,
+		"total_daily_profit":    totalDailyProfit,
+		"total_realized_profit": totalRealizedProfit,
+		"profit_rate":           profitRate,
+		"position_count":        positionCount,
+		"fx_pending":            pending,
 	}, nil
 }
 
-// GetAssetSummary 获取资产摘要（按板块分组）
-func (s *FundService) GetAssetSummary() (map[string]interface{}, error) {
-	positions, err := s.GetAllPositions()
+// GetAssetSummary 获取资产摘要（按板块分组），并附各组合与账户的汇总；filter 为空时统计全部账户
+// 外币份额持仓折算为人民币，尚无汇率的持仓不计入合计，其基金代码列于 fx_pending
+func (s *FundService) GetAssetSummary(filter AssetFilter) (map[string]interface{}, error) {
+	positions, err := s.FilterPositions(filter, false)
 	if err != nil {
 		return nil, err
 	}
+	positions, pending := positionsInCNY(positions)
 
 	sectorStats := make(map[string]map[string]float64)
 	for _, pos := range positions {
@@ -385,11 +599,13 @@ func (s *FundService) GetAssetSummary() (map[string]interface{}, error) {
 				"cost_basis":    0,
 				"current_value": 0,
 				"profit_loss":   0,
+				"daily_profit":  0,
 			}
 		}
 		sectorStats[pos.Sector]["cost_basis"] += pos.CostBasis
 		sectorStats[pos.Sector]["current_value"] += pos.CurrentValue
 		sectorStats[pos.Sector]["profit_loss"] += pos.ProfitLoss
+		sectorStats[pos.Sector]["daily_profit"] += pos.DailyProfit
 	}
 
 	// 计算各板块占比
@@ -413,17 +629,19 @@ func (s *FundService) GetAssetSummary() (map[string]interface{}, error) {
 			"cost_basis":    stats["cost_basis"],
 			"current_value": stats["current_value"],
 			"profit_loss":   stats["profit_loss"],
+			"daily_profit":  stats["daily_profit"],
 			"profit_rate":   profitRate,
 			"weight":        weight,
 		})
 	}
 
 	// 总计
-	totalCostBasis, totalCurrentValue, totalProfitLoss := 0.0, 0.0, 0.0
+	totalCostBasis, totalCurrentValue, totalProfitLoss, totalDailyProfit := 0.0, 0.0, 0.0, 0.0
 	for _, s := range sectors {
 		totalCostBasis += s["cost_basis"].(float64)
 		totalCurrentValue += s["current_value"].(float64)
 		totalProfitLoss += s["profit_loss"].(float64)
+		totalDailyProfit += s["daily_profit"].(float64)
 	}
 
 	totalProfitRate := float64(0)
@@ -431,12 +649,21 @@ func (s *FundService) GetAssetSummary() (map[string]interface{}, error) {
 		totalProfitRate = (totalProfitLoss / totalCostBasis) * 100
 	}
 
+	portfolios, accounts, err := s.accountBreakdown(positions, totalCurrentValue)
+	if err != nil {
+		return nil, err
+	}
+
 	return map[string]interface{}{
 		"sectors":             sectors,
+		"portfolios":          portfolios,
+		"accounts":            accounts,
 		"total_cost_basis":    totalCostBasis,
 		"total_current_value": totalCurrentValue,
 		"total_profit_loss":   totalProfitLoss,
+		"total_daily_profit":  totalDailyProfit,
 		"total_profit_rate":   totalProfitRate,
+		"fx_pending":          pending,
 	}, nil
 }
 
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+	"fundnet/backend/internal/scrapers"
+)
+
+// FxSource 提供人民币汇率中间价的数据源
+type FxSource interface {
+	FetchFxRates() ([]scrapers.FxRate, error)
+}
+
+// fxCurrencies 需要保存汇率的外币，与支持的份额计价币种一致
+var fxCurrencies = map[string]bool{
+	models.CurrencyUSD: true,
+	models.CurrencyHKD: true,
+}
+
+// fxRetryInterval 缺少汇率时两次按需抓取的最小间隔
+const fxRetryInterval = 10 * time.Minute
+
+// FxService 汇率服务
+type FxService struct {
+	db     *sql.DB
+	source FxSource
+
+	mu          sync.Mutex
+	lastFetchAt time.Time
+}
+
+// NewFxService 创建汇率服务，source 为空时只能读取已入库的汇率
+func NewFxService(source FxSource) *FxService {
+	return &FxService{
+		db:     models.GetDB(),
+		source: source,
+	}
+}
+
+// RefreshRates 抓取最新一期人民币汇率中间价并入库，返回写入条数
+func (s *FxService) RefreshRates() (int, error) {
+	if s.source == nil {
+		return 0, errors.New("no fx data source configured")
+	}
+
+	rates, err := s.source.FetchFxRates()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	count := 0
+	for _, rate := range rates {
+		if !fxCurrencies[rate.Currency] {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO fx_rates (currency, rate_date, rate, source, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, rate.Currency, rate.RateDate, rate.Rate, rate.Source, time.Now())
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+
+	return count, tx.Commit()
+}
+
+// RateOn 获取 currency 在 date 当日或之前最近一期的中间价，库中没有时抓取最新一期后再查询，
+// 抓取间隔不短于 fxRetryInterval；仍没有汇率时返回 sql.ErrNoRows
+func (s *FxService) RateOn(currency string, date time.Time) (float64, error) {
+	rate, err := fxRateOn(s.db, currency, date)
+	if !errors.Is(err, sql.ErrNoRows) || s.source == nil {
+		return rate, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.lastFetchAt) >= fxRetryInterval {
+		s.lastFetchAt = time.Now()
+		if _, err := s.RefreshRates(); err != nil {
+			log.Printf("Failed to fetch missing %s fx rate: %v", currency, err)
+		}
+	}
+	return fxRateOn(s.db, currency, date)
+}
+
+// GetRates 查询 [from, to] 区间内的汇率，按币种与日期升序；currency 为空时返回全部币种，零值日期表示不限
+func (s *FxService) GetRates(currency string, from, to time.Time) ([]models.FxRate, error) {
+	query := `
+		SELECT id, currency, rate_date, rate, source, created_at
+		FROM fx_rates
+		WHERE 1 = 1
+	`
+	var args []interface{}
+	if currency != "" {
+		query += " AND currency = ?"
+		args = append(args, currency)
+	}
+	if !from.IsZero() {
+		query += " AND rate_date >= ?"
+		args = append(args, from.Format(calendar.DateLayout))
+	}
+	if !to.IsZero() {
+		query += " AND rate_date <= ?"
+		args = append(args, to.Format(calendar.DateLayout))
+	}
+	query += " ORDER BY currency, rate_date"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := make([]models.FxRate, 0)
+	for rows.Next() {
+		var rate models.FxRate
+		if err := rows.Scan(&rate.ID, &rate.Currency, &rate.RateDate, &rate.Rate, &rate.Source, &rate.CreatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// fxRateOn 获取 currency 在 date 当日或之前最近一期的中间价，人民币恒为 1；没有汇率时返回 sql.ErrNoRows
+func fxRateOn(db *sql.DB, currency string, date time.Time) (float64, error) {
+	if currency == "" || currency == models.CurrencyCNY {
+		return 1, nil
+	}
+	var rate float64
+	err := db.QueryRow(`
+		SELECT rate FROM fx_rates
+		WHERE currency = ? AND rate_date <= ?
+		ORDER BY rate_date DESC LIMIT 1
+	`, currency, calendar.Default().FormatDate(date)).Scan(&rate)
+	return rate, err
+}
+
+// fxRateNear 获取 currency 在 date 当日或之前最近一期的中间价，早于库中最早一期时取最早一期；
+// 该币种没有任何汇率时返回 sql.ErrNoRows
+func fxRateNear(db *sql.DB, currency string, date time.Time) (float64, error) {
+	rate, err := fxRateOn(db, currency, date)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return rate, err
+	}
+	err = db.QueryRow(`
+		SELECT rate FROM fx_rates WHERE currency = ? ORDER BY rate_date LIMIT 1
+	`, currency).Scan(&rate)
+	return rate, err
+}
+
+// cnyAmount 按汇率将金额折算为人民币
+func cnyAmount(amount, rate float64) *float64 {
+	value := round(amount*rate, 2)
+	return &value
+}
+
+// positionsInCNY 将外币份额持仓的金额按持仓记录的汇率折算为人民币，用于跨币种汇总；
+// 尚无汇率的持仓无法折算，不计入返回的持仓，其基金代码在 pending 中返回
+func positionsInCNY(positions []models.Position) (converted []models.Position, pending []string) {
+	converted = make([]models.Position, 0, len(positions))
+	pending = make([]string, 0)
+	for _, pos := range positions {
+		if pos.FxRate == nil {
+			pending = append(pending, pos.FundCode)
+			continue
+		}
+		if rate := *pos.FxRate; rate != 1 {
+			pos.CostBasis = *pos.CostBasisCNY
+			pos.CurrentValue = *pos.ValueCNY
+			pos.ProfitLoss = *pos.ProfitLossCNY
+			pos.DailyProfit = *pos.DailyProfitCNY
+			pos.RealizedProfit = round(pos.RealizedProfit*rate, 2)
+			pos.PendingAmount = round(pos.PendingAmount*rate, 2)
+		}
+		converted = append(converted, pos)
+	}
+	return converted, pending
+}
+
+// fxPendingError 存在尚无汇率、无法折算为人民币的持仓时返回的错误
+func fxPendingError(pending []string) error {
+	return fmt.Errorf("%w: no fx rate yet for %s", ErrInsufficientData, strings.Join(pending, ", "))
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/models"
+	"fundnet/backend/internal/scrapers"
+)
+
+// navPageSize 历史净值分页大小
+const navPageSize = 20
+
+// NavHistorySource 支持分页获取历史净值的数据源
+type NavHistorySource interface {
+	FundDataSource
+	FetchNavPage(code string, pageIndex, pageSize int) ([]scrapers.FundNav, int, error)
+}
+
+// NavService 历史净值服务
+type NavService struct {
+	db      *sql.DB
+	sources *DataSourceChain
+}
+
+// NewNavService 创建历史净值服务
+func NewNavService(sources *DataSourceChain) *NavService {
+	return &NavService{
+		db:      models.GetDB(),
+		sources: sources,
+	}
+}
+
+// Backfill 回填基金自 from 起的历史净值，from 为零值时回填全部历史，返回写入条数
+// 货币基金改为回填每日收益，并据此写入 1 元的单位净值
+func (s *NavService) Backfill(code string, from time.Time) (int, error) {
+	if s.isMoneyFund(code) {
+		return s.BackfillMoneyYields(code, from)
+	}
+
+	var errs []error
+	for _, source := range s.sources.Sources() {
+		historySource, ok := source.(NavHistorySource)
+		if !ok {
+			continue
+		}
+
+		count, err := s.backfillFrom(historySource, code, from)
+		if err == nil {
+			return count, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+	}
+
+	if len(errs) == 0 {
+		return 0, errors.New("no data source supports nav history")
+	}
+	return 0, errors.Join(errs...)
+}
+
+// backfillFrom 从指定数据源按日期倒序翻页，直到早于 from 或翻完
+func (s *NavService) backfillFrom(source NavHistorySource, code string, from time.Time) (int, error) {
+	fromDate := ""
+	if !from.IsZero() {
+		fromDate = from.Format(calendar.DateLayout)
+	}
+
+	count := 0
+	for page := 1; ; page++ {
+		navs, total, err := source.FetchNavPage(code, page, navPageSize)
+		if err != nil {
+			return count, err
+		}
+
+		reachedFrom := false
+		batch := make([]scrapers.FundNav, 0, len(navs))
+		for _, nav := range navs {
+			if fromDate != "" && nav.NavDate < fromDate {
+				reachedFrom = true
+				break
+			}
+			batch = append(batch, nav)
+		}
+
+		if err := s.SaveNavs(batch); err != nil {
+			return count, err
+		}
+		count += len(batch)
+
+		if reachedFrom || len(navs) == 0 || page*navPageSize >= total {
+			return count, nil
+		}
+	}
+}
+
+// SaveNavs 批量写入历史净值，同一日期的记录会被覆盖
+func (s *NavService) SaveNavs(navs []scrapers.FundNav) error {
+	if len(navs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO nav_history (fund_code, nav_date, nav, acc_nav, daily_growth, source, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for _, nav := range navs {
+		if nav.NavDate == "" || nav.Nav <= 0 {
+			continue
+		}
+		if _, err := stmt.Exec(nav.Code, nav.NavDate, nav.Nav, nav.AccNav, nav.DailyGrowth, nav.Source, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetNavHistory 获取 [from, to] 区间内的历史净值，按日期升序；零值表示不限
+func (s *NavService) GetNavHistory(code string, from, to time.Time) ([]models.NavHistory, error) {
+	query := `
+		SELECT id, fund_code, nav_date, nav, acc_nav, daily_growth, source, created_at
+		FROM nav_history
+		WHERE fund_code = ?
+	`
+	args := []interface{}{code}
+	if !from.IsZero() {
+		query += " AND nav_date >= ?"
+		args = append(args, from.Format(calendar.DateLayout))
+	}
+	if !to.IsZero() {
+		query += " AND nav_date <= ?"
+		args = append(args, to.Format(calendar.DateLayout))
+	}
+	query += " ORDER BY nav_date"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []models.NavHistory
+	for rows.Next() {
+		var nav models.NavHistory
+		err := rows.Scan(&nav.ID, &nav.FundCode, &nav.NavDate, &nav.Nav, &nav.AccNav,
+			&nav.DailyGrowth, &nav.Source, &nav.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, nav)
+	}
+
+	return history, nil
+}
+
+// navOn 获取基金 date 当日的单位净值
+func (s *NavService) navOn(code string, date time.Time) (float64, error) {
+	var nav float64
+	err := s.db.QueryRow(`
+		SELECT nav FROM nav_history WHERE fund_code = ? AND nav_date = ? AND nav > 0
+	`, code, date.Format(calendar.DateLayout)).Scan(&nav)
+	return nav, err
+}
+
+// GetAdjustedNavHistory 获取 [from, to] 区间内的历史净值，并按分红与拆分计算复权净值 AdjNav
+// 复权因子为除权日不晚于净值日期的各次分红 (1 + 每份分红 / 除权日净值) 与拆分比例之积，
+// 即分红按除权日净值再投资；除权日净值缺失的分红不计入。
+// 货币基金的单位净值恒为 1 元，复权净值为区间内每日收益率的连乘，保留 6 位小数
+func (s *NavService) GetAdjustedNavHistory(code string, from, to time.Time) ([]models.NavHistory, error) {
+	history, err := s.GetNavHistory(code, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if s.isMoneyFund(code) {
+		factor := 1.0
+		for i := range history {
+			factor *= 1 + history[i].DailyGrowth/100
+			history[i].AdjNav = round(factor, 6)
+		}
+		return history, nil
+	}
+
+	query := `
+		SELECT type, ex_date, cash_per_share, split_ratio
+		FROM corporate_actions
+		WHERE fund_code = ?
+	`
+	args := []interface{}{code}
+	if !to.IsZero() {
+		query += " AND ex_date <= ?"
+		args = append(args, to.Format(calendar.DateLayout))
+	}
+	query += " ORDER BY ex_date"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	type adjustment struct {
+		exDate time.Time
+		factor float64
+	}
+	var adjustments []adjustment
+	var dividends []models.CorporateAction
+	for rows.Next() {
+		var action models.CorporateAction
+		if err := rows.Scan(&action.Type, &action.ExDate, &action.CashPerShare, &action.SplitRatio); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		switch {
+		case action.Type == models.ActionSplit && action.SplitRatio > 0:
+			adjustments = append(adjustments, adjustment{action.ExDate, action.SplitRatio})
+		case action.Type == models.ActionDividend && action.CashPerShare > 0:
+			dividends = append(dividends, action)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, action := range dividends {
+		nav, err := s.navOn(code, action.ExDate)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		adjustments = append(adjustments, adjustment{action.ExDate, 1 + action.CashPerShare/nav})
+	}
+
+	for i := range history {
+		factor := 1.0
+		for _, adj := range adjustments {
+			if !adj.exDate.After(history[i].NavDate) {
+				factor *= adj.factor
+			}
+		}
+		history[i].AdjNav = round(history[i].Nav*factor, 4)
+	}
+	return history, nil
+}
@@ -0,0 +1,449 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"fundnet/backend/internal/calendar"
+	"fundnet/backend/internal/config"
+	"fundnet/backend/internal/models"
+	"fundnet/backend/internal/scrapers"
+)
+
+// tradingDaysPerYear 年化使用的交易日数
+const tradingDaysPerYear = 252
+
+// ErrInsufficientData 区间内的净值或市值不足以计算风险指标
+var ErrInsufficientData = errors.New("insufficient data")
+
+// IndexHistorySource 指数历史行情数据源
+type IndexHistorySource interface {
+	FetchIndexHistory(symbol string, from, to time.Time) ([]scrapers.IndexClose, error)
+}
+
+// RiskMetrics 风险指标
+// 收益率、波动率、回撤与无风险利率均为百分比；回撤以正数表示，尚未修复时 RecoveryDate 为空；
+// 基准数据不可用时 Beta 与 Correlation 为空
+type RiskMetrics struct {
+	Scope            string     `json:"scope"`
+	Code             string     `json:"code"`
+	Benchmark        string     `json:"benchmark"`
+	From             time.Time  `json:"from"`
+	To               time.Time  `json:"to"`
+	Observations     int        `json:"observations"`
+	TotalReturn      float64    `json:"total_return"`
+	AnnualizedReturn float64    `json:"annualized_return"`
+	Volatility       float64    `json:"volatility"`
+	MaxDrawdown      float64    `json:"max_drawdown"`
+	DrawdownStart    *time.Time `json:"drawdown_start"`
+	DrawdownTrough   *time.Time `json:"drawdown_trough"`
+	DrawdownRecovery *time.Time `json:"drawdown_recovery"`
+	RiskFreeRate     float64    `json:"risk_free_rate"`
+	Sharpe           *float64   `json:"sharpe"`
+	Sortino          *float64   `json:"sortino"`
+	Beta             *float64   `json:"beta"`
+	Correlation      *float64   `json:"correlation"`
+}
+
+// RiskService 风险指标服务
+type RiskService struct {
+	db          *sql.DB
+	fundService *FundService
+	navService  *NavService
+	indexes     IndexHistorySource
+	cfg         config.AppConfig
+
+	mu        sync.Mutex
+	backfills map[string]time.Time
+}
+
+// NewRiskService 创建风险指标服务
+func NewRiskService(fundService *FundService, navService *NavService, indexes IndexHistorySource,
+	cfg config.AppConfig) *RiskService {
+	return &RiskService{
+		db:          models.GetDB(),
+		fundService: fundService,
+		navService:  navService,
+		indexes:     indexes,
+		cfg:         cfg,
+		backfills:   make(map[string]time.Time),
+	}
+}
+
+// riskBackfillInterval 同一序列向前回填的最小间隔，避免成立较晚的基金每次查询都重新抓取
+const riskBackfillInterval = 24 * time.Hour
+
+// GetFundRisk 按 [from, to] 区间的复权净值计算基金的风险指标，本地净值不足时先回填
+// from、to 为零值时取最近一年；benchmark 为空时使用配置的默认基准
+func (s *RiskService) GetFundRisk(code string, from, to time.Time, benchmark string) (*RiskMetrics, error) {
+	if _, err := s.fundService.GetFundByCode(code); err != nil {
+		return nil, err
+	}
+	from, to = riskWindow(from, to)
+	s.ensureNavs(code, from, to)
+
+	// 复权净值按除权日净值将分红再投资并折算拆分，收益率不受分红与拆分导致的净值跳变影响
+	history, err := s.navService.GetAdjustedNavHistory(code, from, to)
+	if err != nil {
+		return nil, err
+	}
+	series := make([]valuationPoint, 0, len(history))
+	for _, nav := range history {
+		if nav.AdjNav <= 0 {
+			continue
+		}
+		series = append(series, valuationPoint{Date: dateOnly(nav.NavDate), Value: nav.AdjNav})
+	}
+
+	return s.computeRisk("fund", code, series, from, to, benchmark)
+}
+
+// GetPortfolioRisk 按资产快照与交易流水得到的组合每日收益（剔除资金进出）计算组合的风险指标
+func (s *RiskService) GetPortfolioRisk(from, to time.Time, benchmark string) (*RiskMetrics, error) {
+	from, to = riskWindow(from, to)
+
+	valuations, err := s.fundService.loadValuations()
+	if err != nil {
+		return nil, err
+	}
+	flows, err := s.fundService.loadCashFlows()
+	if err != nil {
+		return nil, err
+	}
+	key := scopeKey{models.SnapshotPortfolio, ""}
+
+	// 以区间开始前一日为基点 1，按每日收益连乘得到净值序列
+	start := from.AddDate(0, 0, -1)
+	returns := flowAdjustedReturns(valuations[key], flows[key], start, to)
+	series := make([]valuationPoint, 0, len(returns)+1)
+	if len(returns) > 0 {
+		series = append(series, valuationPoint{Date: start, Value: 1})
+		if prev := lastBefore(valuations[key], returns[0].Date); !prev.IsZero() {
+			series[0].Date = prev
+		}
+	}
+	value := 1.0
+	for _, point := range returns {
+		value *= 1 + point.Value
+		series = append(series, valuationPoint{Date: point.Date, Value: value})
+	}
+
+	return s.computeRisk(models.SnapshotPortfolio, "", series, from, to, benchmark)
+}
+
+// riskWindow 补全查询区间，缺省为截至今天的最近一年
+func riskWindow(from, to time.Time) (time.Time, time.Time) {
+	if to.IsZero() {
+		to = dateOnly(time.Now())
+	}
+	if from.IsZero() {
+		from = to.AddDate(-1, 0, 0)
+	}
+	return dateOnly(from), dateOnly(to)
+}
+
+// lastBefore 返回 date 之前最近一次快照的日期，没有时为零值
+func lastBefore(valuations []valuationPoint, date time.Time) time.Time {
+	var last time.Time
+	for _, point := range valuations {
+		if !point.Date.Before(date) {
+			break
+		}
+		last = point.Date
+	}
+	return last
+}
+
+// computeRisk 由按日期升序的净值序列计算风险指标
+func (s *RiskService) computeRisk(scope, code string, series []valuationPoint, from, to time.Time,
+	benchmark string) (*RiskMetrics, error) {
+	if len(series) < 3 {
+		return nil, fmt.Errorf("%w: %d data points between %s and %s", ErrInsufficientData,
+			len(series), from.Format(calendar.DateLayout), to.Format(calendar.DateLayout))
+	}
+	if benchmark == "" {
+		benchmark = s.cfg.BenchmarkIndex
+	}
+
+	metrics := &RiskMetrics{
+		Scope:        scope,
+		Code:         code,
+		Benchmark:    benchmark,
+		From:         from,
+		To:           to,
+		Observations: len(series) - 1,
+		RiskFreeRate: round(s.cfg.RiskFreeRate*100, 2),
+	}
+
+	returns := make([]float64, 0, len(series)-1)
+	for i := 1; i < len(series); i++ {
+		returns = append(returns, series[i].Value/series[i-1].Value-1)
+	}
+
+	total := series[len(series)-1].Value/series[0].Value - 1
+	annualized := math.Pow(1+total, tradingDaysPerYear/float64(len(returns))) - 1
+	volatility := stdDev(returns) * math.Sqrt(tradingDaysPerYear)
+	metrics.TotalReturn = round(total*100, 2)
+	metrics.AnnualizedReturn = round(annualized*100, 2)
+	metrics.Volatility = round(volatility*100, 2)
+
+	// 夏普比率以年化波动率为分母，索提诺比率只计低于无风险日收益的下行波动
+	excess := annualized - s.cfg.RiskFreeRate
+	if volatility > 0 {
+		sharpe := round(excess/volatility, 2)
+		metrics.Sharpe = &sharpe
+	}
+	dailyRiskFree := s.cfg.RiskFreeRate / tradingDaysPerYear
+	var downside float64
+	for _, r := range returns {
+		if r < dailyRiskFree {
+			downside += (r - dailyRiskFree) * (r - dailyRiskFree)
+		}
+	}
+	if downside > 0 {
+		sortino := round(excess/(math.Sqrt(downside/float64(len(returns)))*math.Sqrt(tradingDaysPerYear)), 2)
+		metrics.Sortino = &sortino
+	}
+
+	applyMaxDrawdown(metrics, series)
+	s.applyBenchmark(metrics, series, to)
+	return metrics, nil
+}
+
+// applyMaxDrawdown 计算最大回撤及其起点（前高）、谷底与修复日期
+func applyMaxDrawdown(metrics *RiskMetrics, series []valuationPoint) {
+	peak := series[0]
+	var maxDrawdown float64
+	var start, trough valuationPoint
+	for _, point := range series {
+		if point.Value > peak.Value {
+			peak = point
+		}
+		if drawdown := 1 - point.Value/peak.Value; drawdown > maxDrawdown {
+			maxDrawdown = drawdown
+			start, trough = peak, point
+		}
+	}
+	metrics.MaxDrawdown = round(maxDrawdown*100, 2)
+	if maxDrawdown == 0 {
+		return
+	}
+
+	metrics.DrawdownStart = &start.Date
+	metrics.DrawdownTrough = &trough.Date
+	for _, point := range series {
+		if point.Date.After(trough.Date) && point.Value >= start.Value {
+			recovery := point.Date
+			metrics.DrawdownRecovery = &recovery
+			break
+		}
+	}
+}
+
+// applyBenchmark 计算相对基准指数的 Beta 与相关系数，基准收益按序列相邻日期对齐
+func (s *RiskService) applyBenchmark(metrics *RiskMetrics, series []valuationPoint, to time.Time) {
+	closes, err := s.indexCloses(metrics.Benchmark, series[0].Date, to)
+	if err != nil {
+		log.Printf("Failed to load benchmark %s: %v", metrics.Benchmark, err)
+		return
+	}
+
+	var own, bench []float64
+	for i := 1; i < len(series); i++ {
+		prev, ok := closes[series[i-1].Date.Format(calendar.DateLayout)]
+		if !ok {
+			continue
+		}
+		cur, ok := closes[series[i].Date.Format(calendar.DateLayout)]
+		if !ok {
+			continue
+		}
+		own = append(own, series[i].Value/series[i-1].Value-1)
+		bench = append(bench, cur/prev-1)
+	}
+	if len(own) < 2 {
+		return
+	}
+
+	cov := covariance(own, bench)
+	benchVar := covariance(bench, bench)
+	ownVar := covariance(own, own)
+	if benchVar > 0 {
+		beta := round(cov/benchVar, 2)
+		metrics.Beta = &beta
+	}
+	if benchVar > 0 && ownVar > 0 {
+		correlation := round(cov/math.Sqrt(benchVar*ownVar), 2)
+		metrics.Correlation = &correlation
+	}
+}
+
+// ensureNavs 本地历史净值未覆盖 [from, to] 时从数据源回填，失败只记录日志
+func (s *RiskService) ensureNavs(code string, from, to time.Time) {
+	if s.navService == nil {
+		return
+	}
+	var first, last sql.NullString
+	err := s.db.QueryRow(`SELECT MIN(nav_date), MAX(nav_date) FROM nav_history WHERE fund_code = ?`, code).
+		Scan(&first, &last)
+	if err != nil {
+		log.Printf("Failed to check nav history of %s: %v", code, err)
+		return
+	}
+
+	backfillFrom, ok := s.missingFrom("nav:"+code, first, last, from, to)
+	if !ok {
+		return
+	}
+	if _, err := s.navService.Backfill(code, backfillFrom); err != nil {
+		log.Printf("Failed to backfill nav history of %s: %v", code, err)
+	}
+}
+
+// indexCloses 读取基准指数 [from, to] 区间的收盘点位，本地缓存未覆盖时先抓取
+func (s *RiskService) indexCloses(symbol string, from, to time.Time) (map[string]float64, error) {
+	var first, last sql.NullString
+	err := s.db.QueryRow(`SELECT MIN(trade_date), MAX(trade_date) FROM index_history WHERE symbol = ?`, symbol).
+		Scan(&first, &last)
+	if err != nil {
+		return nil, err
+	}
+	if fetchFrom, ok := s.missingFrom("index:"+symbol, first, last, from, to); ok && s.indexes != nil {
+		if err := s.fetchIndex(symbol, fetchFrom, to); err != nil {
+			log.Printf("Failed to fetch index history of %s: %v", symbol, err)
+		}
+	}
+
+	rows, err := s.db.Query(`
+		SELECT trade_date, close FROM index_history
+		WHERE symbol = ? AND trade_date >= ? AND trade_date <= ?
+	`, symbol, from.Format(calendar.DateLayout), to.Format(calendar.DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	closes := make(map[string]float64)
+	for rows.Next() {
+		var date time.Time
+		var value float64
+		if err := rows.Scan(&date, &value); err != nil {
+			return nil, err
+		}
+		closes[date.Format(calendar.DateLayout)] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(closes) == 0 {
+		return nil, fmt.Errorf("%w: no closes for %s", ErrInsufficientData, symbol)
+	}
+	return closes, nil
+}
+
+// fetchIndex 抓取指数收盘点位并写入缓存
+func (s *RiskService) fetchIndex(symbol string, from, to time.Time) error {
+	closes, err := s.indexes.FetchIndexHistory(symbol, from, to)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, item := range closes {
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO index_history (symbol, trade_date, close, source, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, symbol, item.Date, item.Close, item.Source, now)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// missingFrom 判断本地序列 [first, last] 是否覆盖 [from, to]，未覆盖时返回需要补抓的起始日期
+// 最新一个已收盘交易日的数据可能尚未公布，末端只要求覆盖到其前一个交易日；
+// 向前补抓受 riskBackfillInterval 限制，以免成立较晚的序列反复抓取
+func (s *RiskService) missingFrom(key string, first, last sql.NullString, from, to time.Time) (time.Time, bool) {
+	if !first.Valid || !last.Valid {
+		s.markBackfill(key)
+		return from, true
+	}
+
+	cal := calendar.Default()
+	end := to
+	if now := dateOnly(time.Now()); end.After(now) {
+		end = now
+	}
+	required := cal.PrevTradingDay(time.Date(end.Year(), end.Month(), end.Day(), 12, 0, 0, 0, cal.Location()))
+	if datePart(last.String) < cal.FormatDate(required) {
+		if lastDate, err := time.Parse(calendar.DateLayout, datePart(last.String)); err == nil {
+			return lastDate, true
+		}
+	}
+
+	if datePart(first.String) > from.Format(calendar.DateLayout) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if time.Since(s.backfills[key]) >= riskBackfillInterval {
+			s.backfills[key] = time.Now()
+			return from, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// markBackfill 记录序列最近一次向前补抓的时间
+func (s *RiskService) markBackfill(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backfills[key] = time.Now()
+}
+
+// datePart 取数据库日期字符串的日期部分
+func datePart(value string) string {
+	if len(value) > len(calendar.DateLayout) {
+		return value[:len(calendar.DateLayout)]
+	}
+	return value
+}
+
+// stdDev 样本标准差
+func stdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	return math.Sqrt(covariance(values, values))
+}
+
+// covariance 样本协方差
+func covariance(x, y []float64) float64 {
+	n := len(x)
+	if n < 2 {
+		return 0
+	}
+	var meanX, meanY float64
+	for i := 0; i < n; i++ {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	var sum float64
+	for i := 0; i < n; i++ {
+		sum += (x[i] - meanX) * (y[i] - meanY)
+	}
+	return sum / float64(n-1)
+}
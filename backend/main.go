@@ -9,9 +9,11 @@ import (
 	"syscall"
 	"time"
 
+	"fundnet/backend/internal/calendar"
 	"fundnet/backend/internal/config"
 	"fundnet/backend/internal/handlers"
 	"fundnet/backend/internal/models"
+	"fundnet/backend/internal/scrapers"
 	"fundnet/backend/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -24,6 +26,22 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// 加载交易日历
+	if cfg.App.HolidayFile != "" {
+		if err := calendar.Default().LoadFile(cfg.App.HolidayFile); err != nil {
+			log.Fatalf("Failed to load holiday file: %v", err)
+		}
+	}
+	for market, path := range cfg.App.OverseasHolidayFiles {
+		cal := calendar.Market(market)
+		if cal == nil {
+			log.Fatalf("Unknown market %q in overseas_holiday_files", market)
+		}
+		if err := cal.LoadFile(path); err != nil {
+			log.Fatalf("Failed to load %s holiday file: %v", market, err)
+		}
+	}
+
 	// 初始化数据库
 	if err := models.InitDB(cfg.Database.Path); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
@@ -31,8 +49,26 @@ func main() {
 	defer models.CloseDB()
 
 	// 初始化服务
-	fundService := services.NewFundService()
-	估值Service := services.NewEstimateService()
+	httpClient := scrapers.NewClient(cfg.Scraper)
+	eastmoney := scrapers.NewEastmoneyScraper(cfg.Scraper, httpClient)
+	registry := services.NewDataSourceRegistry(eastmoney)
+	sources, err := registry.Chain(cfg.Scraper.Sources, time.Duration(cfg.Scraper.StaleAfter)*time.Second)
+	if err != nil {
+		log.Fatalf("Failed to configure data sources: %v", err)
+	}
+
+	tencent := scrapers.NewTencentQuoteScraper(cfg.Scraper, httpClient)
+	navService := services.NewNavService(sources)
+	fundService := services.NewFundService(sources, navService, eastmoney, eastmoney)
+	fxService := services.NewFxService(scrapers.NewChinamoneyScraper(cfg.Scraper, httpClient))
+	估值Service := services.NewEstimateService(eastmoney, tencent, fxService, cfg.App)
+	riskService := services.NewRiskService(fundService, navService, tencent, cfg.App)
+	backtestService := services.NewBacktestService(fundService, riskService)
+
+	// 启动时先抓取最新一期汇率，外币份额持仓才能及时折算为人民币
+	if _, err := fxService.RefreshRates(); err != nil {
+		log.Printf("Failed to refresh fx rates: %v", err)
+	}
 
 	// 设置 Gin 模式
 	if cfg.Server.Mode == "release" {
@@ -46,10 +82,10 @@ func main() {
 	router.Use(corsMiddleware())
 
 	// 注册路由
-	handlers.RegisterRoutes(router, fundService, 估值Service)
+	handlers.RegisterRoutes(router, fundService, 估值Service, navService, riskService, backtestService, fxService, httpClient)
 
 	// 启动定时任务
-	go startScheduler(fundService, 估值Service, cfg)
+	go startScheduler(fundService, 估值Service, fxService, cfg)
 
 	// 创建 HTTP 服务器
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
@@ -94,13 +130,81 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
-func startScheduler(fundService *services.FundService, 估值Service *services.EstimateService, cfg *config.Config) {
+// 调度参数
+const (
+	closeGrace         = 5 * time.Minute  // 收盘后继续刷新估值的时长，用于取到 15:00 的最终估值
+	navRefreshInterval = 30 * time.Minute // 收盘后抓取官方净值的间隔
+)
+
+// startScheduler 交易时段内按 RefreshInterval 刷新估值；交易日收盘后定期抓取官方净值，
+// 每天抓取一次分红与拆分记录并记入持仓流水、为尚无费率表的基金抓取费率，持仓基金的当日净值全部公布后写入资产快照；
+// 交易日生成当日的定投申购并抓取当日的人民币汇率中间价；夜间、周末与节假日不做任何刷新
+func startScheduler(fundService *services.FundService, 估值Service *services.EstimateService, fxService *services.FxService,
+	cfg *config.Config) {
 	ticker := time.NewTicker(time.Duration(cfg.App.RefreshInterval) * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		log.Println("Executing scheduled estimation update...")
-		fundService.UpdateAllFundData()
-		估值Service.RefreshAllEstimates()
+	cal := calendar.Default()
+	var lastNavRefresh time.Time
+	var lastPlanRun, lastActionRefresh, lastFxRefresh string
+	for now := range ticker.C {
+		// 交易日每天生成一次当日到期的定投申购
+		if today := cal.FormatDate(now); cal.IsTradingDay(now) && today != lastPlanRun {
+			if count, err := fundService.RunInvestmentPlans(now); err != nil {
+				log.Printf("Failed to run investment plans: %v", err)
+			} else {
+				lastPlanRun = today
+				if count > 0 {
+					log.Printf("Generated %d investment plan orders", count)
+				}
+			}
+		}
+
+		// 中间价于交易日 9:15 公布，开盘后每天抓取一次
+		if today := cal.FormatDate(now); cal.IsTradingTime(now) && today != lastFxRefresh {
+			if _, err := fxService.RefreshRates(); err != nil {
+				log.Printf("Failed to refresh fx rates: %v", err)
+			} else {
+				lastFxRefresh = today
+			}
+		}
+
+		switch {
+		case cal.IsTradingTime(now) || cal.IsTradingTime(now.Add(-closeGrace)):
+			log.Println("Executing scheduled estimation update...")
+			fundService.UpdateAllFundData()
+			估值Service.RefreshAllEstimates()
+		case cal.IsTradingDay(now) && cal.IsAfterClose(now) && now.Sub(lastNavRefresh) >= navRefreshInterval:
+			log.Println("Executing scheduled NAV update...")
+			lastNavRefresh = now
+			fundService.UpdateAllFundData()
+			if today := cal.FormatDate(now); today != lastActionRefresh {
+				lastActionRefresh = today
+				fundService.RefreshAllCorporateActions()
+				fundService.RefreshStaleFundMetadata(now)
+				fundService.RefreshMissingFeeSchedules()
+			}
+			if count, err := fundService.ApplyCorporateActions(now); err != nil {
+				log.Printf("Failed to apply dividends and splits: %v", err)
+			} else if count > 0 {
+				log.Printf("Recorded %d dividend and split transactions", count)
+			}
+			if count, err := fundService.AccrueMoneyIncome(now); err != nil {
+				log.Printf("Failed to accrue money market income: %v", err)
+			} else if count > 0 {
+				log.Printf("Recorded %d money market income transactions", count)
+			}
+			if err := 估值Service.SettleEstimateSnapshots(); err != nil {
+				log.Printf("Failed to settle estimate snapshots: %v", err)
+			}
+			// 收盘价与当日官方净值公布后重新计算溢价率
+			if _, err := 估值Service.RefreshMarketPrices(); err != nil {
+				log.Printf("Failed to refresh exchange prices: %v", err)
+			}
+			估值Service.RevalueAllPositions()
+			if _, err := fundService.SnapshotIfReady(now); err != nil {
+				log.Printf("Failed to take asset snapshot: %v", err)
+			}
+		}
 	}
 }